@@ -0,0 +1,369 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+
+	"github.com/aclements/go-gg/generic/slice"
+	"github.com/aclements/go-gg/table"
+	"github.com/aclements/go-moremath/scale"
+)
+
+// NewBinnedScaler returns a continuous scale that divides its domain
+// into bins and maps each value to the level of its bin. This is
+// useful for mapping a continuous column to a discrete palette, such
+// as NewColorRanger(brewer.Blues_7). binning chooses the edges of the
+// bins; if binning is nil, it defaults to BinPretty(5).
+//
+// If the scale's Ranger is a ContinuousRanger, each bin maps to the
+// range value at the bin's center, so values are mapped in steps.
+// If it is a DiscreteRanger, bins are spread evenly across its
+// levels; if there are more bins than levels, some adjacent bins
+// share a level. The scale's ticks are at the edges of the bins, and
+// its legend is a stepped color bar.
+//
+// Binned scales are intended for non-positional aesthetics.
+func NewBinnedScaler(binning Binning) ContinuousScaler {
+	if binning == nil {
+		binning = BinPretty(5)
+	}
+	s := &binnedScale{
+		lin:     NewLinearScaler().(*moremathScale),
+		binning: binning,
+	}
+	if b, ok := binning.(BinBreaks); ok && len(b) > 0 {
+		// Expand the domain to the outermost breaks.
+		lo, hi := b[0], b[0]
+		for _, x := range b {
+			lo, hi = math.Min(lo, x), math.Max(hi, x)
+		}
+		s.lin.Include(lo)
+		s.lin.Include(hi)
+	}
+	return s
+}
+
+// A Binning chooses the edges of the bins of a binned scale. See
+// NewBinnedScaler.
+type Binning interface {
+	// bins returns the edges of the bins, in increasing order,
+	// for data in the domain [min, max], and the subset of edges
+	// to label.
+	bins(data []float64, min, max float64) (edges, breaks []float64)
+}
+
+// BinBreaks is a Binning with bins between explicit breaks. The
+// scale's domain is expanded to include all of the breaks. Values
+// below the first break or above the last break fall in additional
+// bins extending to the bounds of the domain.
+type BinBreaks []float64
+
+func (b BinBreaks) bins(_ []float64, min, max float64) (edges, breaks []float64) {
+	breaks = append([]float64(nil), b...)
+	sort.Float64s(breaks)
+	breaks = slice.Nub(breaks).([]float64)
+	edges = breaks
+	if len(breaks) == 0 || min < breaks[0] {
+		edges = append([]float64{min}, edges...)
+	}
+	if max > edges[len(edges)-1] {
+		edges = append(edges, max)
+	}
+	return edges, breaks
+}
+
+// BinPretty is a Binning with at most N bins whose edges are "nice"
+// round values. The scale's domain is expanded to nice values.
+type BinPretty int
+
+func (n BinPretty) bins(_ []float64, min, max float64) (edges, breaks []float64) {
+	if n <= 0 {
+		n = 5
+	}
+	if min < max {
+		o := scale.TickOptions{Max: int(n) + 1}
+		ls := scale.Linear{Min: min, Max: max}
+		ls.Nice(o)
+		edges, _ = ls.Ticks(o)
+	}
+	if len(edges) < 2 {
+		edges = []float64{min, max}
+	}
+	return edges, edges
+}
+
+// BinQuantile is a Binning with N bins that each contain about the
+// same number of data values. Only the edges between bins are
+// labeled.
+type BinQuantile int
+
+func (n BinQuantile) bins(data []float64, min, max float64) (edges, breaks []float64) {
+	var xs []float64
+	for _, x := range data {
+		if min <= x && x <= max {
+			xs = append(xs, x)
+		}
+	}
+	sort.Float64s(xs)
+	edges = []float64{min}
+	for k := 1; k < int(n) && len(xs) > 0; k++ {
+		// Interpolate between the closest ranks.
+		pos := float64(k) / float64(n) * float64(len(xs)-1)
+		i, frac := math.Modf(pos)
+		q := xs[int(i)]
+		if frac > 0 {
+			q += frac * (xs[int(i)+1] - q)
+		}
+		if q > edges[len(edges)-1] && q < max {
+			edges = append(edges, q)
+		}
+	}
+	edges = append(edges, max)
+	return edges, edges[1 : len(edges)-1]
+}
+
+type binnedScale struct {
+	r       Ranger
+	ticks   TickSpec
+	binning Binning
+
+	// lin tracks the domain and formats labels.
+	lin *moremathScale
+
+	// data is the training data, for binnings that depend on the
+	// distribution of the data.
+	data []float64
+
+	// edges and breaks cache the result of bins. They are nil if
+	// they must be recomputed.
+	edges, breaks []float64
+}
+
+func (s *binnedScale) String() string {
+	min, max := s.lin.bounds()
+	return fmt.Sprintf("binned [%g,%g] => %s", min, max, s.r)
+}
+
+func (s *binnedScale) ExpandDomain(vs table.Slice) {
+	s.edges, s.breaks = nil, nil
+	s.lin.ExpandDomain(vs)
+	var data []float64
+	slice.Convert(&data, vs)
+	for _, x := range data {
+		if isFinite(x) {
+			s.data = append(s.data, x)
+		}
+	}
+}
+
+func (s *binnedScale) SetMin(v interface{}) ContinuousScaler {
+	s.edges, s.breaks = nil, nil
+	s.lin.SetMin(v)
+	return s
+}
+
+func (s *binnedScale) SetMax(v interface{}) ContinuousScaler {
+	s.edges, s.breaks = nil, nil
+	s.lin.SetMax(v)
+	return s
+}
+
+func (s *binnedScale) SetSoftMin(v interface{}) ContinuousScaler {
+	s.edges, s.breaks = nil, nil
+	s.lin.SetSoftMin(v)
+	return s
+}
+
+func (s *binnedScale) SetSoftMax(v interface{}) ContinuousScaler {
+	s.edges, s.breaks = nil, nil
+	s.lin.SetSoftMax(v)
+	return s
+}
+
+func (s *binnedScale) SetOOB(oob OOB) ContinuousScaler {
+	s.lin.SetOOB(oob)
+	return s
+}
+
+func (s *binnedScale) SetReverse(reverse bool) ContinuousScaler {
+	s.lin.SetReverse(reverse)
+	return s
+}
+
+func (s *binnedScale) Include(v interface{}) ContinuousScaler {
+	s.edges, s.breaks = nil, nil
+	s.lin.Include(v)
+	return s
+}
+
+func (s *binnedScale) Ranger(r Ranger) Ranger {
+	old := s.r
+	if r != nil {
+		s.r = r
+	}
+	return old
+}
+
+func (s *binnedScale) RangeType() reflect.Type {
+	return s.r.RangeType()
+}
+
+// bins returns the edges of s's bins and the edges to label.
+func (s *binnedScale) bins() (edges, breaks []float64) {
+	if s.edges != nil {
+		return s.edges, s.breaks
+	}
+	min, max := s.lin.bounds()
+	edges, breaks = s.binning.bins(s.data, min, max)
+	if len(edges) < 2 {
+		edges = []float64{min, max}
+	}
+	s.edges, s.breaks = edges, breaks
+	return edges, breaks
+}
+
+func (s *binnedScale) Map(x interface{}) interface{} {
+	var v float64
+	switch x := x.(type) {
+	case float64:
+		v = x
+	case Unscaled:
+		return mapUnit(s.r, float64(x), OOBKeep)
+	default:
+		v = reflect.ValueOf(x).Convert(float64Type).Float()
+	}
+
+	edges, _ := s.bins()
+	n := len(edges) - 1
+	if s.lin.oob == OOBCensor && (v < edges[0] || v > edges[n]) || math.IsNaN(v) {
+		return mapUnit(s.r, math.NaN(), OOBKeep)
+	}
+	// Find the bin containing v. Bins include their lower edge,
+	// and the last bin also includes its upper edge.
+	inner := edges[1:n]
+	bin := sort.Search(len(inner), func(i int) bool { return inner[i] > v })
+	return s.mapBin(bin, n)
+}
+
+// mapBin maps the i'th of n bins through s's Ranger.
+func (s *binnedScale) mapBin(i, n int) interface{} {
+	if s.lin.reverse {
+		i = n - 1 - i
+	}
+	switch r := s.r.(type) {
+	case ContinuousRanger:
+		return r.Map((float64(i) + 0.5) / float64(n))
+
+	case DiscreteRanger:
+		_, levels := r.Levels()
+		level := 0
+		if n > 1 {
+			// Spread the bins across the levels. If there
+			// are more bins than levels, adjacent bins
+			// share levels.
+			level = int(float64(i)*float64(levels-1)/float64(n-1) + 0.5)
+		}
+		return r.MapLevel(level, levels)
+
+	default:
+		panic("Ranger must be a ContinuousRanger or DiscreteRanger")
+	}
+}
+
+func (s *binnedScale) Ticks(max int, pred func(major, minor table.Slice, labels []string) bool) (major, minor table.Slice, labels []string) {
+	format := func(major table.Slice) []string {
+		var xs []float64
+		slice.Convert(&xs, major)
+		return s.lin.formatTicks(xs)
+	}
+	return s.ticks.apply(max, pred, s.lin.domain, func(int, func(major, minor table.Slice, labels []string) bool) (table.Slice, table.Slice, []string) {
+		if s.lin.domainType == nil {
+			return nil, nil, nil
+		}
+		// Put ticks at the edges of the bins.
+		_, breaks := s.bins()
+		major := reflect.New(reflect.SliceOf(s.lin.domainType))
+		slice.Convert(major.Interface(), breaks)
+		return major.Elem().Interface(), major.Elem().Interface(), s.lin.formatTicks(breaks)
+	}, format)
+}
+
+func (s *binnedScale) SetFormatter(f interface{}) {
+	s.lin.SetFormatter(f)
+}
+
+func (s *binnedScale) SetTicks(t TickSpec) {
+	s.ticks = t
+}
+
+func (s *binnedScale) CloneScaler() Scaler {
+	s2 := *s
+	s2.lin = s.lin.CloneScaler().(*moremathScale)
+	s2.data = append([]float64(nil), s.data...)
+	return &s2
+}
+
+// legendBar returns a stepped color bar for s, with a step of equal
+// length for each bin.
+func (s *binnedScale) legendBar(maxTicks int) *legendBar {
+	major, _, labels := s.Ticks(maxTicks, nil)
+	if len(labels) == 0 {
+		return nil
+	}
+	edges, _ := s.bins()
+	n := len(edges) - 1
+
+	// Position each tick linearly within its bin.
+	var xs, ticks []float64
+	var tickLabels []string
+	slice.Convert(&xs, major)
+	for i, x := range xs {
+		bin := sort.SearchFloat64s(edges, x)
+		var pos float64
+		switch {
+		case bin == 0:
+			pos = 0
+			if x < edges[0] {
+				continue
+			}
+		case bin > n:
+			continue
+		default:
+			pos = (float64(bin-1) + (x-edges[bin-1])/(edges[bin]-edges[bin-1])) / float64(n)
+		}
+		ticks = append(ticks, pos)
+		tickLabels = append(tickLabels, labels[i])
+	}
+	return &legendBar{&binBarRanger{s, n}, ticks, tickLabels}
+}
+
+// binBarRanger maps positions along a stepped color bar to the
+// colors of the corresponding bins.
+type binBarRanger struct {
+	s *binnedScale
+	n int
+}
+
+func (r *binBarRanger) RangeType() reflect.Type {
+	return r.s.RangeType()
+}
+
+func (r *binBarRanger) Map(x float64) interface{} {
+	bin := int(x * float64(r.n))
+	if bin < 0 {
+		bin = 0
+	} else if bin >= r.n {
+		bin = r.n - 1
+	}
+	return r.s.mapBin(bin, r.n)
+}
+
+func (r *binBarRanger) Unmap(y interface{}) (float64, bool) {
+	return 0, false
+}
@@ -0,0 +1,66 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBinQuantile(t *testing.T) {
+	for _, test := range []struct {
+		n        BinQuantile
+		data     []float64
+		min, max float64
+		edges    []float64
+	}{
+		// Quantiles that fall on data values.
+		{4, []float64{40, 0, 30, 10, 20}, 0, 40, []float64{0, 10, 20, 30, 40}},
+		// Quantiles between data values are interpolated.
+		{3, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9}, 1, 9, []float64{1, 3 + 2.0/3, 6 + 1.0/3, 9}},
+		// Data outside the domain is ignored.
+		{3, []float64{-5, 1, 2, 3, 4, 5, 6, 7, 8, 9, 100}, 1, 9, []float64{1, 3 + 2.0/3, 6 + 1.0/3, 9}},
+		// Quantiles at the bounds or repeated by ties are
+		// dropped rather than producing empty bins.
+		{2, []float64{1, 1, 1, 1, 5}, 1, 5, []float64{1, 5}},
+		{2, []float64{1, 9, 9, 9}, 1, 9, []float64{1, 9}},
+		{4, []float64{0, 1, 1, 1, 1, 1, 1, 1, 2}, 0, 2, []float64{0, 1, 2}},
+		// The domain can extend beyond the data.
+		{2, []float64{2, 4, 6}, 0, 10, []float64{0, 4, 10}},
+		// Without data, there's a single bin.
+		{3, nil, 0, 1, []float64{0, 1}},
+	} {
+		edges, breaks := test.n.bins(test.data, test.min, test.max)
+		if !floatsEqual(edges, test.edges) {
+			t.Errorf("BinQuantile(%d) of %v in [%v, %v]: got edges %v, want %v", test.n, test.data, test.min, test.max, edges, test.edges)
+			continue
+		}
+		// Only the inner edges are labeled.
+		if want := test.edges[1 : len(test.edges)-1]; !floatsEqual(breaks, want) {
+			t.Errorf("BinQuantile(%d) of %v in [%v, %v]: got breaks %v, want %v", test.n, test.data, test.min, test.max, breaks, want)
+		}
+	}
+}
+
+func TestBinQuantileScale(t *testing.T) {
+	s := NewBinnedScaler(BinQuantile(2))
+	s.ExpandDomain([]float64{1, 2, 3, 4, 10})
+	s.Ranger(NewFloatRanger(0, 1))
+
+	// The median splits the domain into two bins, each of which
+	// maps to its center. Bins include their lower edge.
+	for _, c := range []struct{ x, want float64 }{
+		{1, 0.25}, {2.5, 0.25}, {3, 0.75}, {10, 0.75},
+	} {
+		if got := s.Map(c.x).(float64); got != c.want {
+			t.Errorf("Map(%v) = %v, want %v", c.x, got, c.want)
+		}
+	}
+
+	major, _, labels := s.Ticks(10, nil)
+	if !reflect.DeepEqual(major, []float64{3}) || !reflect.DeepEqual(labels, []string{"3"}) {
+		t.Errorf("got ticks %v labeled %v, want [3] labeled [3]", major, labels)
+	}
+}
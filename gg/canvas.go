@@ -0,0 +1,259 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// A Canvas is a drawing surface that a Plot renders to. Coordinates
+// are in pixels, with the origin at the top-left corner and Y
+// increasing downward.
+//
+// The gg package provides SVG and raster Canvases through
+// Plot.WriteSVG and Plot.WritePNG. Other output formats can be
+// supported by implementing Canvas and passing it to Plot.Render.
+type Canvas interface {
+	// Path strokes and fills path p according to style.
+	Path(p *Path, style PathStyle)
+
+	// Circle strokes and fills the circle centered at (cx, cy)
+	// with radius r.
+	Circle(cx, cy, r float64, style PathStyle)
+
+	// Rect strokes and fills the rectangle with top-left corner
+	// (x, y), width w, and height h.
+	Rect(x, y, w, h float64, style PathStyle)
+
+	// Text draws text anchored at (x, y).
+	Text(x, y float64, text string, style TextStyle)
+
+	// Image draws img scaled to fill the rectangle with top-left
+	// corner (x, y), width w, and height h. Images are scaled
+	// without interpolation.
+	Image(x, y, w, h float64, img image.Image)
+
+	// BeginGroup begins a group of drawing operations, which must
+	// be ended by a matching call to EndGroup. If clip is
+	// non-nil, drawing in the group is clipped to clip (and any
+	// clip regions of enclosing groups).
+	BeginGroup(clip *Rect)
+
+	// EndGroup ends the group started by the matching
+	// BeginGroup.
+	EndGroup()
+}
+
+// Rect is an axis-aligned rectangle with top-left corner (X, Y),
+// width W, and height H.
+type Rect struct {
+	X, Y, W, H float64
+}
+
+// PathStyle gives the paint of a path or shape.
+type PathStyle struct {
+	// Stroke and Fill are the stroke and fill colors. If either
+	// is nil or fully transparent, the path is not stroked or not
+	// filled, respectively.
+	Stroke, Fill color.Color
+
+	// StrokeWidth is the width of the stroke in pixels.
+	StrokeWidth float64
+
+	// Dash is the dash pattern of the stroke, as alternating
+	// lengths of dashes and gaps in pixels. If nil, the stroke is
+	// solid.
+	Dash []float64
+}
+
+// TextAnchor is the horizontal alignment of text relative to its
+// anchor point.
+type TextAnchor int
+
+const (
+	TextAnchorStart TextAnchor = iota
+	TextAnchorMiddle
+	TextAnchorEnd
+)
+
+// TextBaseline is the vertical alignment of text relative to its
+// anchor point.
+type TextBaseline int
+
+const (
+	// TextBaselineAlphabetic aligns the text's baseline with the
+	// anchor point.
+	TextBaselineAlphabetic TextBaseline = iota
+
+	// TextBaselineMiddle approximately centers the text on the
+	// anchor point.
+	TextBaselineMiddle
+
+	// TextBaselineTop approximately aligns the top of the text
+	// with the anchor point.
+	TextBaselineTop
+)
+
+// shift returns the distance from the anchor point to the
+// text's alphabetic baseline, as a multiple of the font size.
+func (b TextBaseline) shift() float64 {
+	switch b {
+	case TextBaselineMiddle:
+		return 0.3
+	case TextBaselineTop:
+		return 1
+	}
+	return 0
+}
+
+// TextStyle gives the appearance of text.
+type TextStyle struct {
+	// Size is the font size in pixels. If Size is 0, it is the
+	// default font size.
+	Size float64
+
+	// Fill is the text color. If nil, it is black.
+	Fill color.Color
+
+	// Font is the font to draw the text in. If nil, it is the
+	// default font. Canvases that leave drawing text to a viewer
+	// may ignore Font.
+	Font *Font
+
+	Anchor   TextAnchor
+	Baseline TextBaseline
+
+	// Rotate is the clockwise rotation of the text about its
+	// anchor point, in degrees.
+	Rotate float64
+}
+
+// A Path is a sequence of connected line and curve segments,
+// possibly consisting of several disconnected subpaths.
+type Path struct {
+	Segs []PathSeg
+}
+
+// PathOp is the operation of a path segment.
+type PathOp int
+
+const (
+	// PathMoveTo begins a new subpath at Pts[0].
+	PathMoveTo PathOp = iota
+
+	// PathLineTo draws a straight line to Pts[0].
+	PathLineTo
+
+	// PathCubeTo draws a cubic Bézier curve to Pts[0] with
+	// control points Pts[1] and Pts[2].
+	PathCubeTo
+
+	// PathClose closes the current subpath by drawing a line back
+	// to its starting point.
+	PathClose
+)
+
+// PathSeg is a single path segment. Pts[0] is the end point of the
+// segment. For cubic segments, Pts[1] and Pts[2] are the control
+// points.
+type PathSeg struct {
+	Op  PathOp
+	Pts [3]Point
+}
+
+// Point is a point in canvas coordinates.
+type Point struct {
+	X, Y float64
+}
+
+// MoveTo begins a new subpath at (x, y).
+func (p *Path) MoveTo(x, y float64) {
+	p.Segs = append(p.Segs, PathSeg{Op: PathMoveTo, Pts: [3]Point{{x, y}}})
+}
+
+// LineTo adds a line to (x, y).
+func (p *Path) LineTo(x, y float64) {
+	p.Segs = append(p.Segs, PathSeg{Op: PathLineTo, Pts: [3]Point{{x, y}}})
+}
+
+// CubeTo adds a cubic Bézier curve with control points (x1, y1) and
+// (x2, y2) ending at (x, y).
+func (p *Path) CubeTo(x1, y1, x2, y2, x, y float64) {
+	p.Segs = append(p.Segs, PathSeg{Op: PathCubeTo, Pts: [3]Point{{x, y}, {x1, y1}, {x2, y2}}})
+}
+
+// Close closes the current subpath.
+func (p *Path) Close() {
+	p.Segs = append(p.Segs, PathSeg{Op: PathClose})
+}
+
+// circlePath returns a path approximating a circle using four cubic
+// Bézier curves.
+func circlePath(cx, cy, r float64) *Path {
+	// Control point distance for a quarter circle.
+	k := r * 4 * (math.Sqrt2 - 1) / 3
+	var p Path
+	p.MoveTo(cx+r, cy)
+	p.CubeTo(cx+r, cy+k, cx+k, cy+r, cx, cy+r)
+	p.CubeTo(cx-k, cy+r, cx-r, cy+k, cx-r, cy)
+	p.CubeTo(cx-r, cy-k, cx-k, cy-r, cx, cy-r)
+	p.CubeTo(cx+k, cy-r, cx+r, cy-k, cx+r, cy)
+	p.Close()
+	return &p
+}
+
+// rectPath returns a path tracing the rectangle (x, y, w, h).
+func rectPath(x, y, w, h float64) *Path {
+	var p Path
+	p.MoveTo(x, y)
+	p.LineTo(x+w, y)
+	p.LineTo(x+w, y+h)
+	p.LineTo(x, y+h)
+	p.Close()
+	return &p
+}
+
+// roundRectPath returns a path tracing the rectangle (x, y, w, h)
+// with corners rounded to radius r.
+func roundRectPath(x, y, w, h, r float64) *Path {
+	r = math.Min(r, math.Min(w, h)/2)
+	k := r * 4 * (math.Sqrt2 - 1) / 3
+	var p Path
+	p.MoveTo(x+r, y)
+	p.LineTo(x+w-r, y)
+	p.CubeTo(x+w-r+k, y, x+w, y+r-k, x+w, y+r)
+	p.LineTo(x+w, y+h-r)
+	p.CubeTo(x+w, y+h-r+k, x+w-r+k, y+h, x+w-r, y+h)
+	p.LineTo(x+r, y+h)
+	p.CubeTo(x+r-k, y+h, x, y+h-r+k, x, y+h-r)
+	p.LineTo(x, y+r)
+	p.CubeTo(x, y+r-k, x+r-k, y, x+r, y)
+	p.Close()
+	return &p
+}
+
+// isPaint returns whether c is a visible color.
+func isPaint(c color.Color) bool {
+	if c == nil {
+		return false
+	}
+	_, _, _, a := c.RGBA()
+	return a != 0
+}
+
+// withOpacity returns c with its alpha multiplied by opacity.
+func withOpacity(c color.Color, opacity float64) color.Color {
+	if opacity >= 1 {
+		return c
+	}
+	r, g, b, a := c.RGBA()
+	return color.RGBA64{
+		uint16(float64(r) * opacity),
+		uint16(float64(g) * opacity),
+		uint16(float64(b) * opacity),
+		uint16(float64(a) * opacity)}
+}
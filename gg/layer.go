@@ -6,7 +6,10 @@ package gg
 
 import (
 	"fmt"
+	"math"
 
+	"github.com/aclements/go-gg/generic/slice"
+	"github.com/aclements/go-gg/ggstat"
 	"github.com/aclements/go-gg/table"
 )
 
@@ -74,12 +77,21 @@ func (l LayerSteps) Apply(p *Plot) {
 	// ribbons.
 
 	defaultCols(p, &l.X, &l.Y)
+	groupCategorical(p, l.LineWidth)
+	if l.LineType != "" {
+		p.GroupBy(l.LineType)
+	}
+	defer p.Save().Restore()
+	y := stackPosition(p, l.Position, l.X, l.Y)
 	p.marks = append(p.marks, plotMark{&markSteps{
 		l.Step,
 		p.use("x", l.X),
-		p.use("y", l.Y),
+		p.useAs("y", y, l.Y),
 		p.use("stroke", l.Color),
 		p.use("fill", l.Fill),
+		p.use("linewidth", l.LineWidth),
+		p.use("linetype", l.LineType),
+		l.Position,
 	}, p.Data().Tables()})
 }
 
@@ -101,14 +113,35 @@ type LayerPaths struct {
 	// data is grouped by Fill.
 	Fill string
 
+	// LineWidth names a column that defines the width of each
+	// path. Columns of LineWidth values give the width in
+	// pixels; other numeric columns are scaled. If LineWidth is
+	// "", it defaults to 3 pixels. If LineWidth is categorical,
+	// the data is grouped by LineWidth. Otherwise, the width may
+	// vary along a path, and each segment takes the width of the
+	// point it starts from.
+	LineWidth string
+
+	// LineType names a column that defines the dash pattern of
+	// each path. Columns of LineType values are used as is;
+	// other columns are mapped to dash patterns by a discrete
+	// scale. If LineType is "", paths are solid. Otherwise, the
+	// data is grouped by LineType.
+	LineType string
+
+	// Position adjusts the positions of paths from different
+	// groups. For example, PositionStack stacks paths at the
+	// same X. If Position is nil, paths are not adjusted.
+	Position Position
+
 	// XXX Perhaps the theme should provide default values for
 	// things like "color". That would suggest we need to resolve
 	// defaults like that at render time. Possibly a special scale
 	// that gets values from the theme could be used to resolve
 	// them.
 	//
-	// XXX strokeOpacity, fillOpacity, strokeWidth, what other
-	// properties do SVG strokes have?
+	// XXX strokeOpacity, fillOpacity, what other properties do
+	// SVG strokes have?
 	//
 	// XXX Should the set of known styling bindings be fixed, and
 	// all possible rendering targets have to know what to do with
@@ -132,19 +165,39 @@ func (l LayerPaths) apply(p *Plot, sort bool) {
 	if l.Fill != "" {
 		p.GroupBy(l.Fill)
 	}
+	groupCategorical(p, l.LineWidth)
+	if l.LineType != "" {
+		p.GroupBy(l.LineType)
+	}
+	defer p.Save().Restore()
 	if sort {
-		defer p.Save().Restore()
 		p = p.SortBy(l.X)
 	}
+	y := stackPosition(p, l.Position, l.X, l.Y)
 
 	p.marks = append(p.marks, plotMark{&markPath{
 		p.use("x", l.X),
-		p.use("y", l.Y),
+		p.useAs("y", y, l.Y),
 		p.use("stroke", l.Color),
 		p.use("fill", l.Fill),
+		p.use("linewidth", l.LineWidth),
+		p.use("linetype", l.LineType),
+		l.Position,
 	}, p.Data().Tables()})
 }
 
+// groupCategorical groups p by col if col is categorical, using the
+// same rule as GroupAuto. If col is "", it does nothing.
+func groupCategorical(p *Plot, col string) {
+	if col == "" {
+		return
+	}
+	et := table.ColType(p.Data(), col).Elem()
+	if et.Comparable() && !isCardinal(et.Kind()) {
+		p.GroupBy(col)
+	}
+}
+
 // LayerArea shades the area between two columns with a polygon. It is
 // useful in conjunction with ggstat.AggMax and ggstat.AggMin for
 // drawing the extents of data.
@@ -167,6 +220,13 @@ type LayerArea struct {
 	// each area. If FillOpacity is "", it defaults to 0.5.
 	// Otherwise, the data is grouped by FillOpacity.
 	FillOpacity string
+
+	// Position adjusts the positions of areas from different
+	// groups. If Position stacks, such as PositionStack, the
+	// areas of each group are stacked on each other, Upper gives
+	// the height of each area, and Lower is ignored. If Position
+	// is nil, areas are not adjusted.
+	Position Position
 }
 
 func (l LayerArea) Apply(p *Plot) {
@@ -180,21 +240,727 @@ func (l LayerArea) Apply(p *Plot) {
 	defer p.Save().Restore()
 	p = p.SortBy(l.X)
 	upper, lower := l.Upper, l.Lower
+	upperLabel, lowerLabel := upper, lower
 	if upper == "" {
 		upper = p.Const(0)
 	}
+	if l.Position != nil {
+		if lo, hi := l.Position.stack(p, l.X, upper); hi != "" {
+			lower, upper = lo, hi
+			lowerLabel = ""
+		}
+	}
 	if lower == "" {
 		lower = p.Const(0)
 	}
 	p.marks = append(p.marks, plotMark{&markArea{
 		p.use("x", l.X),
-		p.use("y", upper),
-		p.use("y", lower),
+		p.useAs("y", upper, upperLabel),
+		p.useAs("y", lower, lowerLabel),
 		p.use("fill", l.Fill),
 		p.use("opacity", l.FillOpacity),
+		l.Position,
 	}, p.Data().Tables()})
 }
 
+// LayerBars layers a bar at each data point, extending from zero to
+// Y. It groups by Fill and Color, and arranges the bars of different
+// groups at the same X according to Position.
+//
+// X may be continuous or ordinal. Each bar is centered on X and its
+// width is a fraction of the "band" width, which is the smallest
+// distance between distinct X positions in each subplot.
+type LayerBars struct {
+	// X and Y name columns that define the position and length
+	// of each bar. If these are empty, they default to the first
+	// and second columns, respectively.
+	X, Y string
+
+	// Fill names a column that defines the fill color of each
+	// bar. If Fill is "", it defaults to black. Otherwise, the
+	// data is grouped by Fill.
+	Fill string
+
+	// Color names a column that defines the stroke color of each
+	// bar. If Color is "", bars are not stroked. Otherwise, the
+	// data is grouped by Color.
+	Color string
+
+	// Position controls how bars from different groups at the
+	// same X are arranged. If Position is nil, bars are stacked
+	// with PositionStack. PositionDodge places them side by
+	// side and PositionIdentity overlaps them.
+	Position Position
+
+	// Width is the width of each bar as a fraction of the band
+	// width. If Width is 0, it defaults to 0.9.
+	Width float64
+
+	// Edge indicates that X gives the lower edge of each band,
+	// rather than its center. This is useful for the output of
+	// ggstat.Bin, where X is the left edge of each bin.
+	Edge bool
+
+	// Horizontal makes bars extend horizontally from zero to X,
+	// positioned at Y, rather than vertically. X and Y still
+	// name the columns bound to the x and y axes.
+	Horizontal bool
+}
+
+func (l LayerBars) Apply(p *Plot) {
+	defaultCols(p, &l.X, &l.Y)
+	if l.Fill != "" {
+		p.GroupBy(l.Fill)
+	}
+	if l.Color != "" {
+		p.GroupBy(l.Color)
+	}
+	width := l.Width
+	if width == 0 {
+		width = defaultBandWidth
+	}
+	position := l.Position
+	if position == nil {
+		position = PositionStack{}
+	}
+	posAes, posCol, valAes, valCol := "x", l.X, "y", l.Y
+	if l.Horizontal {
+		posAes, posCol, valAes, valCol = "y", l.Y, "x", l.X
+	}
+
+	defer p.Save().Restore()
+	lo, hi := position.stack(p, posCol, valCol)
+	if hi == "" {
+		lo, hi = p.Const(0), valCol
+	}
+
+	p.marks = append(p.marks, plotMark{&markBars{
+		p.use(posAes, posCol),
+		p.useAs(valAes, lo, ""),
+		p.useAs(valAes, hi, valCol),
+		p.use("fill", l.Fill),
+		p.use("stroke", l.Color),
+		width,
+		position,
+		l.Edge,
+		l.Horizontal,
+	}, p.Data().Tables()})
+}
+
+// LayerErrorBars layers an error bar at each data point: a line
+// spanning from YMin to YMax at X, with a cap at each end. It groups
+// by Color, and arranges the error bars of different groups at the
+// same X according to Position.
+//
+// Like LayerBars, X may be continuous or ordinal, and the width of
+// the caps is a fraction of the band width.
+type LayerErrorBars struct {
+	// X names the column that defines the position of each error
+	// bar. If X is "", it defaults to the first column.
+	X string
+
+	// YMin and YMax name columns that define the lower and upper
+	// bounds of each error bar. They are required unless
+	// Horizontal is set. The y scale is trained on both bounds.
+	YMin, YMax string
+
+	// Y, XMin, and XMax replace X, YMin, and YMax when Horizontal
+	// is set. If Y is "", it defaults to the second column.
+	Y, XMin, XMax string
+
+	// Color names a column that defines the stroke color of each
+	// error bar. If Color is "", it defaults to black. Otherwise,
+	// the data is grouped by Color.
+	Color string
+
+	// Width is the width of the caps as a fraction of the band
+	// width. If Width is 0, it defaults to 0.5.
+	Width float64
+
+	// Position adjusts the positions of error bars from different
+	// groups at the same X. For example, PositionDodge places
+	// them side by side to match dodged bars. If Position is nil,
+	// error bars are not adjusted.
+	Position Position
+
+	// Horizontal makes error bars span horizontally from XMin to
+	// XMax at each Y.
+	Horizontal bool
+}
+
+func (l LayerErrorBars) Apply(p *Plot) {
+	var pos, lo, hi string
+	if l.Horizontal {
+		defaultCols(p, &l.X, &l.Y)
+		pos, lo, hi = l.Y, l.XMin, l.XMax
+	} else {
+		defaultCols(p, &l.X)
+		pos, lo, hi = l.X, l.YMin, l.YMax
+	}
+	width := l.Width
+	if width == 0 {
+		width = 0.5
+	}
+	addInterval(p, "LayerErrorBars", &markInterval{
+		shape:      intervalErrorBar,
+		width:      width,
+		position:   l.Position,
+		horizontal: l.Horizontal,
+	}, pos, "", lo, hi, l.Color, "")
+}
+
+// LayerPointRange layers a point at (X, Y) and a line spanning from
+// YMin to YMax through it. It groups by Color, and arranges the point
+// ranges of different groups at the same X according to Position.
+type LayerPointRange struct {
+	// X and Y name columns that define the position of each
+	// point. If these are empty, they default to the first and
+	// second columns, respectively.
+	X, Y string
+
+	// YMin and YMax name columns that define the lower and upper
+	// bounds of the line through each point. They are required
+	// unless Horizontal is set. The y scale is trained on both
+	// bounds.
+	YMin, YMax string
+
+	// XMin and XMax replace YMin and YMax when Horizontal is set.
+	XMin, XMax string
+
+	// Color names a column that defines the color of each point
+	// and line. If Color is "", it defaults to black. Otherwise,
+	// the data is grouped by Color.
+	Color string
+
+	// Position adjusts the positions of point ranges from
+	// different groups at the same X. If Position is nil, point
+	// ranges are not adjusted.
+	Position Position
+
+	// Horizontal makes lines span horizontally from XMin to XMax
+	// through each point.
+	Horizontal bool
+}
+
+func (l LayerPointRange) Apply(p *Plot) {
+	defaultCols(p, &l.X, &l.Y)
+	pos, mid, lo, hi := l.X, l.Y, l.YMin, l.YMax
+	if l.Horizontal {
+		pos, mid, lo, hi = l.Y, l.X, l.XMin, l.XMax
+	}
+	addInterval(p, "LayerPointRange", &markInterval{
+		shape:      intervalPointRange,
+		width:      defaultBandWidth,
+		position:   l.Position,
+		horizontal: l.Horizontal,
+	}, pos, mid, lo, hi, l.Color, "")
+}
+
+// LayerCrossbar layers a box spanning from YMin to YMax at each X,
+// with a line across it at Y. It groups by Color and Fill, and
+// arranges the crossbars of different groups at the same X according
+// to Position.
+//
+// Like LayerBars, X may be continuous or ordinal, and the width of
+// each box is a fraction of the band width.
+type LayerCrossbar struct {
+	// X and Y name columns that define the position of each box
+	// and its middle line. If these are empty, they default to
+	// the first and second columns, respectively.
+	X, Y string
+
+	// YMin and YMax name columns that define the lower and upper
+	// edges of each box. They are required unless Horizontal is
+	// set. The y scale is trained on both edges.
+	YMin, YMax string
+
+	// XMin and XMax replace YMin and YMax when Horizontal is set.
+	XMin, XMax string
+
+	// Color names a column that defines the stroke color of each
+	// box. If Color is "", it defaults to black. Otherwise, the
+	// data is grouped by Color.
+	Color string
+
+	// Fill names a column that defines the fill color of each
+	// box. If Fill is "", boxes are not filled. Otherwise, the
+	// data is grouped by Fill.
+	Fill string
+
+	// Width is the width of each box as a fraction of the band
+	// width. If Width is 0, it defaults to 0.9.
+	Width float64
+
+	// Position adjusts the positions of crossbars from different
+	// groups at the same X. If Position is nil, crossbars are not
+	// adjusted.
+	Position Position
+
+	// Horizontal makes boxes span horizontally from XMin to XMax
+	// at each Y, with a vertical line across each at X.
+	Horizontal bool
+}
+
+func (l LayerCrossbar) Apply(p *Plot) {
+	defaultCols(p, &l.X, &l.Y)
+	pos, mid, lo, hi := l.X, l.Y, l.YMin, l.YMax
+	if l.Horizontal {
+		pos, mid, lo, hi = l.Y, l.X, l.XMin, l.XMax
+	}
+	width := l.Width
+	if width == 0 {
+		width = defaultBandWidth
+	}
+	addInterval(p, "LayerCrossbar", &markInterval{
+		shape:      intervalCrossbar,
+		width:      width,
+		position:   l.Position,
+		horizontal: l.Horizontal,
+	}, pos, mid, lo, hi, l.Color, l.Fill)
+}
+
+// addInterval binds the data of interval mark m and adds it to p.
+// pos names the column giving the position of each interval, mid
+// names the column giving its center or is "", and lo and hi name
+// the columns giving its bounds. Columns along the position axis
+// are bound to "x", unless m is horizontal.
+func addInterval(p *Plot, layer string, m *markInterval, pos, mid, lo, hi, color, fill string) {
+	posAes, valAes := "x", "y"
+	if m.horizontal {
+		posAes, valAes = "y", "x"
+	}
+	if lo == "" || hi == "" {
+		bounds := "YMin and YMax"
+		if m.horizontal {
+			bounds = "XMin and XMax"
+		}
+		panic(fmt.Sprintf("%s requires %s", layer, bounds))
+	}
+	if color != "" {
+		p.GroupBy(color)
+	}
+	if fill != "" {
+		p.GroupBy(fill)
+	}
+
+	// The bounds only label the axis if there's no center.
+	loLabel, hiLabel := lo, hi
+	if mid != "" {
+		loLabel, hiLabel = "", ""
+	}
+	m.pos = p.use(posAes, pos)
+	m.mid = p.use(valAes, mid)
+	m.lo = p.useAs(valAes, lo, loLabel)
+	m.hi = p.useAs(valAes, hi, hiLabel)
+	m.stroke = p.use("stroke", color)
+	m.fill = p.use("fill", fill)
+	p.marks = append(p.marks, plotMark{m, p.Data().Tables()})
+}
+
+// LayerBoxPlot layers a box plot summarizing the distribution of Y
+// at each distinct X. Each box spans the quartiles of Y with a line
+// at the median, whiskers extend from the box to the most extreme
+// values within Whisker times the interquartile range, and values
+// beyond the whiskers are drawn as points. See ggstat.BoxPlot.
+//
+// LayerBoxPlot groups by Fill and Color, and arranges the boxes of
+// different groups at the same X according to Position. Like
+// LayerBars, X may be continuous or ordinal, and the width of each
+// box is a fraction of the band width.
+type LayerBoxPlot struct {
+	// X and Y name columns that define the position of each box
+	// and the values to summarize. If these are empty, they
+	// default to the first and second columns, respectively.
+	X, Y string
+
+	// Fill names a column that defines the fill color of each
+	// box. If Fill is "", boxes are not filled. Otherwise, the
+	// data is grouped by Fill.
+	Fill string
+
+	// Color names a column that defines the stroke color of each
+	// box and its whiskers and outliers. If Color is "", it
+	// defaults to black. Otherwise, the data is grouped by Color.
+	Color string
+
+	// Whisker is the maximum length of each whisker as a multiple
+	// of the interquartile range. If Whisker is 0, it defaults
+	// to 1.5. If Whisker is +Inf, the whiskers extend to the
+	// minimum and maximum values and there are no outliers.
+	Whisker float64
+
+	// Notch indents each box around the median to show an
+	// approximate 95% confidence interval of the median. If the
+	// notches of two boxes don't overlap, this is strong evidence
+	// that their medians differ.
+	Notch bool
+
+	// VarWidth makes the width of each box proportional to the
+	// square root of the number of values it summarizes.
+	VarWidth bool
+
+	// Width is the width of each box as a fraction of the band
+	// width. If Width is 0, it defaults to 0.9. If VarWidth is
+	// set, this is the width of the box with the most values.
+	Width float64
+
+	// Position controls how boxes from different groups at the
+	// same X are arranged. If Position is nil, boxes are placed
+	// side by side with PositionDodge.
+	Position Position
+}
+
+func (l LayerBoxPlot) Apply(p *Plot) {
+	defaultCols(p, &l.X, &l.Y)
+	if l.Fill != "" {
+		p.GroupBy(l.Fill)
+	}
+	if l.Color != "" {
+		p.GroupBy(l.Color)
+	}
+	width := l.Width
+	if width == 0 {
+		width = defaultBandWidth
+	}
+	position := l.Position
+	if position == nil {
+		position = PositionDodge{}
+	}
+
+	defer p.Save().Restore()
+	p.Stat(ggstat.BoxPlot{X: l.X, Y: l.Y, Whisker: l.Whisker})
+	var counts map[table.GroupID][]float64
+	if l.VarWidth {
+		counts = make(map[table.GroupID][]float64)
+		for _, gid := range p.Data().Tables() {
+			counts[gid] = p.Data().Table(gid).MustColumn("count").([]float64)
+		}
+	}
+
+	m := &markBoxPlot{
+		pos:           p.use("x", l.X),
+		lowerWhisker:  p.useAs("y", "lower whisker", ""),
+		lowerQuartile: p.useAs("y", "lower quartile", ""),
+		median:        p.useAs("y", "median", l.Y),
+		upperQuartile: p.useAs("y", "upper quartile", ""),
+		upperWhisker:  p.useAs("y", "upper whisker", ""),
+		outlier:       p.useAs("y", "outlier", ""),
+		fill:          p.use("fill", l.Fill),
+		stroke:        p.use("stroke", l.Color),
+		counts:        counts,
+		width:         width,
+		position:      position,
+		notch:         l.Notch,
+	}
+	if l.Notch {
+		// Only train the Y scale on the notches if they're
+		// drawn, since they can extend past the whiskers.
+		m.lowerNotch = p.useAs("y", "lower notch", "")
+		m.upperNotch = p.useAs("y", "upper notch", "")
+	}
+	p.marks = append(p.marks, plotMark{m, p.Data().Tables()})
+}
+
+// ViolinScale controls how LayerViolin scales the widths of violins.
+type ViolinScale int
+
+const (
+	// ViolinArea makes all violins in a subplot have the same
+	// area.
+	ViolinArea ViolinScale = iota
+
+	// ViolinCount makes the area of each violin proportional to
+	// the number of values it summarizes.
+	ViolinCount
+
+	// ViolinWidth makes all violins have the same maximum width.
+	ViolinWidth
+)
+
+// LayerViolin layers a violin at each distinct X showing the
+// distribution of the values of Y at that X. Each violin is a kernel
+// density estimate of the values, mirrored around the center of the
+// band at X. It groups by Fill and Color, and arranges the violins of
+// different groups at the same X according to Position.
+type LayerViolin struct {
+	// X and Y name columns that define the position of each
+	// violin and the values to summarize. If these are empty,
+	// they default to the first and second columns,
+	// respectively.
+	X, Y string
+
+	// Fill names a column that defines the fill color of each
+	// violin. If Fill is "", violins are not filled. Otherwise,
+	// the data is grouped by Fill.
+	Fill string
+
+	// Color names a column that defines the stroke color of each
+	// violin. If Color is "", it defaults to black. Otherwise,
+	// the data is grouped by Color.
+	Color string
+
+	// Scale controls how the widths of violins are scaled
+	// relative to each other.
+	Scale ViolinScale
+
+	// Quantiles lists quantiles, between 0 and 1, at which to
+	// draw a line across each violin. For example, {0.25, 0.5,
+	// 0.75} marks the quartiles. The quantiles are computed from
+	// the density estimate.
+	Quantiles []float64
+
+	// Bandwidth is the bandwidth of the kernel density estimate.
+	// If Bandwidth is 0, it is computed from the values of each
+	// violin. See ggstat.Density.
+	Bandwidth float64
+
+	// Width is the maximum width of each violin as a fraction of
+	// the band width. If Width is 0, it defaults to 0.9.
+	Width float64
+
+	// Position controls how violins from different groups at the
+	// same X are arranged. If Position is nil, violins are placed
+	// side by side with PositionDodge.
+	Position Position
+}
+
+func (l LayerViolin) Apply(p *Plot) {
+	defaultCols(p, &l.X, &l.Y)
+	if l.Fill != "" {
+		p.GroupBy(l.Fill)
+	}
+	if l.Color != "" {
+		p.GroupBy(l.Color)
+	}
+	width := l.Width
+	if width == 0 {
+		width = defaultBandWidth
+	}
+	position := l.Position
+	if position == nil {
+		position = PositionDodge{}
+	}
+
+	defer p.Save().Restore()
+	m := &markViolin{
+		density:   make(map[table.GroupID][]float64),
+		cdf:       make(map[table.GroupID][]float64),
+		count:     make(map[table.GroupID][]float64),
+		violin:    make(map[table.GroupID][]int),
+		scale:     l.Scale,
+		quantiles: l.Quantiles,
+		width:     width,
+		position:  position,
+	}
+
+	// Estimate the density at each X, and then ungroup X so
+	// position adjustments apply to each group's violins
+	// together. Record which violin each row belongs to and how
+	// many values it summarizes.
+	p.GroupBy(l.X)
+	counts := make(map[table.GroupID]int)
+	for _, gid := range p.Data().Tables() {
+		counts[gid] = p.Data().Table(gid).Len()
+	}
+	p.Stat(ggstat.Density{
+		X:         l.Y,
+		Domain:    ggstat.DomainData{Widen: 1, SplitGroups: true},
+		Bandwidth: l.Bandwidth,
+	})
+	violins := make(map[table.GroupID]int)
+	for _, gid := range p.Data().Tables() {
+		parent, n := gid.Parent(), p.Data().Table(gid).Len()
+		for i := 0; i < n; i++ {
+			m.violin[parent] = append(m.violin[parent], violins[parent])
+			m.count[parent] = append(m.count[parent], float64(counts[gid]))
+		}
+		violins[parent]++
+	}
+	p.SetData(table.Ungroup(p.Data()))
+	for _, gid := range p.Data().Tables() {
+		t := p.Data().Table(gid)
+		m.density[gid] = t.MustColumn("probability density").([]float64)
+		m.cdf[gid] = t.MustColumn("cumulative density").([]float64)
+	}
+
+	m.pos = p.use("x", l.X)
+	m.y = p.use("y", l.Y)
+	m.fill = p.use("fill", l.Fill)
+	m.stroke = p.use("stroke", l.Color)
+	p.marks = append(p.marks, plotMark{m, p.Data().Tables()})
+}
+
+// LayerHLine layers a horizontal line across the full width of each
+// subplot at each Y value. This is useful for marking reference
+// values, such as a target or a baseline.
+type LayerHLine struct {
+	// Y names a column that defines the y value of each line.
+	Y string
+
+	// YValues is a slice of constant y values of lines, such as
+	// a []float64 or a []time.Duration. The values are mapped by
+	// the y scale like any other data, so they must have a type
+	// the scale accepts. If YValues is non-nil, it is used
+	// instead of Y and the lines are drawn in every subplot.
+	YValues table.Slice
+
+	// Color names a column that defines the color of each line.
+	// If Color is "", it defaults to black. Otherwise, the data
+	// is grouped by Color.
+	Color string
+
+	// NoTrain leaves the y scale's domain unchanged, so lines
+	// outside the range of the other layers' data are not
+	// visible.
+	NoTrain bool
+}
+
+func (l LayerHLine) Apply(p *Plot) {
+	addRules(p, "y", l.Y, l.YValues, l.Color, l.NoTrain)
+}
+
+// LayerVLine layers a vertical line across the full height of each
+// subplot at each X value.
+type LayerVLine struct {
+	// X names a column that defines the x value of each line.
+	X string
+
+	// XValues is a slice of constant x values of lines, such as
+	// a []float64 or a []time.Time. The values are mapped by the
+	// x scale like any other data, so they must have a type the
+	// scale accepts. If XValues is non-nil, it is used instead of
+	// X and the lines are drawn in every subplot.
+	XValues table.Slice
+
+	// Color names a column that defines the color of each line.
+	// If Color is "", it defaults to black. Otherwise, the data
+	// is grouped by Color.
+	Color string
+
+	// NoTrain leaves the x scale's domain unchanged, so lines
+	// outside the range of the other layers' data are not
+	// visible.
+	NoTrain bool
+}
+
+func (l LayerVLine) Apply(p *Plot) {
+	addRules(p, "x", l.X, l.XValues, l.Color, l.NoTrain)
+}
+
+// addRules adds a layer of lines across each subplot at each value
+// in column col, or at each of values if it is non-nil, along the
+// axis aesthetic aes.
+func addRules(p *Plot, aes, col string, values table.Slice, color string, noTrain bool) {
+	if color != "" {
+		p.GroupBy(color)
+	}
+	defer p.Save().Restore()
+	if values != nil {
+		col = constRows(p, values)[0]
+	}
+	if col == "" {
+		panic(fmt.Sprintf("reference line requires a column or values for %q", aes))
+	}
+
+	m := &markRule{vertical: aes == "x"}
+	if noTrain {
+		m.at = p.useUntrained(aes, col)
+	} else if values != nil {
+		m.at = p.useAs(aes, col, "")
+	} else {
+		m.at = p.use(aes, col)
+	}
+	m.stroke = p.use("stroke", color)
+	p.marks = append(p.marks, plotMark{m, p.Data().Tables()})
+}
+
+// LayerABLine layers a line with the given slope and intercept
+// across the full width of each subplot. It doesn't train the
+// scales, and requires linear x and y scales. In subplots with other
+// x or y scales, such as log or time scales, it draws nothing and
+// logs a warning.
+type LayerABLine struct {
+	// Slope and Intercept name columns that define the slope and
+	// y intercept of each line.
+	Slope, Intercept string
+
+	// SlopeValues and InterceptValues give the constant slopes
+	// and intercepts of lines. They must have the same length.
+	// If they are non-nil, they are used instead of Slope and
+	// Intercept and the lines are drawn in every subplot.
+	SlopeValues, InterceptValues []float64
+
+	// Color names a column that defines the color of each line.
+	// If Color is "", it defaults to black. Otherwise, the data
+	// is grouped by Color.
+	Color string
+}
+
+func (l LayerABLine) Apply(p *Plot) {
+	if l.Color != "" {
+		p.GroupBy(l.Color)
+	}
+	defer p.Save().Restore()
+	slope, intercept := l.Slope, l.Intercept
+	if l.SlopeValues != nil || l.InterceptValues != nil {
+		if len(l.SlopeValues) != len(l.InterceptValues) {
+			panic("LayerABLine requires the same number of SlopeValues and InterceptValues")
+		}
+		cols := constRows(p, l.SlopeValues, l.InterceptValues)
+		slope, intercept = cols[0], cols[1]
+	}
+	if slope == "" || intercept == "" {
+		panic("LayerABLine requires Slope and Intercept")
+	}
+
+	// Represent each line by its points at x=0 and x=1. With
+	// linear scales, these determine the line in the plot.
+	x0, x1 := p.tempCol("abline-x0"), p.tempCol("abline-x1")
+	y1 := p.tempCol("abline-y1")
+	p.SetData(table.MapTables(p.Data(), func(_ table.GroupID, t *table.Table) *table.Table {
+		var ss, is []float64
+		slice.Convert(&ss, t.MustColumn(slope))
+		slice.Convert(&is, t.MustColumn(intercept))
+		y1s := make([]float64, len(ss))
+		for i := range ss {
+			y1s[i] = is[i] + ss[i]
+		}
+		return table.NewBuilder(t).AddConst(x0, 0.0).AddConst(x1, 1.0).Add(y1, y1s).Done()
+	}))
+
+	p.marks = append(p.marks, plotMark{&markABLine{
+		x0:     p.useUntrained("x", x0),
+		y0:     p.useUntrained("y", intercept),
+		x1:     p.useUntrained("x", x1),
+		y1:     p.useUntrained("y", y1),
+		stroke: p.use("stroke", l.Color),
+	}, p.Data().Tables()})
+}
+
+// constRows replaces each table in p's data with a table that has a
+// row for each index of values and the constant columns of the
+// original table. It returns the names of the columns holding each
+// slice of values, which must all have the same length.
+func constRows(p *Plot, values ...table.Slice) []string {
+	cols := make([]string, len(values))
+	for i := range values {
+		cols[i] = p.tempCol("values")
+	}
+	p.SetData(table.MapTables(p.Data(), func(_ table.GroupID, t *table.Table) *table.Table {
+		b := new(table.Builder)
+		for i, col := range cols {
+			b.Add(col, values[i])
+		}
+		for _, c := range t.Columns() {
+			if cv, ok := t.Const(c); ok {
+				b.AddConst(c, cv)
+			}
+		}
+		return b.Done()
+	}))
+	return cols
+}
+
 // LayerPoints layers a point mark at each data point.
 type LayerPoints struct {
 	// X and Y name columns that define input and response of each
@@ -202,10 +968,18 @@ type LayerPoints struct {
 	// second columns, respectively.
 	X, Y string
 
-	// Color names the column that defines the fill color of each
-	// point. If Color is "", it defaults to constant black.
+	// Color names the column that defines the color of each
+	// point. Solid shapes are filled with this color and open
+	// shapes are outlined with it. If Color is "", it defaults
+	// to constant black.
 	Color string
 
+	// Fill names the column that defines the color to fill the
+	// inside of open shapes, such as ShapeCircleOpen, with. If
+	// Fill is "", open shapes are not filled. Fill doesn't
+	// affect solid shapes.
+	Fill string
+
 	// Opacity names the column that defines the opacity of each
 	// point. If Opacity is "", it defaults to fully opaque. This
 	// is multiplied by any alpha value specified by Color.
@@ -216,14 +990,26 @@ type LayerPoints struct {
 	// dimension.
 	Size string
 
-	// XXX fill vs stroke, shape
+	// Shape names the column that defines the shape of each
+	// point. If Shape is "", points are circles. Columns of Shape
+	// values are used as is; other columns are mapped to shapes
+	// by a discrete scale.
+	Shape string
+
+	// Position adjusts the positions of points. For example,
+	// PositionJitter reduces overplotting and PositionDodge
+	// places points from different groups side by side. If
+	// Position is nil, points are not adjusted.
+	Position Position
 }
 
 func (l LayerPoints) Apply(p *Plot) {
 	defaultCols(p, &l.X, &l.Y)
+	defer p.Save().Restore()
+	y := stackPosition(p, l.Position, l.X, l.Y)
 	p.marks = append(p.marks, plotMark{&markPoint{
 		p.use("x", l.X),
-		p.use("y", l.Y),
+		p.useAs("y", y, l.Y),
 		// TODO: It's actually the fill color, but I generally
 		// want it to match things that are stroke colors.
 		// Maybe I should have a "color" aesthetic for the
@@ -236,11 +1022,18 @@ func (l LayerPoints) Apply(p *Plot) {
 		// specific opacities? What's the physical type?
 		p.use("opacity", l.Opacity),
 		p.use("size", l.Size),
+		p.use("fill", l.Fill),
+		p.use("shape", l.Shape),
+		l.Position,
 	}, p.Data().Tables()})
 }
 
 // LayerTiles layers a rectangle at each data point. The rectangle is
 // specified by its center, width, and height.
+//
+// X and Y may be continuous or ordinal. Tiles on a regular grid
+// without a stroke are drawn as a single image, which keeps large
+// heatmaps compact. Otherwise, each tile is drawn as a rectangle.
 type LayerTiles struct {
 	// X and Y name columns that define the input and response at
 	// the center of each rectangle. If they are "", they default
@@ -248,36 +1041,377 @@ type LayerTiles struct {
 	X, Y string
 
 	// Width and Height name columns that define the width and
-	// height of each rectangle. If they are "", the width and/or
-	// height are automatically determined from the smallest
-	// spacing between distinct X and Y points.
+	// height of each rectangle in the units of X and Y, which
+	// must be numeric if Width or Height, respectively, is
+	// given. The scales are trained on the edges of the
+	// rectangles, and scale transforms apply to the edges, so a
+	// tile may not be centered on X and Y after a non-linear
+	// transform.
+	//
+	// If Width or Height is "", the width or height of the tiles
+	// is determined from the spacing between distinct X or Y
+	// values: each tile extends halfway to the adjacent tiles.
+	// This handles both regular and irregular grids.
 	Width, Height string
 
 	// Fill names a column that defines the fill color of each
 	// rectangle. If it is "", the default fill is black.
 	Fill string
 
-	// XXX Stroke color/width, opacity, center adjustment.
+	// Color names a column that defines the stroke color of each
+	// rectangle. If it is "", rectangles are not stroked.
+	Color string
 }
 
 func (l LayerTiles) Apply(p *Plot) {
 	defaultCols(p, &l.X, &l.Y)
-	if l.Width != "" || l.Height != "" {
-		// TODO: What scale are these in? (x+width) is in the
-		// X scale, but width itself is not. It doesn't make
-		// sense to train the X scale on width, and if there's
-		// a scale transform, (x+width) has to happen before
-		// the transform. OTOH, if x is discrete, I can't do
-		// (x+width); maybe in that case you just can't
-		// specify a width. OTOOH, if width is specified and
-		// the value is unscaled, I could still do something
-		// reasonable with that if x is discrete.
-		panic("not implemented: non-default width/height")
-	}
-	p.marks = append(p.marks, plotMark{&markTiles{
-		p.use("x", l.X),
-		p.use("y", l.Y),
-		p.use("fill", l.Fill),
+	defer p.Save().Restore()
+	m := &markTiles{
+		x:      p.use("x", l.X),
+		y:      p.use("y", l.Y),
+		fill:   p.use("fill", l.Fill),
+		stroke: p.use("stroke", l.Color),
+	}
+	if l.Width != "" {
+		lo, hi := tileEdges(p, l.X, l.Width)
+		m.xlo, m.xhi = p.useAs("x", lo, ""), p.useAs("x", hi, "")
+	}
+	if l.Height != "" {
+		lo, hi := tileEdges(p, l.Y, l.Height)
+		m.ylo, m.yhi = p.useAs("y", lo, ""), p.useAs("y", hi, "")
+	}
+	p.marks = append(p.marks, plotMark{m, p.Data().Tables()})
+}
+
+// tileEdges adds columns to p's data giving the lower and upper edges
+// of tiles centered at each value of column center with the size in
+// column size, and returns their names.
+func tileEdges(p *Plot, center, size string) (lo, hi string) {
+	lo, hi = p.tempCol("tile-lo"), p.tempCol("tile-hi")
+	p.SetData(table.MapTables(p.Data(), func(_ table.GroupID, t *table.Table) *table.Table {
+		var cs, ss []float64
+		slice.Convert(&cs, t.MustColumn(center))
+		slice.Convert(&ss, t.MustColumn(size))
+		los, his := make([]float64, len(cs)), make([]float64, len(cs))
+		for i, c := range cs {
+			los[i], his[i] = c-ss[i]/2, c+ss[i]/2
+		}
+		return table.NewBuilder(t).Add(lo, los).Add(hi, his).Done()
+	}))
+	return lo, hi
+}
+
+// LayerRects layers a rectangle at each data point. The rectangle is
+// specified by its edges. This is useful for shading ranges, such as
+// time windows.
+type LayerRects struct {
+	// XMin, XMax, YMin, and YMax name columns that define the
+	// edges of each rectangle. If any of these is "", that edge
+	// extends to the edge of the subplot. At least one must be
+	// given.
+	XMin, XMax, YMin, YMax string
+
+	// Fill names a column that defines the fill color of each
+	// rectangle. If Fill is "", it defaults to black.
+	Fill string
+
+	// FillOpacity names a column that defines the fill opacity
+	// of each rectangle. If FillOpacity is "", it defaults to
+	// 0.5.
+	FillOpacity string
+
+	// Color names a column that defines the stroke color of each
+	// rectangle. If Color is "", rectangles are not stroked.
+	Color string
+}
+
+func (l LayerRects) Apply(p *Plot) {
+	if l.XMin == "" && l.XMax == "" && l.YMin == "" && l.YMax == "" {
+		panic("LayerRects requires at least one of XMin, XMax, YMin, or YMax")
+	}
+	defer p.Save().Restore()
+	p.marks = append(p.marks, plotMark{&markRects{
+		xmin:        p.use("x", l.XMin),
+		xmax:        p.use("x", l.XMax),
+		ymin:        p.use("y", l.YMin),
+		ymax:        p.use("y", l.YMax),
+		fill:        p.use("fill", l.Fill),
+		fillOpacity: p.use("opacity", l.FillOpacity),
+		stroke:      p.use("stroke", l.Color),
+	}, p.Data().Tables()})
+}
+
+// ArrowMode controls which ends of a segment have an arrowhead.
+type ArrowMode int
+
+const (
+	// ArrowNone draws segments without arrowheads.
+	ArrowNone ArrowMode = iota
+
+	// ArrowEnd draws an arrowhead at the end point of each
+	// segment, pointing away from the start point.
+	ArrowEnd
+
+	// ArrowStart draws an arrowhead at the start point of each
+	// segment, pointing away from the end point.
+	ArrowStart
+
+	// ArrowBoth draws arrowheads at both ends of each segment.
+	ArrowBoth
+)
+
+// LayerSegments layers a straight line segment from (X, Y) to (XEnd,
+// YEnd) for each data point. This is useful for connecting pairs of
+// points, such as before and after measurements.
+type LayerSegments struct {
+	// X and Y name columns that define the start point of each
+	// segment. If these are empty, they default to the first
+	// and second columns, respectively.
+	X, Y string
+
+	// XEnd and YEnd name columns that define the end point of
+	// each segment. If either is "", it defaults to X or Y,
+	// respectively, giving vertical or horizontal segments.
+	XEnd, YEnd string
+
+	// Color names a column that defines the stroke color of each
+	// segment. If Color is "", it defaults to black.
+	Color string
+
+	// Arrow controls which ends of each segment have an
+	// arrowhead.
+	Arrow ArrowMode
+}
+
+func (l LayerSegments) Apply(p *Plot) {
+	defaultCols(p, &l.X, &l.Y)
+	defer p.Save().Restore()
+	xEnd, yEnd := l.XEnd, l.YEnd
+	if xEnd == "" {
+		xEnd = l.X
+	}
+	if yEnd == "" {
+		yEnd = l.Y
+	}
+	p.marks = append(p.marks, plotMark{&markSegments{
+		x0:     p.use("x", l.X),
+		y0:     p.use("y", l.Y),
+		x1:     p.use("x", xEnd),
+		y1:     p.use("y", yEnd),
+		stroke: p.use("stroke", l.Color),
+		arrow:  l.Arrow,
+	}, p.Data().Tables()})
+}
+
+// LayerContour layers the contours of a function sampled on a grid,
+// such as the data of a heatmap. The contours are colored by their
+// level. See ggstat.Contour for how contours are computed.
+type LayerContour struct {
+	// X, Y, and Z name columns that define the grid coordinates
+	// and the value of the function at each grid point. If these
+	// are empty, they default to the first, second, and third
+	// columns, respectively.
+	X, Y, Z string
+
+	// Levels gives the values of Z at which to draw contours. If
+	// Levels is nil, about N levels are chosen at "nice" values
+	// spanning the range of Z.
+	Levels []float64
+
+	// N is the approximate number of levels to choose if Levels
+	// is nil. If N is 0, it defaults to 10.
+	N int
+
+	// Filled fills the regions between adjacent levels, colored
+	// by the lower level, rather than drawing lines at each
+	// level.
+	Filled bool
+}
+
+func (l LayerContour) Apply(p *Plot) {
+	defaultCols(p, &l.X, &l.Y, &l.Z)
+	defer p.Save().Restore()
+	p.Stat(ggstat.Contour{X: l.X, Y: l.Y, Z: l.Z, Levels: l.Levels, N: l.N, Filled: l.Filled})
+
+	// Draw each level as a single path, separating the lines or
+	// polygons with NaNs. This way the polygons of a filled
+	// region don't leave seams between them.
+	p.GroupBy("level")
+	p.SetData(table.MapTables(p.Data(), func(_ table.GroupID, t *table.Table) *table.Table {
+		var xs, ys []float64
+		slice.Convert(&xs, t.MustColumn(l.X))
+		slice.Convert(&ys, t.MustColumn(l.Y))
+		paths := t.MustColumn("path").([]int)
+		var nxs, nys []float64
+		for i := range xs {
+			if i > 0 && paths[i] != paths[i-1] {
+				nxs, nys = append(nxs, math.NaN()), append(nys, math.NaN())
+			}
+			nxs, nys = append(nxs, xs[i]), append(nys, ys[i])
+		}
+		b := new(table.Builder).Add(l.X, nxs).Add(l.Y, nys)
+		for _, c := range t.Columns() {
+			if cv, ok := t.Const(c); ok {
+				b.AddConst(c, cv)
+			}
+		}
+		return b.Done()
+	}))
+
+	aes := "stroke"
+	if l.Filled {
+		aes = "fill"
+	}
+	p.marks = append(p.marks, plotMark{&markContour{
+		x:      p.use("x", l.X),
+		y:      p.use("y", l.Y),
+		level:  p.use(aes, "level"),
+		filled: l.Filled,
+	}, p.Data().Tables()})
+}
+
+// LayerHex bins points into hexagons and layers a hexagon for each
+// non-empty bin, filled according to the number of points in the bin.
+// This is useful for showing the density of scatterplots with too
+// many points to draw individually. See ggstat.HexBin for how points
+// are binned.
+//
+// The hexagons are sized so they tile the plot without gaps after
+// the X and Y scales are applied, which requires linear scales.
+type LayerHex struct {
+	// X and Y name columns that define the points to bin. If
+	// these are empty, they default to the first and second
+	// columns, respectively.
+	X, Y string
+
+	// W names a column that defines the weight of each point. If
+	// W is "", each point has weight 1. Hexagons are filled
+	// according to the sum of the weights of their points.
+	W string
+
+	// Bins is the number of hexagons across the range of X. If
+	// Bins is 0, it defaults to 30.
+	Bins int
+
+	// Color names a column that defines the stroke color of each
+	// hexagon. If Color is "", hexagons are not stroked.
+	Color string
+}
+
+func (l LayerHex) Apply(p *Plot) {
+	defaultCols(p, &l.X, &l.Y)
+	defer p.Save().Restore()
+	p.Stat(ggstat.HexBin{X: l.X, Y: l.Y, W: l.W, Bins: l.Bins})
+	weight := l.W
+	if weight == "" {
+		weight = "count"
+	}
+
+	// Compute the bounding box of each hexagon. Hexagons have
+	// vertical sides, so they are as wide as the distance between
+	// centers and extend 2/3 of the way to the adjacent rows.
+	left, right := p.tempCol("hex-left"), p.tempCol("hex-right")
+	bottom, top := p.tempCol("hex-bottom"), p.tempCol("hex-top")
+	p.SetData(table.MapTables(p.Data(), func(_ table.GroupID, t *table.Table) *table.Table {
+		var xs, ys []float64
+		slice.Convert(&xs, t.MustColumn(l.X))
+		slice.Convert(&ys, t.MustColumn(l.Y))
+		w, _ := t.Const("width")
+		h, _ := t.Const("height")
+		hw, r := w.(float64)/2, h.(float64)*2/3
+		ls, rs := make([]float64, len(xs)), make([]float64, len(xs))
+		bs, ts := make([]float64, len(xs)), make([]float64, len(xs))
+		for i := range xs {
+			ls[i], rs[i] = xs[i]-hw, xs[i]+hw
+			bs[i], ts[i] = ys[i]-r, ys[i]+r
+		}
+		return table.NewBuilder(t).Add(left, ls).Add(right, rs).Add(bottom, bs).Add(top, ts).Done()
+	}))
+
+	p.marks = append(p.marks, plotMark{&markHex{
+		x:      p.use("x", l.X),
+		y:      p.use("y", l.Y),
+		left:   p.useAs("x", left, ""),
+		right:  p.useAs("x", right, ""),
+		bottom: p.useAs("y", bottom, ""),
+		top:    p.useAs("y", top, ""),
+		fill:   p.use("fill", weight),
+		stroke: p.use("stroke", l.Color),
+	}, p.Data().Tables()})
+}
+
+// LayerText layers a text label at each data point. Unlike
+// LayerTags, which attaches one tag to each group, LayerText labels
+// every row.
+type LayerText struct {
+	// X and Y name columns that define the position of each
+	// label. If these are empty, they default to the first and
+	// second columns, respectively.
+	X, Y string
+
+	// Label names the column that gives the text of each label.
+	// Label is required.
+	Label string
+
+	// Color names the column that defines the color of each
+	// label. If Color is "", it defaults to black.
+	Color string
+
+	// Size names the column that defines the size of each label.
+	// Sizes are scaled like the sizes of LayerPoints, so a label
+	// is about as tall as a point of the same size is wide. If
+	// Size is "", labels use the theme's font size.
+	Size string
+
+	// Rotate names the column that gives the clockwise rotation
+	// of each label about its position, in degrees. If Rotate is
+	// "", labels are not rotated. Like the columns below, its
+	// values are used directly rather than scaled.
+	Rotate string
+
+	// HJust and VJust name the columns that justify each label
+	// relative to its position, before rotation. An HJust of -1,
+	// 0, or 1 aligns the left edge, center, or right edge of the
+	// label with the position, and a VJust of -1, 0, or 1 aligns
+	// the bottom, middle, or top of the label with the position.
+	// Values in between interpolate. If either is "", it
+	// defaults to a constant 0, so labels are centered.
+	HJust, VJust string
+
+	// NudgeX and NudgeY name the columns that move each label
+	// right and up by a number of pixels. This is useful for
+	// offsetting labels from points at the same position. If
+	// either is "", it defaults to a constant 0.
+	NudgeX, NudgeY string
+
+	// CheckOverlap drops labels that would overlap a label drawn
+	// before them in the same subplot. Labels are drawn in row
+	// order, so earlier rows take precedence.
+	CheckOverlap bool
+}
+
+func (l LayerText) Apply(p *Plot) {
+	defaultCols(p, &l.X, &l.Y)
+	if l.Label == "" {
+		panic("LayerText requires Label")
+	}
+	labels := make(map[table.GroupID]table.Slice)
+	for _, gid := range p.Data().Tables() {
+		labels[gid] = p.Data().Table(gid).MustColumn(l.Label)
+	}
+
+	p.marks = append(p.marks, plotMark{&markText{
+		x:            p.use("x", l.X),
+		y:            p.use("y", l.Y),
+		color:        p.use("stroke", l.Color),
+		size:         p.use("size", l.Size),
+		labels:       labels,
+		rotate:       p.use("angle", l.Rotate),
+		hjust:        p.use("hjust", l.HJust),
+		vjust:        p.use("vjust", l.VJust),
+		nudgeX:       p.use("nudgex", l.NudgeX),
+		nudgeY:       p.use("nudgey", l.NudgeY),
+		checkOverlap: l.CheckOverlap,
 	}, p.Data().Tables()})
 }
 
@@ -302,9 +1436,12 @@ type LayerTags struct {
 	// curve.
 	HPos float64
 
-	// Offset controls the pixel offset of the tag from the point
-	// it is attached to. If these are both zero, they are treated
-	// as -20, -20.
+	// Offset controls the preferred pixel offset of the tag from
+	// the point it is attached to. If these are both zero, they
+	// are treated as -20, -20. Tags are moved from their
+	// preferred positions as needed to keep them within the plot
+	// area and avoid overlapping each other and the data points
+	// of this layer.
 	OffsetX, OffsetY int
 }
 
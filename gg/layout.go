@@ -5,13 +5,13 @@
 package gg
 
 import (
-	"fmt"
+	"image/color"
 	"math"
+	"reflect"
 	"sort"
 
 	"github.com/aclements/go-gg/gg/layout"
 	"github.com/aclements/go-gg/table"
-	"github.com/ajstarks/svgo"
 )
 
 // A plotElt is a high-level element of a plot layout.
@@ -34,16 +34,19 @@ import (
 //                           | Label (x, y/-3/0)    |
 //                           +----------------------+
 //                           | Padding (x, y/-2)    |
-//    +-----------+----------+----------------------+----------+------------+
-//    | Padding   | YTicks   |                      | Padding  | Label      |
-//    | (x/-2, y) | (x/-1,y) | Subplot (x, y)       | (x/2, y) | (x/3/0, y) |
-//    |           |          |                      |          |            |
-//    +-----------+----------+----------------------+----------+------------+
+//    +-----------+----------+----------------------+----------+------------+----------+
+//    | Padding   | YTicks   |                      | Padding  | Label      | Legend   |
+//    | (x/-2, y) | (x/-1,y) | Subplot (x, y)       | (x/2, y) | (x/3/0, y) | (x/4, y) |
+//    |           |          |                      |          |            |          |
+//    +-----------+----------+----------------------+----------+------------+----------+
 //                           | XTicks (x, y/1)      |
 //                           +----------------------+
 //                           | Padding (x, y/2)     |
 //                           +----------------------+
 //
+// The legend is placed to the right of the right-most column of
+// subplots and spans all rows of subplots.
+//
 // TODO: Should I instead think of this as specifying the edges rather
 // than the cells?
 type plotElt interface {
@@ -54,30 +57,32 @@ type plotElt interface {
 	// are the same as xPath and yPath.
 	paths() (xPath, yPath, x2Path, y2Path eltPath)
 
-	// render draws this plot element to r.svg.
+	// setTheme sets the theme used to lay out and render this
+	// plot element.
+	setTheme(t *Theme)
+
+	// render draws this plot element to r.canvas.
 	render(r *eltRender)
 }
 
 type eltRender struct {
-	svg *svg.SVG
-	id  int
-}
-
-func (r *eltRender) genid(prefix string) (id, ref string) {
-	id = fmt.Sprintf("%s%d", prefix, r.id)
-	ref = "url(#" + id + ")"
-	r.id++
-	return
+	canvas Canvas
 }
 
 type eltCommon struct {
 	xPath, yPath, x2Path, y2Path eltPath
+
+	theme *Theme
 }
 
 func (c *eltCommon) paths() (xPath, yPath, x2Path, y2Path eltPath) {
 	return c.xPath, c.yPath, c.x2Path, c.y2Path
 }
 
+func (c *eltCommon) setTheme(t *Theme) {
+	c.theme = t
+}
+
 type eltSubplot struct {
 	eltCommon
 	layout.Leaf
@@ -108,7 +113,7 @@ func (e *eltSubplot) SizeHint() (w, h float64, flexw, flexh bool) {
 func (e *eltSubplot) SetLayout(x, y, w, h float64) {
 	e.Leaf.SetLayout(x, y, w, h)
 	m := &e.plotMargins
-	m.t, m.r, m.b, m.l = plotMargins(w, h)
+	m.t, m.r, m.b, m.l = e.theme.PlotMargins(w, h)
 }
 
 type eltTicks struct {
@@ -178,7 +183,7 @@ func (e *eltTicks) mapTicks(s Scaler, ticks table.Slice) (pixels []float64) {
 // element e based on the dimensions of e.ticksFor (which must have
 // been laid out prior to calling this).
 func (e *eltTicks) computeTicks() {
-	const tickDistance = 30 // TODO: Theme. Min pixels between tick labels.
+	tickDistance := e.theme.TickLabelSpacing
 
 	_, _, w, h := e.ticksFor.Layout()
 	var dim float64
@@ -210,7 +215,7 @@ func (e *eltTicks) computeTicks() {
 					// Labels i-1 and i are too close.
 					return false
 				}
-				metrics := measureString(fontSize, labels[i])
+				metrics := e.theme.measure(e.theme.TickLabel, labels[i])
 				switch e.axis {
 				case 'x':
 					last = p + metrics.width
@@ -222,10 +227,42 @@ func (e *eltTicks) computeTicks() {
 			return true
 		}
 		major, minor, labels := s.Ticks(maxTicks, pred)
+		// User-specified ticks may be outside the scale's
+		// domain. Drop these rather than drawing them outside
+		// the plot.
+		major, labels = e.clipTicks(s, major, labels)
+		minor, _ = e.clipTicks(s, minor, nil)
 		e.ticks[s] = plotEltTicks{major, minor, labels}
 	}
 }
 
+// clipTicks returns the subset of ticks (and their labels, if labels
+// is non-nil) that fall within the subplot along e's axis.
+func (e *eltTicks) clipTicks(s Scaler, ticks table.Slice, labels []string) (table.Slice, []string) {
+	if ticks == nil {
+		return nil, labels
+	}
+	x, y, w, h := e.Layout()
+	lo, hi := x, x+w
+	if e.axis == 'y' {
+		lo, hi = y, y+h
+	}
+	tv := reflect.ValueOf(ticks)
+	outv := reflect.MakeSlice(tv.Type(), 0, tv.Len())
+	var outLabels []string
+	for i, p := range e.mapTicks(s, ticks) {
+		// Allow for rounding error at the edges.
+		if p < lo-0.5 || p > hi+0.5 {
+			continue
+		}
+		outv = reflect.Append(outv, tv.Index(i))
+		if labels != nil {
+			outLabels = append(outLabels, labels[i])
+		}
+	}
+	return outv.Interface(), outLabels
+}
+
 func (e *eltTicks) SizeHint() (w, h float64, flexw, flexh bool) {
 	if len(e.ticks) == 0 {
 		// Ticks haven't been computed yet or there are none.
@@ -243,16 +280,16 @@ func (e *eltTicks) SizeHint() (w, h float64, flexw, flexh bool) {
 	var maxWidth, maxHeight float64
 	for s := range e.scales() {
 		for _, label := range e.ticks[s].labels {
-			metrics := measureString(fontSize, label)
+			metrics := e.theme.measure(e.theme.TickLabel, label)
 			maxHeight = math.Max(maxHeight, metrics.leading)
 			maxWidth = math.Max(maxWidth, metrics.width)
 		}
 	}
 	switch e.axis {
 	case 'x':
-		maxHeight += xTickSep
+		maxHeight += e.theme.TickLabelSep
 	case 'y':
-		maxWidth += yTickSep
+		maxWidth += e.theme.TickLabelSep
 	}
 	return maxWidth, maxHeight, e.axis == 'x', e.axis == 'y'
 }
@@ -261,16 +298,14 @@ type eltLabel struct {
 	eltCommon
 	layout.Leaf
 
-	side  rune // 't', 'b', 'l', 'r'
+	side  rune // 't', 'b', 'l', 'r', or 'T' for the title
 	label string
-	fill  string
 }
 
 func newEltLabelFacet(side rune, label string, x1, y1, x2, y2 int, level int) *eltLabel {
 	elt := &eltLabel{
 		side:  side,
 		label: label,
-		fill:  "#ccc", // TODO: Theme.
 	}
 	switch side {
 	case 't':
@@ -296,7 +331,6 @@ func newEltLabelAxis(side rune, label string, x, y, span int) *eltLabel {
 		eltCommon: eltCommon{xPath: eltPath{x}, yPath: eltPath{y}},
 		side:      side,
 		label:     label,
-		fill:      "none",
 	}
 	switch side {
 	case 'T', 'b':
@@ -309,10 +343,22 @@ func newEltLabelAxis(side rune, label string, x, y, span int) *eltLabel {
 	return elt
 }
 
+// style returns the background color and text theme of label e.
+func (e *eltLabel) style() (bg color.Color, text TextTheme) {
+	switch e.side {
+	case 't', 'r': // Facet labels
+		return e.theme.StripBackground, e.theme.StripText
+	case 'T':
+		return nil, e.theme.Title
+	}
+	return nil, e.theme.AxisTitle
+}
+
 func (e *eltLabel) SizeHint() (w, h float64, flexw, flexh bool) {
 	// TODO: We actually want the height of the text, which could
 	// be N*leading if there are multiple lines.
-	dim := measureString(fontSize, e.label).leading * facetLabelHeight
+	_, text := e.style()
+	dim := e.theme.measure(text, e.label).leading * e.theme.LabelHeight
 	switch e.side {
 	case 't', 'b':
 		return 0, dim, true, false
@@ -325,6 +371,110 @@ func (e *eltLabel) SizeHint() (w, h float64, flexw, flexh bool) {
 	}
 }
 
+type eltLegend struct {
+	eltCommon
+	layout.Leaf
+
+	guides []*legendGuide
+
+	// subplots are the subplots explained by this legend. These
+	// are used to size point keys the same way marks size
+	// points.
+	subplots []*eltSubplot
+}
+
+func newEltLegend(guides []*legendGuide, x, y1, y2 int) *eltLegend {
+	return &eltLegend{
+		eltCommon: eltCommon{
+			xPath:  eltPath{x, 4},
+			yPath:  eltPath{y1},
+			y2Path: eltPath{y2},
+		},
+		guides: guides,
+	}
+}
+
+// pointScale returns the pixel dimension that point sizes are
+// relative to. See markPoint.
+func (e *eltLegend) pointScale() float64 {
+	dim := math.Inf(1)
+	for _, s := range e.subplots {
+		_, _, w, h := s.Layout()
+		dim = math.Min(dim, math.Min(w, h))
+	}
+	if math.IsInf(dim, 1) {
+		return 0
+	}
+	return dim
+}
+
+// keySize returns the dimensions of each key in guide g.
+func (e *eltLegend) keySize(g *legendGuide) (w, h float64) {
+	w, h = e.theme.LegendKeySize, e.theme.LegendKeySize
+	if g.glyph&(legendGlyphPoint|legendGlyphText) != 0 {
+		scale := e.pointScale()
+		for _, key := range g.keys {
+			size := 0.01
+			if !math.IsNaN(key.size) {
+				size = key.size
+			}
+			w = math.Max(w, 2*size*scale+2)
+		}
+		h = w
+	}
+	if g.glyph&legendGlyphLine != 0 {
+		// Make keys wide enough to show dash patterns.
+		for _, key := range g.keys {
+			if key.linetype != LineSolid {
+				w = math.Max(w, 2*e.theme.LegendKeySize)
+				break
+			}
+		}
+	}
+	return
+}
+
+// guideSize returns the dimensions of guide g.
+func (e *eltLegend) guideSize(g *legendGuide) (w, h float64) {
+	t := e.theme
+	title := t.measure(t.LegendTitle, g.title)
+	w, h = title.width, title.leading
+
+	var labelWidth, rowHeight, rows float64
+	var keyw float64
+	if g.bar != nil {
+		keyw = t.LegendKeySize
+		for _, label := range g.bar.labels {
+			labelWidth = math.Max(labelWidth, t.measure(t.LegendText, label).width)
+		}
+		rowHeight, rows = t.LegendKeySize, t.LegendBarLength
+	} else {
+		var keyh float64
+		keyw, keyh = e.keySize(g)
+		for _, key := range g.keys {
+			m := t.measure(t.LegendText, key.label)
+			labelWidth = math.Max(labelWidth, m.width)
+			rowHeight = math.Max(rowHeight, m.leading)
+		}
+		rowHeight = math.Max(rowHeight, keyh)
+		rows = float64(len(g.keys))
+	}
+	w = math.Max(w, keyw+t.LegendKeySep+labelWidth)
+	h += rowHeight * rows
+	return
+}
+
+func (e *eltLegend) SizeHint() (w, h float64, flexw, flexh bool) {
+	for i, g := range e.guides {
+		gw, gh := e.guideSize(g)
+		if i > 0 {
+			h += e.theme.LegendSpacing
+		}
+		w, h = math.Max(w, gw), h+gh
+	}
+	return w + e.theme.LegendPadding, h, false, true
+}
+
 type eltPadding struct {
 	eltCommon
 	layout.Leaf
@@ -353,8 +503,7 @@ func newEltPadding(side rune, x, y int) *eltPadding {
 }
 
 func (e *eltPadding) SizeHint() (w, h float64, flexw, flexh bool) {
-	const padding = 4 // TODO: Theme.
-
+	padding := e.theme.SubplotPadding
 	switch e.side {
 	case 't', 'b':
 		return 0, padding, true, false
@@ -466,6 +615,31 @@ func addAxisLabels(elts []plotElt, title, xlabel, ylabel string) []plotElt {
 	return elts
 }
 
+func addLegend(elts []plotElt, guides []*legendGuide) []plotElt {
+	if len(guides) == 0 {
+		return elts
+	}
+
+	// Find the region covered by subplots.
+	var r subplotRegion
+	var subplots []*eltSubplot
+	for _, elt := range elts {
+		elt, ok := elt.(*eltSubplot)
+		if !ok {
+			continue
+		}
+		r.update(elt.subplot, 0)
+		subplots = append(subplots, elt)
+	}
+	if !r.valid {
+		return elts
+	}
+
+	legend := newEltLegend(guides, r.x2, r.y1, r.y2)
+	legend.subplots = subplots
+	return append(elts, legend)
+}
+
 type subplotRegion struct {
 	valid                 bool
 	x1, x2, y1, y2, level int
@@ -616,12 +790,11 @@ func layoutPlotElts(elts []plotElt) layout.Element {
 			continue
 		}
 		x, y := elt.xPath[0], elt.yPath[0]
-		elts = append(elts,
-			newEltPadding('t', x, y),
-			newEltPadding('r', x, y),
-			newEltPadding('b', x, y),
-			newEltPadding('l', x, y),
-		)
+		for _, side := range "trbl" {
+			pad := newEltPadding(side, x, y)
+			pad.setTheme(elt.theme)
+			elts = append(elts, pad)
+		}
 	}
 
 	// Construct the global element grid from coordinate paths by
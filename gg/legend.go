@@ -0,0 +1,316 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import (
+	"image/color"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/aclements/go-gg/generic/slice"
+	"github.com/aclements/go-gg/table"
+)
+
+// legendGlyph is a set of glyphs used to draw the keys of a legend.
+type legendGlyph int
+
+const (
+	// legendGlyphRect draws a key as a filled rectangle.
+	legendGlyphRect legendGlyph = 1 << iota
+
+	// legendGlyphLine draws a key as a horizontal line.
+	legendGlyphLine
+
+	// legendGlyphPoint draws a key as a point.
+	legendGlyphPoint
+
+	// legendGlyphText draws a key as a letter.
+	legendGlyphText
+)
+
+// A legendMarker is a marker that can be represented in the legend of
+// a plot.
+type legendMarker interface {
+	// legend returns the glyph that represents this mark in a
+	// legend key and the scaled data bound to this mark's
+	// aesthetics. Elements of sds may be nil. Positional
+	// aesthetics are ignored.
+	legend() (glyph legendGlyph, sds []*scaledData)
+}
+
+// legendAesthetics is the set of aesthetics that can be explained by
+// a legend.
+var legendAesthetics = map[string]bool{
+	"stroke":    true,
+	"fill":      true,
+	"opacity":   true,
+	"size":      true,
+	"shape":     true,
+	"linewidth": true,
+	"linetype":  true,
+}
+
+// A legendGuide explains the mapping of one or more aesthetics in
+// the legend. It is either a set of discrete keys or a color bar.
+type legendGuide struct {
+	title string
+
+	// glyph is the set of glyphs to draw in each key.
+	glyph legendGlyph
+
+	// keys is the list of discrete keys of this guide. It is nil
+	// if this guide is a color bar.
+	keys []legendKey
+
+	// bar is the color bar of this guide, or nil if this guide
+	// consists of discrete keys.
+	bar *legendBar
+}
+
+// A legendKey is a single key in a legendGuide. Its visual values
+// are nil or NaN if the corresponding aesthetic is not part of the
+// guide.
+type legendKey struct {
+	label         string
+	stroke, fill  color.Color
+	opacity, size float64
+	shape         Shape
+	linewidth     float64
+	linetype      LineType
+}
+
+// A legendBar is a continuous color bar, or a stepped color bar for
+// a binned scale.
+type legendBar struct {
+	ranger ContinuousRanger
+
+	// ticks gives the positions of the tick marks of the bar, as
+	// fractions of its length from the minimum value.
+	ticks  []float64
+	labels []string
+}
+
+// legendGuides returns the guides explaining all of the
+// non-positional aesthetics used by p's marks.
+//
+// This must be called after all scales have Rangers.
+func (p *Plot) legendGuides() []*legendGuide {
+	// Collect the distinct scales used for legend aesthetics, in
+	// the order they're used by marks.
+	type entry struct {
+		aes    string
+		scaler Scaler
+		cols   []string
+		glyph  legendGlyph
+	}
+	type entryKey struct {
+		aes    string
+		scaler Scaler
+	}
+	var entries []*entry
+	index := make(map[entryKey]*entry)
+	for _, mark := range p.marks {
+		lm, ok := mark.m.(legendMarker)
+		if !ok {
+			continue
+		}
+		glyph, sds := lm.legend()
+		for _, sd := range sds {
+			if sd == nil || !legendAesthetics[sd.aes] {
+				continue
+			}
+			for _, gid := range mark.groups {
+				seq, ok := sd.seqs[gid]
+				if !ok {
+					continue
+				}
+				if _, ok := seq.seq.([]Unscaled); ok {
+					continue
+				}
+				k := entryKey{sd.aes, seq.scaler}
+				e := index[k]
+				if e == nil {
+					e = &entry{aes: sd.aes, scaler: seq.scaler}
+					index[k] = e
+					entries = append(entries, e)
+				}
+				e.cols = append(e.cols, sd.col)
+				e.glyph |= glyph
+			}
+		}
+	}
+
+	// Group the entries by title, which is typically the column
+	// they were bound from.
+	var titles []string
+	byTitle := make(map[string][]*entry)
+	for _, e := range entries {
+		title, ok := p.axisLabels[e.aes]
+		if !ok {
+			title = strings.Join(slice.Nub(e.cols).([]string), "\n")
+		}
+		if byTitle[title] == nil {
+			titles = append(titles, title)
+		}
+		byTitle[title] = append(byTitle[title], e)
+	}
+
+	// Construct guides, merging aesthetics that have the same
+	// title and the same keys.
+	var guides []*legendGuide
+	for _, title := range titles {
+		var keyGuides []*legendGuide
+		for _, e := range byTitle[title] {
+			if bar := newLegendBar(e.aes, e.scaler, p.theme.LegendMaxKeys); bar != nil {
+				guides = append(guides, &legendGuide{title: title, bar: bar})
+				continue
+			}
+
+			major, labels := legendKeys(e.scaler, p.theme.LegendMaxKeys)
+			if len(labels) == 0 {
+				continue
+			}
+
+			// Find a guide with the same keys.
+			var g *legendGuide
+		findGuide:
+			for _, g2 := range keyGuides {
+				if len(g2.keys) != len(labels) {
+					continue
+				}
+				for i, key := range g2.keys {
+					if key.label != labels[i] {
+						continue findGuide
+					}
+				}
+				g = g2
+				break
+			}
+			if g == nil {
+				g = &legendGuide{title: title, keys: make([]legendKey, len(labels))}
+				for i, label := range labels {
+					g.keys[i] = legendKey{label: label, opacity: math.NaN(), size: math.NaN(), linewidth: math.NaN()}
+				}
+				keyGuides = append(keyGuides, g)
+				guides = append(guides, g)
+			}
+			g.glyph |= e.glyph
+
+			// Map the keys to visual values.
+			vals := reflect.ValueOf(mapMany(e.scaler, major))
+			for i := range g.keys {
+				val := vals.Index(i).Interface()
+				key := &g.keys[i]
+				switch e.aes {
+				case "stroke":
+					key.stroke, _ = val.(color.Color)
+				case "fill":
+					key.fill, _ = val.(color.Color)
+				case "opacity":
+					key.opacity, _ = val.(float64)
+				case "size":
+					key.size, _ = val.(float64)
+				case "shape":
+					key.shape, _ = val.(Shape)
+				case "linewidth":
+					switch val := val.(type) {
+					case float64:
+						key.linewidth = val
+					case LineWidth:
+						key.linewidth = float64(val)
+					}
+				case "linetype":
+					key.linetype, _ = val.(LineType)
+				}
+			}
+		}
+	}
+	return guides
+}
+
+// minLegendKeys is the minimum number of keys legendKeys tries to
+// find for a continuous scale.
+const minLegendKeys = 3
+
+// legendKeys returns the values and labels of the keys of a legend
+// for scaler, with at most maxKeys keys. A continuous scale's ticks
+// may be too sparse to show how it varies, such as a single tick at
+// 0.5 for data in [0.1, 0.9]. In this case, legendKeys asks for finer
+// ticks and then keeps evenly spaced ticks from those.
+func legendKeys(scaler Scaler, maxKeys int) (major table.Slice, labels []string) {
+	major, _, labels = scaler.Ticks(maxKeys, nil)
+	s := scaler
+	if ds, ok := s.(*defaultScale); ok {
+		s = ds.scale
+	}
+	if _, ok := s.(ContinuousScaler); !ok || len(labels) >= minLegendKeys || maxKeys < minLegendKeys {
+		return major, labels
+	}
+
+	for max := 2 * maxKeys; len(labels) < minLegendKeys && max <= 64*maxKeys; max *= 2 {
+		major, _, labels = scaler.Ticks(max, nil)
+	}
+	n := len(labels)
+	if n <= maxKeys {
+		return major, labels
+	}
+	// Keep every step'th tick, starting with the first.
+	step := (n - 1 + maxKeys - 2) / (maxKeys - 1)
+	mv := reflect.ValueOf(major)
+	keep := reflect.MakeSlice(mv.Type(), 0, maxKeys)
+	var keepLabels []string
+	for i := 0; i < n; i += step {
+		keep = reflect.Append(keep, mv.Index(i))
+		keepLabels = append(keepLabels, labels[i])
+	}
+	return keep.Interface(), keepLabels
+}
+
+// newLegendBar returns a color bar for a continuous or binned color
+// scale, or nil if aes is not a color aesthetic or scaler does not
+// map a continuous domain to a continuous range. maxTicks is the
+// maximum number of ticks to show on the bar.
+func newLegendBar(aes string, scaler Scaler, maxTicks int) *legendBar {
+	if aes != "stroke" && aes != "fill" {
+		return nil
+	}
+	s := scaler
+	if ds, ok := s.(*defaultScale); ok {
+		s = ds.scale
+	}
+	if bs, ok := s.(*binnedScale); ok {
+		return bs.legendBar(maxTicks)
+	}
+	if _, ok := s.(ContinuousScaler); !ok {
+		return nil
+	}
+	r, ok := scaler.Ranger(nil).(ContinuousRanger)
+	if !ok {
+		return nil
+	}
+
+	// Find the position of the ticks along the bar by
+	// temporarily giving the scale a unit Ranger.
+	major, _, labels := scaler.Ticks(maxTicks, nil)
+	if len(labels) == 0 {
+		return nil
+	}
+	scaler.Ranger(NewFloatRanger(0, 1))
+	pos := mapMany(scaler, major).([]float64)
+	scaler.Ranger(r)
+
+	// Drop user-specified ticks that are off the bar.
+	var ticks []float64
+	var tickLabels []string
+	for i, p := range pos {
+		if 0 <= p && p <= 1 {
+			ticks = append(ticks, p)
+			tickLabels = append(tickLabels, labels[i])
+		}
+	}
+
+	return &legendBar{r, ticks, tickLabels}
+}
@@ -0,0 +1,106 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aclements/go-gg/table"
+)
+
+func TestLegendGuides(t *testing.T) {
+	tab := new(table.Builder).
+		Add("x", []float64{1, 2, 3}).
+		Add("y", []float64{0.1, 0.5, 0.9}).
+		Add("big", []float64{0, 50, 100}).
+		Add("group", []string{"a", "b", "a"}).
+		Done()
+
+	type guide struct {
+		title  string
+		labels []string
+		bar    bool
+	}
+	for _, test := range []struct {
+		name  string
+		layer Plotter
+		want  []guide
+	}{
+		{
+			// The ticks of [0.1, 0.9] are just 0.5, which
+			// isn't enough keys to show a size ramp.
+			"size",
+			LayerPoints{X: "x", Y: "y", Size: "y"},
+			[]guide{{"y", []string{"0.1", "0.3", "0.5", "0.7", "0.9"}, false}},
+		},
+		{
+			"opacity",
+			LayerPoints{X: "x", Y: "y", Opacity: "y"},
+			[]guide{{"y", []string{"0.1", "0.3", "0.5", "0.7", "0.9"}, false}},
+		},
+		{
+			// Enough ticks are left alone.
+			"size ticks",
+			LayerPoints{X: "x", Y: "y", Size: "big"},
+			[]guide{{"big", []string{"0", "50", "100"}, false}},
+		},
+		{
+			"discrete color",
+			LayerPoints{X: "x", Y: "y", Color: "group"},
+			[]guide{{"group", []string{"a", "b"}, false}},
+		},
+		{
+			"continuous color",
+			LayerPoints{X: "x", Y: "y", Color: "big"},
+			[]guide{{"big", []string{"0", "50", "100"}, true}},
+		},
+		{
+			// Aesthetics with the same column and keys
+			// share a guide.
+			"merged",
+			LayerPoints{X: "x", Y: "y", Shape: "group", Color: "group"},
+			[]guide{{"group", []string{"a", "b"}, false}},
+		},
+	} {
+		p := NewPlot(tab).Add(test.layer)
+		render(p)
+		var got []guide
+		for _, g := range p.legendGuides() {
+			var labels []string
+			if g.bar != nil {
+				labels = g.bar.labels
+			}
+			for _, k := range g.keys {
+				labels = append(labels, k.label)
+			}
+			got = append(got, guide{g.title, labels, g.bar != nil})
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got guides %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLegendKeyValues(t *testing.T) {
+	tab := new(table.Builder).
+		Add("x", []float64{1, 2, 3}).
+		Add("y", []float64{0.1, 0.5, 0.9}).
+		Done()
+	p := NewPlot(tab).Add(LayerPoints{X: "x", Y: "y", Size: "y", Opacity: "y"})
+	render(p)
+	guides := p.legendGuides()
+	if len(guides) != 1 {
+		t.Fatalf("got %d guides, want 1", len(guides))
+	}
+
+	// The size and opacity keys must increase across the ramp.
+	keys := guides[0].keys
+	for i := 1; i < len(keys); i++ {
+		if !(keys[i].size > keys[i-1].size) || !(keys[i].opacity > keys[i-1].opacity) {
+			t.Errorf("key %d has size %v and opacity %v, not greater than key %d's %v and %v", i, keys[i].size, keys[i].opacity, i-1, keys[i-1].size, keys[i-1].opacity)
+		}
+	}
+}
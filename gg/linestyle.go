@@ -0,0 +1,83 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import "reflect"
+
+// A LineType is the dash pattern of a line. LineTypes are the range
+// type of the "linetype" aesthetic.
+type LineType int
+
+const (
+	LineSolid LineType = iota
+	LineDashed
+	LineDotted
+	LineDotDash
+	LineLongDash
+	LineTwoDash
+)
+
+var lineTypeType = reflect.TypeOf(LineType(0))
+
+// lineTypeDashes gives the dash pattern of each LineType as multiples
+// of the line width.
+var lineTypeDashes = [...][]float64{
+	LineSolid:    nil,
+	LineDashed:   {4, 3},
+	LineDotted:   {1, 2},
+	LineDotDash:  {1, 2, 4, 2},
+	LineLongDash: {8, 3},
+	LineTwoDash:  {2, 2, 6, 2},
+}
+
+// dash returns the dash pattern of a line of type t with the given
+// width, in pixels, for PathStyle.Dash.
+func (t LineType) dash(width float64) []float64 {
+	if t < 0 || int(t) >= len(lineTypeDashes) || lineTypeDashes[t] == nil {
+		return nil
+	}
+	dash := make([]float64, len(lineTypeDashes[t]))
+	for i, d := range lineTypeDashes[t] {
+		dash[i] = d * width
+	}
+	return dash
+}
+
+// NewLineTypeRanger returns a DiscreteRanger that maps each level to
+// the corresponding LineType in types. Levels beyond the end of
+// types map to the last LineType.
+func NewLineTypeRanger(types []LineType) DiscreteRanger {
+	return &lineTypeRanger{types}
+}
+
+type lineTypeRanger struct {
+	types []LineType
+}
+
+func (r *lineTypeRanger) RangeType() reflect.Type {
+	return lineTypeType
+}
+
+func (r *lineTypeRanger) Levels() (min, max int) {
+	return len(r.types), len(r.types)
+}
+
+func (r *lineTypeRanger) MapLevel(i, j int) interface{} {
+	if i < 0 {
+		i = 0
+	} else if i >= len(r.types) {
+		i = len(r.types) - 1
+	}
+	return r.types[i]
+}
+
+// A LineWidth is the width of a line in pixels. A column of
+// LineWidths bound to the "linewidth" aesthetic gives exact widths
+// rather than being scaled like other numeric columns.
+type LineWidth float64
+
+// defaultLineWidth is the width of lines drawn by path layers when
+// their width isn't mapped.
+const defaultLineWidth = 3
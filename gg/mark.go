@@ -20,13 +20,19 @@ import (
 	"github.com/aclements/go-gg/generic/slice"
 	"github.com/aclements/go-gg/table"
 	"github.com/aclements/go-moremath/stats"
-	"github.com/ajstarks/svgo"
 )
 
 // TODO: Audit all of this for inf and NaN.
 
 type marker interface {
-	mark(env *renderEnv, canvas *svg.SVG)
+	mark(env *renderEnv, canvas Canvas)
+}
+
+// A groupMarker is a marker that draws all of its groups in a
+// subplot at once, rather than one group at a time. This lets marks
+// coordinate between groups, such as placing bars side by side.
+type groupMarker interface {
+	markGroups(env *renderEnv, gids []table.GroupID, canvas Canvas)
 }
 
 func isFinite(x float64) bool {
@@ -39,28 +45,90 @@ type plotMark struct {
 }
 
 type markPath struct {
-	x, y, stroke, fill *scaledData
+	x, y, stroke, fill  *scaledData
+	linewidth, linetype *scaledData
+	position            Position
+}
+
+func (m *markPath) markGroups(env *renderEnv, gids []table.GroupID, canvas Canvas) {
+	markPlaced(env, gids, canvas, m, m.position, m.x, m.y)
 }
 
-func (m *markPath) mark(env *renderEnv, canvas *svg.SVG) {
+func (m *markPath) mark(env *renderEnv, canvas Canvas) {
 	// XXX What ensures these type assertions will succeed,
 	// especially if it's an identity scale? Maybe identity scales
 	// still need to coerce their results to the right type.
 	xs, ys := env.get(m.x).([]float64), env.get(m.y).([]float64)
-	var stroke color.Color = color.Black
-	if m.stroke != nil {
-		stroke = env.getFirst(m.stroke).(color.Color)
+	style, widths := pathStyle(env, m.stroke, m.fill, m.linewidth, m.linetype)
+	drawPathWidths(canvas, xs, ys, widths, style)
+}
+
+func (m *markPath) legend() (legendGlyph, []*scaledData) {
+	return pathGlyph(m.fill), []*scaledData{m.stroke, m.fill, m.linewidth, m.linetype}
+}
+
+// pathStyle returns the style of the current group of a path mark
+// with the given aesthetics, any of which may be nil. If the line
+// width varies within the group, it also returns the width at each
+// point, and the returned style has a StrokeWidth of 1.
+func pathStyle(env *renderEnv, stroke, fill, linewidth, linetype *scaledData) (style PathStyle, widths []float64) {
+	style = PathStyle{
+		Stroke:      env.theme.DataColor,
+		Fill:        color.Transparent,
+		StrokeWidth: defaultLineWidth,
 	}
-	var fill color.Color = color.Transparent
-	if m.fill != nil {
-		fill = env.getFirst(m.fill).(color.Color)
+	if stroke != nil {
+		style.Stroke = env.getFirst(stroke).(color.Color)
 	}
+	if fill != nil {
+		style.Fill = env.getFirst(fill).(color.Color)
+	}
+	if linewidth != nil {
+		slice.Convert(&widths, env.get(linewidth))
+		if len(widths) > 0 && constant(widths) {
+			style.StrokeWidth, widths = widths[0], nil
+		} else {
+			style.StrokeWidth = 1
+		}
+		if math.IsNaN(style.StrokeWidth) {
+			// The width is missing, so omit the line.
+			style.Stroke, style.StrokeWidth = color.Transparent, 0
+			return style, nil
+		}
+	}
+	if linetype != nil {
+		style.Dash = env.getFirst(linetype).(LineType).dash(style.StrokeWidth)
+	}
+	return style, widths
+}
 
-	drawPath(canvas, xs, ys, stroke, fill)
+// constant returns whether all of xs are equal. NaNs are equal to
+// each other.
+func constant(xs []float64) bool {
+	for _, x := range xs[1:] {
+		if x != xs[0] && !(math.IsNaN(x) && math.IsNaN(xs[0])) {
+			return false
+		}
+	}
+	return true
+}
+
+// pathGlyph returns the legend glyph for a path with the given fill.
+func pathGlyph(fill *scaledData) legendGlyph {
+	if fill != nil {
+		return legendGlyphRect
+	}
+	return legendGlyphLine
 }
 
 type markArea struct {
 	x, upper, lower, fill, fillOpacity *scaledData
+	position                           Position
+}
+
+func (m *markArea) markGroups(env *renderEnv, gids []table.GroupID, canvas Canvas) {
+	// Only adjust X, since Y is a range.
+	markPlaced(env, gids, canvas, m, m.position, m.x, nil)
 }
 
 func reversed(data []float64) []float64 {
@@ -71,11 +139,11 @@ func reversed(data []float64) []float64 {
 	return rev
 }
 
-func (m *markArea) mark(env *renderEnv, canvas *svg.SVG) {
+func (m *markArea) mark(env *renderEnv, canvas Canvas) {
 	xs := env.get(m.x).([]float64)
 	upper := env.get(m.upper).([]float64)
 	lower := env.get(m.lower).([]float64)
-	var fill color.Color = color.Black
+	var fill color.Color = env.theme.DataColor
 	if m.fill != nil {
 		fill = env.getFirst(m.fill).(color.Color)
 	}
@@ -83,36 +151,32 @@ func (m *markArea) mark(env *renderEnv, canvas *svg.SVG) {
 	if m.fillOpacity != nil {
 		fillOpacity = env.getFirst(m.fillOpacity).(float64)
 	}
-	r, g, b, a := fill.RGBA()
-	fill = color.RGBA64{
-		uint16(float64(r) * fillOpacity),
-		uint16(float64(g) * fillOpacity),
-		uint16(float64(b) * fillOpacity),
-		uint16(float64(a) * fillOpacity)}
+	fill = withOpacity(fill, fillOpacity)
 
 	xs = append(xs, reversed(xs)...)
 	ys := append(upper, reversed(lower)...)
 
-	drawPath(canvas, xs, ys, color.Transparent, fill)
+	drawPath(canvas, xs, ys, PathStyle{Fill: fill})
+}
+
+func (m *markArea) legend() (legendGlyph, []*scaledData) {
+	return legendGlyphRect, []*scaledData{m.fill, m.fillOpacity}
 }
 
 type markSteps struct {
 	dir StepMode
 
-	x, y, stroke, fill *scaledData
+	x, y, stroke, fill  *scaledData
+	linewidth, linetype *scaledData
+	position            Position
 }
 
-func (m *markSteps) mark(env *renderEnv, canvas *svg.SVG) {
-	xs, ys := env.get(m.x).([]float64), env.get(m.y).([]float64)
-	var stroke color.Color = color.Black
-	if m.stroke != nil {
-		stroke = env.getFirst(m.stroke).(color.Color)
-	}
-	var fill color.Color = color.Transparent
-	if m.fill != nil {
-		fill = env.getFirst(m.fill).(color.Color)
-	}
+func (m *markSteps) markGroups(env *renderEnv, gids []table.GroupID, canvas Canvas) {
+	markPlaced(env, gids, canvas, m, m.position, m.x, m.y)
+}
 
+func (m *markSteps) mark(env *renderEnv, canvas Canvas) {
+	xs, ys := env.get(m.x).([]float64), env.get(m.y).([]float64)
 	if len(xs) == 0 {
 		return
 	}
@@ -149,10 +213,24 @@ func (m *markSteps) mark(env *renderEnv, canvas *svg.SVG) {
 		ys2 = ys2[1:]
 	}
 
-	drawPath(canvas, xs2, ys2, stroke, fill)
+	style, widths := pathStyle(env, m.stroke, m.fill, m.linewidth, m.linetype)
+	if widths != nil {
+		// Each step takes the width of the point it
+		// starts from.
+		widths2 := make([]float64, len(xs2))
+		for i := range widths2 {
+			widths2[i] = widths[i/2]
+		}
+		widths = widths2
+	}
+	drawPathWidths(canvas, xs2, ys2, widths, style)
+}
+
+func (m *markSteps) legend() (legendGlyph, []*scaledData) {
+	return pathGlyph(m.fill), []*scaledData{m.stroke, m.fill, m.linewidth, m.linetype}
 }
 
-func drawPath(canvas *svg.SVG, xs, ys []float64, stroke color.Color, fill color.Color) {
+func drawPath(canvas Canvas, xs, ys []float64, style PathStyle) {
 	switch len(xs) {
 	case 0:
 		return
@@ -164,7 +242,7 @@ func drawPath(canvas *svg.SVG, xs, ys []float64, stroke color.Color, fill color.
 	}
 
 	// Build path.
-	var path []byte
+	var p Path
 	inLine := false
 	for i := range xs {
 		if !isFinite(xs[i]) || !isFinite(ys[i]) {
@@ -172,29 +250,59 @@ func drawPath(canvas *svg.SVG, xs, ys []float64, stroke color.Color, fill color.
 			continue
 		}
 		if !inLine {
-			path = append(path, 'M')
+			p.MoveTo(xs[i], ys[i])
 			inLine = true
+		} else {
+			p.LineTo(xs[i], ys[i])
 		}
-		path = append(path, ' ')
-		path = strconv.AppendFloat(path, xs[i], 'g', 6, 64)
-		path = append(path, ' ')
-		path = strconv.AppendFloat(path, ys[i], 'g', 6, 64)
 	}
-	if len(path) == 0 {
+	if len(p.Segs) == 0 {
 		return
 	}
 
-	// XXX Stroke width
+	canvas.Path(&p, style)
+}
+
+// drawPathWidths is like drawPath, but if widths is non-nil, it
+// strokes each segment of the path separately with the width at the
+// segment's first point, scaling style.Dash to match. Segments with a
+// NaN width are omitted.
+func drawPathWidths(canvas Canvas, xs, ys, widths []float64, style PathStyle) {
+	if widths == nil {
+		drawPath(canvas, xs, ys, style)
+		return
+	}
 
-	style := cssPaint("stroke", stroke) + ";" + cssPaint("fill", fill) + ";stroke-width:3"
-	canvas.Path(wrapPath(string(path)), style)
+	// Fill the whole path, then stroke each segment.
+	if _, _, _, a := style.Fill.RGBA(); a != 0 {
+		drawPath(canvas, xs, ys, PathStyle{Fill: style.Fill})
+	}
+	for i := 0; i+1 < len(xs); i++ {
+		if !isFinite(xs[i]) || !isFinite(ys[i]) || !isFinite(xs[i+1]) || !isFinite(ys[i+1]) || !isFinite(widths[i]) {
+			continue
+		}
+		seg := PathStyle{Stroke: style.Stroke, StrokeWidth: widths[i]}
+		for _, d := range style.Dash {
+			seg.Dash = append(seg.Dash, d*widths[i]/style.StrokeWidth)
+		}
+		var p Path
+		p.MoveTo(xs[i], ys[i])
+		p.LineTo(xs[i+1], ys[i+1])
+		canvas.Path(&p, seg)
+	}
 }
 
 type markPoint struct {
 	x, y, color, opacity, size *scaledData
+	fill, shape                *scaledData
+	position                   Position
 }
 
-func (m *markPoint) mark(env *renderEnv, canvas *svg.SVG) {
+func (m *markPoint) markGroups(env *renderEnv, gids []table.GroupID, canvas Canvas) {
+	markPlaced(env, gids, canvas, m, m.position, m.x, m.y)
+}
+
+func (m *markPoint) mark(env *renderEnv, canvas Canvas) {
 	xs, ys := env.get(m.x).([]float64), env.get(m.y).([]float64)
 	var colors []color.Color
 	if m.color != nil {
@@ -208,36 +316,635 @@ func (m *markPoint) mark(env *renderEnv, canvas *svg.SVG) {
 	if m.size != nil {
 		sizes = env.get(m.size).([]float64)
 	}
+	var fills []color.Color
+	if m.fill != nil {
+		slice.Convert(&fills, env.get(m.fill))
+	}
+	var shapes []Shape
+	if m.shape != nil {
+		shapes = env.get(m.shape).([]Shape)
+	}
 	mindim := math.Min(env.Size())
 
 	for i := range xs {
 		if !isFinite(xs[i]) || !isFinite(ys[i]) {
 			continue
 		}
+		if sizes != nil && math.IsNaN(sizes[i]) || opacities != nil && math.IsNaN(opacities[i]) {
+			continue
+		}
 
-		var style string
+		var c, fill color.Color = env.theme.DataColor, nil
 		if colors != nil {
-			style = cssPaint("fill", colors[i])
+			c = colors[i]
+		}
+		if fills != nil {
+			fill = fills[i]
 		}
 		if opacities != nil {
-			if style != "" {
-				style += ";"
+			c = withOpacity(c, opacities[i])
+			if fill != nil {
+				fill = withOpacity(fill, opacities[i])
 			}
-			style += fmt.Sprintf("opacity:%.6g", opacities[i])
 		}
 		r := mindim * 0.01
 		if sizes != nil {
 			r = mindim * sizes[i]
 		}
-		canvas.Circle(int(xs[i]), int(ys[i]), int(r), style)
+		shape := ShapeCircle
+		if shapes != nil {
+			shape = shapes[i]
+		}
+		drawShape(canvas, shape, xs[i], ys[i], r, c, fill)
 	}
 }
 
+func (m *markPoint) legend() (legendGlyph, []*scaledData) {
+	return legendGlyphPoint, []*scaledData{m.color, m.opacity, m.size, m.fill, m.shape}
+}
+
+type markBars struct {
+	pos, lo, hi, fill, stroke *scaledData
+
+	width            float64
+	position         Position
+	edge, horizontal bool
+}
+
+func (m *markBars) mark(env *renderEnv, canvas Canvas) {
+	m.markGroups(env, []table.GroupID{env.gid}, canvas)
+}
+
+func (m *markBars) markGroups(env *renderEnv, gids []table.GroupID, canvas Canvas) {
+	g := env.place(m.position, gids, m.pos, nil, m.width, m.horizontal)
+	defer env.unplace()
+
+	// Increasing values along the y axis go up, so the lower edge
+	// of a band on the y axis is at the bottom.
+	dir := 1.0
+	if m.horizontal {
+		dir = -1
+	}
+
+	for _, gid := range gids {
+		env.gid = gid
+		pos := env.get(m.pos).([]float64)
+		lo, hi := env.get(m.lo).([]float64), env.get(m.hi).([]float64)
+		var fill color.Color = env.theme.DataColor
+		if m.fill != nil {
+			fill = env.getFirst(m.fill).(color.Color)
+		}
+		style := PathStyle{Fill: fill}
+		if m.stroke != nil {
+			style.Stroke = env.getFirst(m.stroke).(color.Color)
+			style.StrokeWidth = 1
+		}
+
+		for i, p := range pos {
+			if !isFinite(p) || !isFinite(lo[i]) || !isFinite(hi[i]) {
+				continue
+			}
+			if m.edge {
+				p += dir * g.posBand / 2
+			}
+			p0, w := p-g.slot/2, g.slot
+			v0, v1 := math.Min(lo[i], hi[i]), math.Max(lo[i], hi[i])
+			if m.horizontal {
+				canvas.Rect(v0, p0, v1-v0, w, style)
+			} else {
+				canvas.Rect(p0, v0, w, v1-v0, style)
+			}
+		}
+	}
+}
+
+func (m *markBars) legend() (legendGlyph, []*scaledData) {
+	return legendGlyphRect, []*scaledData{m.fill, m.stroke}
+}
+
+// intervalShape is the shape drawn by a markInterval.
+type intervalShape int
+
+const (
+	// intervalErrorBar draws a line across the interval with a
+	// cap at each end.
+	intervalErrorBar intervalShape = iota
+
+	// intervalPointRange draws a line across the interval with a
+	// point at its center.
+	intervalPointRange
+
+	// intervalCrossbar draws a box around the interval with a
+	// line across it at its center.
+	intervalCrossbar
+)
+
+type markInterval struct {
+	shape intervalShape
+
+	pos, mid, lo, hi, stroke, fill *scaledData
+
+	width      float64
+	position   Position
+	horizontal bool
+}
+
+func (m *markInterval) mark(env *renderEnv, canvas Canvas) {
+	m.markGroups(env, []table.GroupID{env.gid}, canvas)
+}
+
+func (m *markInterval) markGroups(env *renderEnv, gids []table.GroupID, canvas Canvas) {
+	g := env.place(m.position, gids, m.pos, nil, m.width, m.horizontal)
+	defer env.unplace()
+
+	// line draws a line from (p0, v0) to (p1, v1) in position and
+	// value coordinates.
+	line := func(p0, v0, p1, v1 float64, style PathStyle) {
+		if m.horizontal {
+			p0, v0, p1, v1 = v0, p0, v1, p1
+		}
+		var path Path
+		path.MoveTo(p0, v0)
+		path.LineTo(p1, v1)
+		canvas.Path(&path, style)
+	}
+	r := math.Min(env.Size()) * 0.01
+
+	for _, gid := range gids {
+		env.gid = gid
+		pos := env.get(m.pos).([]float64)
+		lo, hi := env.get(m.lo).([]float64), env.get(m.hi).([]float64)
+		var mid []float64
+		if m.mid != nil {
+			mid = env.get(m.mid).([]float64)
+		}
+		var stroke color.Color = env.theme.DataColor
+		if m.stroke != nil {
+			stroke = env.getFirst(m.stroke).(color.Color)
+		}
+		var fill color.Color = color.Transparent
+		if m.fill != nil {
+			fill = env.getFirst(m.fill).(color.Color)
+		}
+		style := PathStyle{Stroke: stroke, StrokeWidth: 2}
+
+		for i, p := range pos {
+			if !isFinite(p) || !isFinite(lo[i]) || !isFinite(hi[i]) {
+				continue
+			}
+			p0, p1 := p-g.slot/2, p+g.slot/2
+			switch m.shape {
+			case intervalErrorBar:
+				line(p, lo[i], p, hi[i], style)
+				line(p0, lo[i], p1, lo[i], style)
+				line(p0, hi[i], p1, hi[i], style)
+
+			case intervalPointRange:
+				line(p, lo[i], p, hi[i], style)
+				if isFinite(mid[i]) {
+					cx, cy := p, mid[i]
+					if m.horizontal {
+						cx, cy = cy, cx
+					}
+					canvas.Circle(cx, cy, 1.5*r, PathStyle{Fill: stroke})
+				}
+
+			case intervalCrossbar:
+				v0, v1 := math.Min(lo[i], hi[i]), math.Max(lo[i], hi[i])
+				box := PathStyle{Stroke: stroke, Fill: fill, StrokeWidth: 1}
+				if m.horizontal {
+					canvas.Rect(v0, p0, v1-v0, g.slot, box)
+				} else {
+					canvas.Rect(p0, v0, g.slot, v1-v0, box)
+				}
+				if isFinite(mid[i]) {
+					line(p0, mid[i], p1, mid[i], style)
+				}
+			}
+		}
+	}
+}
+
+func (m *markInterval) legend() (legendGlyph, []*scaledData) {
+	glyph := legendGlyphLine
+	switch m.shape {
+	case intervalPointRange:
+		glyph |= legendGlyphPoint
+	case intervalCrossbar:
+		if m.fill != nil {
+			glyph |= legendGlyphRect
+		}
+	}
+	return glyph, []*scaledData{m.stroke, m.fill}
+}
+
+type markBoxPlot struct {
+	pos *scaledData
+
+	lowerWhisker, lowerQuartile, median, upperQuartile, upperWhisker *scaledData
+	outlier                                                          *scaledData
+
+	// lowerNotch and upperNotch are nil unless notch is set.
+	lowerNotch, upperNotch *scaledData
+
+	fill, stroke *scaledData
+
+	// counts gives the number of values summarized by each box,
+	// or is nil if boxes have a fixed width.
+	counts map[table.GroupID][]float64
+
+	width    float64
+	position Position
+	notch    bool
+}
+
+func (m *markBoxPlot) mark(env *renderEnv, canvas Canvas) {
+	m.markGroups(env, []table.GroupID{env.gid}, canvas)
+}
+
+func (m *markBoxPlot) markGroups(env *renderEnv, gids []table.GroupID, canvas Canvas) {
+	g := env.place(m.position, gids, m.pos, nil, m.width, false)
+	defer env.unplace()
+
+	// Scale variable-width boxes relative to the largest box in
+	// this subplot.
+	maxCount := 0.0
+	for _, gid := range gids {
+		for _, c := range m.counts[gid] {
+			maxCount = math.Max(maxCount, c)
+		}
+	}
+	r := math.Min(env.Size()) * 0.01
+
+	for _, gid := range gids {
+		env.gid = gid
+		pos := env.get(m.pos).([]float64)
+		lw, lq := env.get(m.lowerWhisker).([]float64), env.get(m.lowerQuartile).([]float64)
+		med := env.get(m.median).([]float64)
+		uq, uw := env.get(m.upperQuartile).([]float64), env.get(m.upperWhisker).([]float64)
+		var ln, un []float64
+		if m.notch {
+			ln, un = env.get(m.lowerNotch).([]float64), env.get(m.upperNotch).([]float64)
+		}
+		outlier := env.get(m.outlier).([]float64)
+		var stroke color.Color = env.theme.DataColor
+		if m.stroke != nil {
+			stroke = env.getFirst(m.stroke).(color.Color)
+		}
+		var fill color.Color = color.Transparent
+		if m.fill != nil {
+			fill = env.getFirst(m.fill).(color.Color)
+		}
+		style := PathStyle{Stroke: stroke, StrokeWidth: 1}
+
+		for i, p := range pos {
+			if !isFinite(p) {
+				continue
+			}
+			if isFinite(outlier[i]) {
+				canvas.Circle(p, outlier[i], r, PathStyle{Fill: stroke})
+				continue
+			}
+			if !isFinite(lq[i]) || !isFinite(uq[i]) {
+				continue
+			}
+
+			w := g.slot
+			if m.counts != nil && maxCount > 0 {
+				w *= math.Sqrt(m.counts[gid][i] / maxCount)
+			}
+			p0, p1 := p-w/2, p+w/2
+
+			// Draw the whiskers.
+			var whiskers Path
+			whiskers.MoveTo(p, lq[i])
+			whiskers.LineTo(p, lw[i])
+			whiskers.MoveTo(p, uq[i])
+			whiskers.LineTo(p, uw[i])
+			canvas.Path(&whiskers, style)
+
+			// Draw the box, indenting the median by half
+			// of each side if it's notched.
+			var box Path
+			inset := 0.0
+			if m.notch {
+				inset = w / 4
+				box.MoveTo(p0, lq[i])
+				box.LineTo(p0, ln[i])
+				box.LineTo(p0+inset, med[i])
+				box.LineTo(p0, un[i])
+				box.LineTo(p0, uq[i])
+				box.LineTo(p1, uq[i])
+				box.LineTo(p1, un[i])
+				box.LineTo(p1-inset, med[i])
+				box.LineTo(p1, ln[i])
+				box.LineTo(p1, lq[i])
+				box.Close()
+			} else {
+				box = *rectPath(p0, lq[i], w, uq[i]-lq[i])
+			}
+			canvas.Path(&box, PathStyle{Stroke: stroke, Fill: fill, StrokeWidth: 1})
+
+			var median Path
+			median.MoveTo(p0+inset, med[i])
+			median.LineTo(p1-inset, med[i])
+			canvas.Path(&median, PathStyle{Stroke: stroke, StrokeWidth: 2})
+		}
+	}
+}
+
+func (m *markBoxPlot) legend() (legendGlyph, []*scaledData) {
+	glyph := legendGlyphRect
+	if m.fill == nil {
+		glyph = legendGlyphLine
+	} else if m.stroke != nil {
+		glyph |= legendGlyphLine
+	}
+	return glyph, []*scaledData{m.fill, m.stroke}
+}
+
+type markViolin struct {
+	pos, y, fill, stroke *scaledData
+
+	// density and cdf give the density estimate and cumulative
+	// density at each row. violin gives the index of the violin
+	// each row belongs to, and count gives the number of values
+	// summarized by that violin.
+	density, cdf, count map[table.GroupID][]float64
+	violin              map[table.GroupID][]int
+
+	scale     ViolinScale
+	quantiles []float64
+	width     float64
+	position  Position
+}
+
+func (m *markViolin) mark(env *renderEnv, canvas Canvas) {
+	m.markGroups(env, []table.GroupID{env.gid}, canvas)
+}
+
+func (m *markViolin) markGroups(env *renderEnv, gids []table.GroupID, canvas Canvas) {
+	g := env.place(m.position, gids, m.pos, nil, m.width, false)
+	defer env.unplace()
+
+	// weight returns the factor to weight the density of row i
+	// of group gid by before scaling.
+	weight := func(gid table.GroupID, i int) float64 {
+		if m.scale == ViolinCount {
+			return m.count[gid][i]
+		}
+		return 1
+	}
+	// Scale the widest violin in this subplot to fill its slot.
+	maxDensity := 0.0
+	for _, gid := range gids {
+		for i, d := range m.density[gid] {
+			if isFinite(d) {
+				maxDensity = math.Max(maxDensity, d*weight(gid, i))
+			}
+		}
+	}
+
+	for _, gid := range gids {
+		env.gid = gid
+		pos, ys := env.get(m.pos).([]float64), env.get(m.y).([]float64)
+		var stroke color.Color = env.theme.DataColor
+		if m.stroke != nil {
+			stroke = env.getFirst(m.stroke).(color.Color)
+		}
+		var fill color.Color = color.Transparent
+		if m.fill != nil {
+			fill = env.getFirst(m.fill).(color.Color)
+		}
+
+		// Compute the half-width at each row.
+		density, violin := m.density[gid], m.violin[gid]
+		hws := make([]float64, len(density))
+		for i, d := range density {
+			hws[i] = d * weight(gid, i) / maxDensity * g.slot / 2
+		}
+
+		// Draw each violin.
+		for lo := 0; lo < len(violin); {
+			hi := lo + 1
+			for hi < len(violin) && violin[hi] == violin[lo] {
+				hi++
+			}
+			if m.scale == ViolinWidth {
+				// Scale each violin to fill its slot.
+				max := 0.0
+				for _, hw := range hws[lo:hi] {
+					if isFinite(hw) {
+						max = math.Max(max, hw)
+					}
+				}
+				for i := lo; i < hi; i++ {
+					hws[i] *= g.slot / 2 / max
+				}
+			}
+			m.markViolin(canvas, pos[lo], ys[lo:hi], hws[lo:hi], m.cdf[gid][lo:hi], stroke, fill)
+			lo = hi
+		}
+	}
+}
+
+// markViolin draws one violin centered at pos with half-width hws[i]
+// at ys[i], and draws its quantiles using the cumulative density
+// cdf.
+func (m *markViolin) markViolin(canvas Canvas, pos float64, ys, hws, cdf []float64, stroke, fill color.Color) {
+	if !isFinite(pos) {
+		return
+	}
+	var p Path
+	for i := range ys {
+		if !isFinite(ys[i]) || !isFinite(hws[i]) {
+			continue
+		}
+		if len(p.Segs) == 0 {
+			p.MoveTo(pos+hws[i], ys[i])
+		} else {
+			p.LineTo(pos+hws[i], ys[i])
+		}
+	}
+	for i := len(ys) - 1; i >= 0; i-- {
+		if isFinite(ys[i]) && isFinite(hws[i]) {
+			p.LineTo(pos-hws[i], ys[i])
+		}
+	}
+	if len(p.Segs) == 0 {
+		return
+	}
+	p.Close()
+	canvas.Path(&p, PathStyle{Stroke: stroke, Fill: fill, StrokeWidth: 1})
+
+	// The density is trimmed to the range of the data, so
+	// normalize the cumulative density to that range.
+	lo, hi := cdf[0], cdf[len(cdf)-1]
+	for _, q := range m.quantiles {
+		at := lo + q*(hi-lo)
+		i := sort.SearchFloat64s(cdf, at)
+		if i == 0 || i == len(cdf) {
+			continue
+		}
+		t := (at - cdf[i-1]) / (cdf[i] - cdf[i-1])
+		y := ys[i-1] + t*(ys[i]-ys[i-1])
+		hw := hws[i-1] + t*(hws[i]-hws[i-1])
+		var line Path
+		line.MoveTo(pos-hw, y)
+		line.LineTo(pos+hw, y)
+		canvas.Path(&line, PathStyle{Stroke: stroke, StrokeWidth: 1})
+	}
+}
+
+func (m *markViolin) legend() (legendGlyph, []*scaledData) {
+	glyph := legendGlyphRect
+	if m.fill == nil {
+		glyph = legendGlyphLine
+	} else if m.stroke != nil {
+		glyph |= legendGlyphLine
+	}
+	return glyph, []*scaledData{m.fill, m.stroke}
+}
+
+// A ruleKey identifies a reference line for removing duplicates.
+type ruleKey struct {
+	at, slope  float64
+	r, g, b, a uint32
+}
+
+// markRule draws lines across the full width or height of a subplot.
+type markRule struct {
+	at, stroke *scaledData
+	vertical   bool
+}
+
+func (m *markRule) mark(env *renderEnv, canvas Canvas) {
+	m.markGroups(env, []table.GroupID{env.gid}, canvas)
+}
+
+func (m *markRule) markGroups(env *renderEnv, gids []table.GroupID, canvas Canvas) {
+	x, y, w, h := env.Area()
+	seen := make(map[ruleKey]bool)
+	for _, gid := range gids {
+		env.gid = gid
+		at := env.get(m.at).([]float64)
+		var stroke color.Color = env.theme.DataColor
+		if m.stroke != nil {
+			stroke = env.getFirst(m.stroke).(color.Color)
+		}
+		r, g, b, a := stroke.RGBA()
+		for _, v := range at {
+			if !isFinite(v) || seen[ruleKey{v, 0, r, g, b, a}] {
+				continue
+			}
+			seen[ruleKey{v, 0, r, g, b, a}] = true
+			var p Path
+			if m.vertical {
+				p.MoveTo(v, y)
+				p.LineTo(v, y+h)
+			} else {
+				p.MoveTo(x, v)
+				p.LineTo(x+w, v)
+			}
+			canvas.Path(&p, PathStyle{Stroke: stroke, StrokeWidth: 2})
+		}
+	}
+}
+
+func (m *markRule) legend() (legendGlyph, []*scaledData) {
+	return legendGlyphLine, []*scaledData{m.stroke}
+}
+
+// markABLine draws lines through two points across the full width of
+// a subplot.
+type markABLine struct {
+	x0, y0, x1, y1, stroke *scaledData
+}
+
+func (m *markABLine) mark(env *renderEnv, canvas Canvas) {
+	m.markGroups(env, []table.GroupID{env.gid}, canvas)
+}
+
+func (m *markABLine) markGroups(env *renderEnv, gids []table.GroupID, canvas Canvas) {
+	x, _, w, _ := env.Area()
+	seen := make(map[ruleKey]bool)
+	for _, gid := range gids {
+		env.gid = gid
+		xs, ys := m.x0.seqs[gid].scaler, m.y0.seqs[gid].scaler
+		if !isLinearScaler(xs) || !isLinearScaler(ys) {
+			Warning.Printf("LayerABLine requires linear x and y scales, but they are %s and %s; ignoring", xs, ys)
+			continue
+		}
+		x0s, y0s := env.get(m.x0).([]float64), env.get(m.y0).([]float64)
+		x1s, y1s := env.get(m.x1).([]float64), env.get(m.y1).([]float64)
+		var stroke color.Color = env.theme.DataColor
+		if m.stroke != nil {
+			stroke = env.getFirst(m.stroke).(color.Color)
+		}
+		r, g, b, a := stroke.RGBA()
+		for i := range x0s {
+			if x0s[i] == x1s[i] {
+				continue
+			}
+			slope := (y1s[i] - y0s[i]) / (x1s[i] - x0s[i])
+			at := y0s[i] + (x-x0s[i])*slope
+			key := ruleKey{at, slope, r, g, b, a}
+			if !isFinite(at) || !isFinite(slope) || seen[key] {
+				continue
+			}
+			seen[key] = true
+			var p Path
+			p.MoveTo(x, at)
+			p.LineTo(x+w, at+w*slope)
+			canvas.Path(&p, PathStyle{Stroke: stroke, StrokeWidth: 2})
+		}
+	}
+}
+
+func (m *markABLine) legend() (legendGlyph, []*scaledData) {
+	return legendGlyphLine, []*scaledData{m.stroke}
+}
+
+// isLinearScaler returns whether s maps numbers linearly. A default
+// scale that hasn't been trained is linear.
+func isLinearScaler(s Scaler) bool {
+	if ds, ok := s.(*defaultScale); ok {
+		if ds.scale == nil {
+			return true
+		}
+		s = ds.scale
+	}
+	ms, ok := s.(*moremathScale)
+	return ok && ms.base == 0 && ms.trans == nil
+}
+
+// minGap returns the smallest positive difference between the finite
+// values in xs, or 0 if there are fewer than two distinct finite
+// values.
+func minGap(xs []float64) float64 {
+	xs = append([]float64(nil), xs...)
+	sort.Float64s(xs)
+	gap := 0.0
+	for i := 1; i < len(xs); i++ {
+		if !isFinite(xs[i-1]) || !isFinite(xs[i]) {
+			continue
+		}
+		if d := xs[i] - xs[i-1]; d > 0 && (gap == 0 || d < gap) {
+			gap = d
+		}
+	}
+	return gap
+}
+
 type markTiles struct {
-	x, y, fill *scaledData
+	x, y, fill, stroke *scaledData
+
+	// xlo, xhi, ylo, and yhi give the edges of each tile, or are
+	// nil if the tiles' sizes are determined from their spacing.
+	xlo, xhi, ylo, yhi *scaledData
 }
 
-func (m *markTiles) mark(env *renderEnv, canvas *svg.SVG) {
+func (m *markTiles) mark(env *renderEnv, canvas Canvas) {
 	xs, ys := env.get(m.x).([]float64), env.get(m.y).([]float64)
 	// TODO: Should the Scaler (or Ranger) ensure that the values
 	// are color.Color? How would this work with an identity
@@ -246,11 +953,10 @@ func (m *markTiles) mark(env *renderEnv, canvas *svg.SVG) {
 	if m.fill != nil {
 		slice.Convert(&fills, env.get(m.fill))
 	}
-
-	// TODO: We can't use an <image> this if the width and height
-	// are specified, or if there is a stroke.
-	// minx, maxx := stats.Bounds(xs)
-	// miny, maxy := stats.Bounds(ys)
+	var strokes []color.Color
+	if m.stroke != nil {
+		slice.Convert(&strokes, env.get(m.stroke))
+	}
 
 	// Compute image bounds.
 	imageBounds := func(vals []float64) (float64, float64, float64, bool) {
@@ -273,11 +979,9 @@ func (m *markTiles) mark(env *renderEnv, canvas *svg.SVG) {
 		case 0:
 			return 0, 0, -1, false
 		case 1:
-			// TODO: In this case we'll produce a 1 pixel
-			// wide/high line. That's probably not what's
-			// desired. Maybe we want it to be the
-			// width/height of the plot area?
-			minGap = 1.0
+			// There's no spacing to derive the tile size
+			// from, so let the rectangles fill the plot.
+			regular = false
 		default:
 			sort.Float64s(unique)
 			minGap = unique[1] - unique[0]
@@ -285,11 +989,11 @@ func (m *markTiles) mark(env *renderEnv, canvas *svg.SVG) {
 				minGap = math.Min(minGap, u-unique[i])
 			}
 			// Consider the spacing "regular" if every
-			// point is within a 1000th of a multiple of
-			// minGap.
-			for _, u := range unique {
-				_, error := math.Modf((u - unique[0]) / minGap)
-				if 0.001 <= error && error <= 0.999 {
+			// gap is within a 1000th of minGap. Otherwise,
+			// the image would have empty cells, such as
+			// on a log-spaced grid.
+			for i, u := range unique[1:] {
+				if (u-unique[i])/minGap > 1.001 {
 					regular = false
 					break
 				}
@@ -302,9 +1006,9 @@ func (m *markTiles) mark(env *renderEnv, canvas *svg.SVG) {
 	if xgap == -1 || ygap == -1 {
 		return
 	}
-	if !xreg || !yreg {
-		// TODO: Can't use an image.
-		panic("not implemented: irregular tile spacing")
+	if !xreg || !yreg || m.xlo != nil || m.ylo != nil || strokes != nil {
+		m.markRects(env, canvas, xs, ys, fills, strokes)
+		return
 	}
 
 	// TODO: If there are a small number of cells, just make the
@@ -314,7 +1018,7 @@ func (m *markTiles) mark(env *renderEnv, canvas *svg.SVG) {
 	// Create the image.
 	iw, ih := round((xmax-xmin+xgap)/xgap), round((ymax-ymin+ygap)/ygap)
 	img := image.NewRGBA(image.Rect(0, 0, iw, ih))
-	fill := color.Color(color.Black)
+	fill := env.theme.DataColor
 	for i := range xs {
 		if !isFinite(xs[i]) || !isFinite(ys[i]) {
 			continue
@@ -325,17 +1029,291 @@ func (m *markTiles) mark(env *renderEnv, canvas *svg.SVG) {
 		img.Set(round((xs[i]-xmin)/xgap), round((ys[i]-ymin)/ygap), fill)
 	}
 
-	// Encode the image.
-	uri := bytes.NewBufferString("data:image/png;base64,")
-	w := base64.NewEncoder(base64.StdEncoding, uri)
-	if err := png.Encode(w, img); err != nil {
-		Warning.Println("error encoding image:", err)
-		return
+	canvas.Image(float64(round(xmin-xgap/2)), float64(round(ymin-ygap/2)),
+		float64(round(xmax-xmin+xgap)), float64(int(ymax-ymin+ygap)),
+		img)
+}
+
+// markRects draws each tile as a separate rectangle.
+func (m *markTiles) markRects(env *renderEnv, canvas Canvas, xs, ys []float64, fills, strokes []color.Color) {
+	w, h := env.Size()
+	edges := func(coords []float64, lo, hi *scaledData, def float64) ([]float64, []float64) {
+		if lo != nil {
+			return env.get(lo).([]float64), env.get(hi).([]float64)
+		}
+		return tileBounds(coords, def)
 	}
-	w.Close()
-	canvas.Image(round(xmin-xgap/2), round(ymin-ygap/2),
-		round(xmax-xmin+xgap), int(ymax-ymin+ygap),
-		uri.String(), `preserveAspectRatio="none" style="image-rendering:optimizeSpeed;image-rendering:-moz-crisp-edges;image-rendering:-webkit-optimize-contrast;image-rendering:pixelated"`)
+	x0s, x1s := edges(xs, m.xlo, m.xhi, w)
+	y0s, y1s := edges(ys, m.ylo, m.yhi, h)
+
+	// Snap the edges to pixels so adjacent tiles don't leave
+	// antialiased seams between them.
+	snap := func(a, b float64) (float64, float64) {
+		return float64(round(math.Min(a, b))), float64(round(math.Max(a, b)))
+	}
+
+	style := PathStyle{Fill: env.theme.DataColor}
+	for i := range xs {
+		if !isFinite(x0s[i]) || !isFinite(x1s[i]) || !isFinite(y0s[i]) || !isFinite(y1s[i]) {
+			continue
+		}
+		x0, x1 := snap(x0s[i], x1s[i])
+		y0, y1 := snap(y0s[i], y1s[i])
+		if fills != nil {
+			style.Fill = fills[i]
+		}
+		if strokes != nil {
+			style.Stroke, style.StrokeWidth = strokes[i], 1
+		}
+		canvas.Rect(x0, y0, x1-x0, y1-y0, style)
+	}
+}
+
+// tileBounds returns the edges of tiles centered at each of coords.
+// The boundary between tiles at adjacent distinct coordinates is
+// halfway between them, and the outermost tiles extend as far
+// outward as they do inward. If there is only one distinct
+// coordinate, its tiles have size def.
+func tileBounds(coords []float64, def float64) (lo, hi []float64) {
+	var unique []float64
+	for _, c := range coords {
+		if isFinite(c) {
+			unique = append(unique, c)
+		}
+	}
+	sort.Float64s(unique)
+	unique = slice.Nub(unique).([]float64)
+
+	type bounds struct{ lo, hi float64 }
+	index := make(map[float64]bounds, len(unique))
+	for i, u := range unique {
+		var b bounds
+		switch {
+		case len(unique) == 1:
+			b = bounds{u - def/2, u + def/2}
+		case i == 0:
+			b.hi = (u + unique[i+1]) / 2
+			b.lo = 2*u - b.hi
+		case i == len(unique)-1:
+			b.lo = (unique[i-1] + u) / 2
+			b.hi = 2*u - b.lo
+		default:
+			b = bounds{(unique[i-1] + u) / 2, (u + unique[i+1]) / 2}
+		}
+		index[u] = b
+	}
+
+	lo, hi = make([]float64, len(coords)), make([]float64, len(coords))
+	for i, c := range coords {
+		b, ok := index[c]
+		if !ok {
+			b = bounds{math.NaN(), math.NaN()}
+		}
+		lo[i], hi[i] = b.lo, b.hi
+	}
+	return lo, hi
+}
+
+func (m *markTiles) legend() (legendGlyph, []*scaledData) {
+	return legendGlyphRect, []*scaledData{m.fill, m.stroke}
+}
+
+type markRects struct {
+	xmin, xmax, ymin, ymax    *scaledData
+	fill, fillOpacity, stroke *scaledData
+}
+
+func (m *markRects) mark(env *renderEnv, canvas Canvas) {
+	x, y, w, h := env.Area()
+	// edges returns the mapped values of sd, or nil if sd is nil.
+	edges := func(sd *scaledData) []float64 {
+		if sd == nil {
+			return nil
+		}
+		return env.get(sd).([]float64)
+	}
+	x0s, x1s := edges(m.xmin), edges(m.xmax)
+	y0s, y1s := edges(m.ymin), edges(m.ymax)
+	n := 0
+	for _, es := range [][]float64{x0s, x1s, y0s, y1s} {
+		if es != nil {
+			n = len(es)
+		}
+	}
+	var fills, strokes []color.Color
+	if m.fill != nil {
+		slice.Convert(&fills, env.get(m.fill))
+	}
+	if m.stroke != nil {
+		slice.Convert(&strokes, env.get(m.stroke))
+	}
+	var opacities []float64
+	if m.fillOpacity != nil {
+		opacities = env.get(m.fillOpacity).([]float64)
+	}
+
+	// edge returns the i'th value of es, or def if es is nil.
+	edge := func(es []float64, i int, def float64) float64 {
+		if es == nil {
+			return def
+		}
+		return es[i]
+	}
+	for i := 0; i < n; i++ {
+		// The y axis is flipped, so YMin maps to the bottom.
+		x0, x1 := edge(x0s, i, x), edge(x1s, i, x+w)
+		y0, y1 := edge(y0s, i, y+h), edge(y1s, i, y)
+		if !isFinite(x0) || !isFinite(x1) || !isFinite(y0) || !isFinite(y1) {
+			continue
+		}
+		var fill color.Color = env.theme.DataColor
+		if fills != nil {
+			fill = fills[i]
+		}
+		opacity := 0.5
+		if opacities != nil {
+			opacity = opacities[i]
+		}
+		style := PathStyle{Fill: withOpacity(fill, opacity)}
+		if strokes != nil {
+			style.Stroke, style.StrokeWidth = strokes[i], 1
+		}
+		canvas.Rect(math.Min(x0, x1), math.Min(y0, y1), math.Abs(x1-x0), math.Abs(y1-y0), style)
+	}
+}
+
+func (m *markRects) legend() (legendGlyph, []*scaledData) {
+	return legendGlyphRect, []*scaledData{m.fill, m.fillOpacity, m.stroke}
+}
+
+type markSegments struct {
+	x0, y0, x1, y1, stroke *scaledData
+	arrow                  ArrowMode
+}
+
+func (m *markSegments) mark(env *renderEnv, canvas Canvas) {
+	x0s, y0s := env.get(m.x0).([]float64), env.get(m.y0).([]float64)
+	x1s, y1s := env.get(m.x1).([]float64), env.get(m.y1).([]float64)
+	var strokes []color.Color
+	if m.stroke != nil {
+		slice.Convert(&strokes, env.get(m.stroke))
+	}
+
+	const width = 2
+	for i := range x0s {
+		if !isFinite(x0s[i]) || !isFinite(y0s[i]) || !isFinite(x1s[i]) || !isFinite(y1s[i]) {
+			continue
+		}
+		var stroke color.Color = env.theme.DataColor
+		if strokes != nil {
+			stroke = strokes[i]
+		}
+		x0, y0, x1, y1 := x0s[i], y0s[i], x1s[i], y1s[i]
+		if m.arrow == ArrowEnd || m.arrow == ArrowBoth {
+			x1, y1 = drawArrowhead(canvas, x0s[i], y0s[i], x1s[i], y1s[i], width, stroke)
+		}
+		if m.arrow == ArrowStart || m.arrow == ArrowBoth {
+			x0, y0 = drawArrowhead(canvas, x1s[i], y1s[i], x0s[i], y0s[i], width, stroke)
+		}
+		var p Path
+		p.MoveTo(x0, y0)
+		p.LineTo(x1, y1)
+		canvas.Path(&p, PathStyle{Stroke: stroke, StrokeWidth: width})
+	}
+}
+
+func (m *markSegments) legend() (legendGlyph, []*scaledData) {
+	return legendGlyphLine, []*scaledData{m.stroke}
+}
+
+// drawArrowhead draws a filled arrowhead at (x1, y1) pointing away
+// from (x0, y0), sized for a line of the given stroke width. It
+// returns the point where the line should end so it doesn't poke
+// through the tip of the arrowhead.
+func drawArrowhead(canvas Canvas, x0, y0, x1, y1, width float64, fill color.Color) (float64, float64) {
+	dx, dy := x1-x0, y1-y0
+	l := math.Hypot(dx, dy)
+	if l == 0 {
+		return x1, y1
+	}
+	dx, dy = dx/l, dy/l
+	length, half := 5*width, 2*width
+	bx, by := x1-dx*length, y1-dy*length
+	var p Path
+	p.MoveTo(x1, y1)
+	p.LineTo(bx-dy*half, by+dx*half)
+	p.LineTo(bx+dy*half, by-dx*half)
+	p.Close()
+	canvas.Path(&p, PathStyle{Fill: fill})
+	return bx + dx*width, by + dy*width
+}
+
+type markContour struct {
+	x, y, level *scaledData
+	filled      bool
+}
+
+func (m *markContour) mark(env *renderEnv, canvas Canvas) {
+	xs, ys := env.get(m.x).([]float64), env.get(m.y).([]float64)
+	c := env.getFirst(m.level).(color.Color)
+	if m.filled {
+		drawPath(canvas, xs, ys, PathStyle{Fill: c})
+	} else {
+		drawPath(canvas, xs, ys, PathStyle{Stroke: c, StrokeWidth: 1.5})
+	}
+}
+
+func (m *markContour) legend() (legendGlyph, []*scaledData) {
+	if m.filled {
+		return legendGlyphRect, []*scaledData{m.level}
+	}
+	return legendGlyphLine, []*scaledData{m.level}
+}
+
+type markHex struct {
+	x, y, left, right, bottom, top *scaledData
+	fill, stroke                   *scaledData
+}
+
+func (m *markHex) mark(env *renderEnv, canvas Canvas) {
+	xs, ys := env.get(m.x).([]float64), env.get(m.y).([]float64)
+	ls, rs := env.get(m.left).([]float64), env.get(m.right).([]float64)
+	bs, ts := env.get(m.bottom).([]float64), env.get(m.top).([]float64)
+	var fills, strokes []color.Color
+	slice.Convert(&fills, env.get(m.fill))
+	if m.stroke != nil {
+		slice.Convert(&strokes, env.get(m.stroke))
+	}
+
+	for i := range xs {
+		// Compute the hexagon's shape in pixels, so hexagons
+		// tile regardless of the plot's aspect ratio.
+		x, y := xs[i], ys[i]
+		hw, r := (rs[i]-ls[i])/2, (ts[i]-bs[i])/2
+		if !isFinite(x) || !isFinite(y) || !isFinite(hw) || !isFinite(r) {
+			continue
+		}
+		var p Path
+		p.MoveTo(x, y+r)
+		p.LineTo(x+hw, y+r/2)
+		p.LineTo(x+hw, y-r/2)
+		p.LineTo(x, y-r)
+		p.LineTo(x-hw, y-r/2)
+		p.LineTo(x-hw, y+r/2)
+		p.Close()
+
+		// Without a stroke, stroke each hexagon in its fill
+		// color to cover antialiasing seams between them.
+		style := PathStyle{Fill: fills[i], Stroke: fills[i], StrokeWidth: 0.5}
+		if strokes != nil {
+			style.Stroke, style.StrokeWidth = strokes[i], 1
+		}
+		canvas.Path(&p, style)
+	}
+}
+
+func (m *markHex) legend() (legendGlyph, []*scaledData) {
+	return legendGlyphRect, []*scaledData{m.fill, m.stroke}
 }
 
 type markTags struct {
@@ -346,50 +1324,242 @@ type markTags struct {
 	offsetX, offsetY int
 }
 
-func (m *markTags) mark(env *renderEnv, canvas *svg.SVG) {
+func (m *markTags) mark(env *renderEnv, canvas Canvas) {
+	m.markGroups(env, []table.GroupID{env.gid}, canvas)
+}
+
+func (m *markTags) markGroups(env *renderEnv, gids []table.GroupID, canvas Canvas) {
 	const padX = 5
 
-	xs, ys := env.get(m.x).([]float64), env.get(m.y).([]float64)
-	if len(xs) == 0 {
-		return
+	// Find the point and label of each tag, and all of the points
+	// that tags should avoid.
+	type tag struct {
+		label string
+		x, y  float64
 	}
+	var tags []tag
+	var boxes []Rect
+	var points []Point
+	for _, gid := range gids {
+		env.gid = gid
+		xs, ys := env.get(m.x).([]float64), env.get(m.y).([]float64)
+		for i := range xs {
+			if isFinite(xs[i]) && isFinite(ys[i]) {
+				points = append(points, Point{xs[i], ys[i]})
+			}
+		}
+		if len(xs) == 0 {
+			continue
+		}
 
-	// Find the point closest to hpos between the min and max.
-	//
-	// TODO: Give the user control over this.
-	minx, maxx := stats.Bounds(xs)
-	targetx := minx + (maxx-minx)*m.hpos
-	midi, middelta := 0, math.Abs(xs[0]-targetx)
-	for i, x := range xs {
-		delta := math.Abs(x - targetx)
-		if delta < middelta {
-			midi, middelta = i, delta
+		// Find the point closest to hpos between the min and max.
+		//
+		// TODO: Give the user control over this.
+		minx, maxx := stats.Bounds(xs)
+		targetx := minx + (maxx-minx)*m.hpos
+		midi, middelta := 0, math.Abs(xs[0]-targetx)
+		for i, x := range xs {
+			delta := math.Abs(x - targetx)
+			if delta < middelta {
+				midi, middelta = i, delta
+			}
 		}
+		label := fmt.Sprint(reflect.ValueOf(m.labels[gid]).Index(midi).Interface())
+		tags = append(tags, tag{label, xs[midi], ys[midi]})
+
+		// Start the tag box at the requested offset from its
+		// point.
+		x, y := float64(int(xs[midi])), float64(int(ys[midi]))
+		ox, oy := float64(m.offsetX), float64(m.offsetY)
+		t := env.theme.measure(TextTheme{}, label)
+		boxW, boxH := t.width+2*padX, 1.5*t.leading
+		boxX := x + ox
+		if m.offsetX <= 0 {
+			boxX -= boxW
+		}
+		boxes = append(boxes, Rect{boxX, y + oy - boxH/2, boxW, boxH})
 	}
 
-	// Get label.
-	label := fmt.Sprint(reflect.ValueOf(m.labels[env.gid]).Index(midi).Interface())
+	// Move the tags so they stay in the plot area and don't
+	// cover each other or the data.
+	x, y, w, h := env.Area()
+	boxes = repelBoxes(boxes, points, Rect{x, y, w, h}, 2)
 
-	// Attach tag to this point.
-	//
-	// TODO: More user control.
-	//
-	// TODO: Make automatic positioning account for bounds of plot.
-	//
-	// TODO: Adjust positions to avoid overlap. Unfortunately,
-	// this requires some global optimization, but mark only sees
-	// one tag at a time.
-	//
-	// TODO: Re-enable the tag box when I have decent text metrics.
-	//t := measureString(fontSize, label)
-	//canvas.Rect(int(xs[midi]+offsetX-t.width), int(ys[midi]+offsetY-0.75*t.leading), int(t.width), int(1.5*t.leading), `rx="4"`, `fill="white"`, `stroke="black"`)
-	if m.offsetX > 0 {
-		// To the right, left-aligned.
-		canvas.Text(int(xs[midi])+m.offsetX+padX, int(ys[midi])+m.offsetY, label, `dy=".3em"`)
-	} else {
-		canvas.Text(int(xs[midi])+m.offsetX-padX, int(ys[midi])+m.offsetY, label, `dy=".3em"`, `text-anchor="end"`)
+	style := env.theme.textStyle(TextTheme{})
+	style.Baseline = TextBaselineMiddle
+	var boxFill color.Color = color.White
+	if isPaint(env.theme.Background) {
+		boxFill = env.theme.Background
+	}
+	for i, t := range tags {
+		box := boxes[i]
+		// Attach the leader line to the middle of the side of
+		// the box facing the point, leaving and arriving
+		// perpendicular to that side.
+		var p Path
+		p.MoveTo(t.x, t.y)
+		switch {
+		case t.x < box.X:
+			ex, ey := box.X, box.Y+box.H/2
+			p.CubeTo(t.x+0.8*(ex-t.x), t.y, t.x+0.2*(ex-t.x), ey, ex, ey)
+		case t.x > box.X+box.W:
+			ex, ey := box.X+box.W, box.Y+box.H/2
+			p.CubeTo(t.x+0.8*(ex-t.x), t.y, t.x+0.2*(ex-t.x), ey, ex, ey)
+		case t.y < box.Y:
+			ex, ey := box.X+box.W/2, box.Y
+			p.CubeTo(t.x, t.y+0.8*(ey-t.y), ex, t.y+0.2*(ey-t.y), ex, ey)
+		case t.y > box.Y+box.H:
+			ex, ey := box.X+box.W/2, box.Y+box.H
+			p.CubeTo(t.x, t.y+0.8*(ey-t.y), ex, t.y+0.2*(ey-t.y), ex, ey)
+		}
+		if len(p.Segs) > 1 {
+			canvas.Path(&p, PathStyle{Stroke: env.theme.DataColor, StrokeWidth: 2, Dash: []float64{2, 3}})
+		}
 	}
-	canvas.Path(fmt.Sprintf("M%.6g %.6gc%.6g %.6g,%.6g %.6g,%.6g %.6g", xs[midi], ys[midi], 0.8*float64(m.offsetX), 0.0, 0.2*float64(m.offsetX), float64(m.offsetY), float64(m.offsetX), float64(m.offsetY)), `fill="none"`, `stroke="black"`, `stroke-dasharray="2, 3"`, `stroke-width="2"`)
+	// Draw the boxes over all of the leader lines.
+	for i, t := range tags {
+		box := boxes[i]
+		canvas.Path(roundRectPath(box.X, box.Y, box.W, box.H, 4), PathStyle{Fill: boxFill, Stroke: env.theme.DataColor, StrokeWidth: 1})
+		canvas.Text(box.X+padX, box.Y+box.H/2, t.label, style)
+	}
+}
+
+// capHeight is the approximate height of capital letters as a
+// fraction of the font size.
+const capHeight = 0.7
+
+type markText struct {
+	x, y, color, size *scaledData
+	labels            map[table.GroupID]table.Slice
+
+	rotate, hjust, vjust *scaledData
+	nudgeX, nudgeY       *scaledData
+	checkOverlap         bool
+}
+
+func (m *markText) mark(env *renderEnv, canvas Canvas) {
+	m.markGroups(env, []table.GroupID{env.gid}, canvas)
+}
+
+func (m *markText) markGroups(env *renderEnv, gids []table.GroupID, canvas Canvas) {
+	mindim := math.Min(env.Size())
+	var drawn []Rect
+
+	// floats returns the values of sd for the current group, or
+	// nil if sd is nil.
+	floats := func(sd *scaledData) []float64 {
+		if sd == nil {
+			return nil
+		}
+		var vs []float64
+		slice.Convert(&vs, env.get(sd))
+		return vs
+	}
+	// at returns vs[i], or 0 if vs is nil.
+	at := func(vs []float64, i int) float64 {
+		if vs == nil {
+			return 0
+		}
+		return vs[i]
+	}
+
+	for _, gid := range gids {
+		env.gid = gid
+		xs, ys := env.get(m.x).([]float64), env.get(m.y).([]float64)
+		var colors []color.Color
+		if m.color != nil {
+			slice.Convert(&colors, env.get(m.color))
+		}
+		var sizes []float64
+		if m.size != nil {
+			sizes = env.get(m.size).([]float64)
+		}
+		rotates, hjusts, vjusts := floats(m.rotate), floats(m.hjust), floats(m.vjust)
+		nudgeXs, nudgeYs := floats(m.nudgeX), floats(m.nudgeY)
+		labels := reflect.ValueOf(m.labels[gid])
+
+		for i := range xs {
+			if !isFinite(xs[i]) || !isFinite(ys[i]) {
+				continue
+			}
+			angle, hjust, vjust := at(rotates, i), at(hjusts, i), at(vjusts, i)
+			sin, cos := math.Sincos(angle * math.Pi / 180)
+			rotate := func(x, y float64) (float64, float64) {
+				return x*cos - y*sin, x*sin + y*cos
+			}
+			label := fmt.Sprint(labels.Index(i).Interface())
+			style := env.theme.textStyle(TextTheme{})
+			style.Fill = env.theme.DataColor
+			if colors != nil {
+				style.Fill = colors[i]
+			}
+			if sizes != nil {
+				style.Size = 2 * mindim * sizes[i]
+			}
+			style.Rotate = angle
+			width := measureString(style.Font, style.Size, label).width
+
+			// Find the start of the label's baseline
+			// relative to its position, in the label's
+			// coordinates.
+			x, y := xs[i]+at(nudgeXs, i), ys[i]-at(nudgeYs, i)
+			dx, dy := -(hjust+1)/2*width, (vjust+1)/2*capHeight*style.Size
+
+			if m.checkOverlap {
+				box := rotatedBounds(rotate, dx, dy-(1-descent)*style.Size, width, style.Size)
+				box.X, box.Y = box.X+x, box.Y+y
+				if overlapsAny(box, drawn) {
+					continue
+				}
+				drawn = append(drawn, box)
+			}
+
+			// Use the text anchor for the common
+			// justifications, since viewers of some
+			// formats may lay out text with different
+			// metrics.
+			switch hjust {
+			case 0:
+				style.Anchor, dx = TextAnchorMiddle, 0
+			case 1:
+				style.Anchor, dx = TextAnchorEnd, 0
+			}
+			ox, oy := rotate(dx, dy)
+			canvas.Text(x+ox, y+oy, label, style)
+		}
+	}
+}
+
+func (m *markText) legend() (legendGlyph, []*scaledData) {
+	return legendGlyphText, []*scaledData{m.color, m.size}
+}
+
+// descent is the approximate depth of descenders below the baseline
+// as a fraction of the font size.
+const descent = 0.2
+
+// rotatedBounds returns the bounding box of the rectangle with
+// top-left corner (x, y), width w, and height h after transforming
+// it by rotate.
+func rotatedBounds(rotate func(x, y float64) (float64, float64), x, y, w, h float64) Rect {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, c := range [][2]float64{{x, y}, {x + w, y}, {x, y + h}, {x + w, y + h}} {
+		rx, ry := rotate(c[0], c[1])
+		minX, maxX = math.Min(minX, rx), math.Max(maxX, rx)
+		minY, maxY = math.Min(minY, ry), math.Max(maxY, ry)
+	}
+	return Rect{minX, minY, maxX - minX, maxY - minY}
+}
+
+// overlapsAny returns whether r overlaps any of rs.
+func overlapsAny(r Rect, rs []Rect) bool {
+	for _, r2 := range rs {
+		if r.X < r2.X+r2.W && r2.X < r.X+r.W && r.Y < r2.Y+r2.H && r2.Y < r.Y+r.H {
+			return true
+		}
+	}
+	return false
 }
 
 type markTooltips struct {
@@ -397,7 +1567,14 @@ type markTooltips struct {
 	labels map[table.GroupID]table.Slice
 }
 
-func (m *markTooltips) mark(env *renderEnv, canvas *svg.SVG) {
+func (m *markTooltips) mark(env *renderEnv, c Canvas) {
+	// Tooltips are interactive, so they only make sense in SVG.
+	sc, ok := c.(*svgCanvas)
+	if !ok {
+		return
+	}
+	canvas := sc.svg
+
 	// Construct JSON for data.
 	xs, ys := env.get(m.x).([]float64), env.get(m.y).([]float64)
 	if len(xs) == 0 {
@@ -513,6 +1690,17 @@ function tooltipOut(evt, data, tid) {
 `)
 }
 
+// pngDataURI encodes img as a PNG data URI.
+func pngDataURI(img image.Image) (string, error) {
+	uri := bytes.NewBufferString("data:image/png;base64,")
+	w := base64.NewEncoder(base64.StdEncoding, uri)
+	if err := png.Encode(w, img); err != nil {
+		return "", err
+	}
+	w.Close()
+	return uri.String(), nil
+}
+
 // cssPaint returns a CSS fragment for setting CSS property prop to
 // color c.
 func cssPaint(prop string, c color.Color) string {
@@ -0,0 +1,252 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aclements/go-gg/table"
+)
+
+// recordCanvas is a Canvas that records the paths and text drawn on
+// it.
+type recordCanvas struct {
+	paths []recordedPath
+	texts []recordedText
+}
+
+type recordedPath struct {
+	path  *Path
+	style PathStyle
+}
+
+type recordedText struct {
+	x, y  float64
+	text  string
+	style TextStyle
+}
+
+func (c *recordCanvas) Path(p *Path, style PathStyle) {
+	c.paths = append(c.paths, recordedPath{p, style})
+}
+
+func (c *recordCanvas) Circle(cx, cy, r float64, style PathStyle) {}
+
+func (c *recordCanvas) Rect(x, y, w, h float64, style PathStyle) {}
+
+func (c *recordCanvas) Text(x, y float64, text string, style TextStyle) {
+	c.texts = append(c.texts, recordedText{x, y, text, style})
+}
+
+func (c *recordCanvas) Image(x, y, w, h float64, img image.Image) {}
+
+func (c *recordCanvas) BeginGroup(clip *Rect) {}
+
+func (c *recordCanvas) EndGroup() {}
+
+// render renders p to a new recordCanvas.
+func render(p *Plot) *recordCanvas {
+	c := new(recordCanvas)
+	p.Render(c, 400, 300)
+	return c
+}
+
+func TestLayerText(t *testing.T) {
+	tab := new(table.Builder).
+		Add("x", []float64{1, 2, 3}).
+		Add("y", []float64{1, 2, 3}).
+		Add("label", []string{"a", "b", "c"}).
+		Add("angle", []int{0, 45, 90}).
+		Add("nudge", []float64{0, 10, 0}).
+		Done()
+
+	// labelTexts returns the text drawn for each label, by label.
+	labelTexts := func(l LayerText) map[string]recordedText {
+		texts := make(map[string]recordedText)
+		for _, text := range render(NewPlot(tab).Add(l)).texts {
+			switch text.text {
+			case "a", "b", "c":
+				texts[text.text] = text
+			}
+		}
+		return texts
+	}
+
+	plain := labelTexts(LayerText{X: "x", Y: "y", Label: "label"})
+	rotated := labelTexts(LayerText{X: "x", Y: "y", Label: "label", Rotate: "angle", HJust: "nudge"})
+	nudged := labelTexts(LayerText{X: "x", Y: "y", Label: "label", NudgeX: "nudge", NudgeY: "nudge"})
+
+	for i, label := range []string{"a", "b", "c"} {
+		if plain[label].style.Rotate != 0 {
+			t.Errorf("label %s without Rotate has rotation %v, want 0", label, plain[label].style.Rotate)
+		}
+		if want := float64(45 * i); rotated[label].style.Rotate != want {
+			t.Errorf("label %s has rotation %v, want %v", label, rotated[label].style.Rotate, want)
+		}
+
+		wantDX, wantDY := 0.0, 0.0
+		if label == "b" {
+			wantDX, wantDY = 10, -10
+		}
+		dx, dy := nudged[label].x-plain[label].x, nudged[label].y-plain[label].y
+		if dx != wantDX || dy != wantDY {
+			t.Errorf("label %s is nudged by (%v, %v), want (%v, %v)", label, dx, dy, wantDX, wantDY)
+		}
+	}
+}
+
+func TestPathLineWidth(t *testing.T) {
+	tab := new(table.Builder).
+		Add("x", []float64{0, 1, 2, 3}).
+		Add("y", []float64{0, 1, 0, 1}).
+		Add("z", []float64{1, 2, 3, 4}).
+		Done()
+
+	// A continuous width varies along a single path rather than
+	// splitting it into one-point groups. The default linewidth
+	// Ranger maps z to [1, 6] pixels.
+	for _, l := range []Plotter{
+		LayerLines{X: "x", Y: "y", LineWidth: "z"},
+		LayerSteps{LayerPaths: LayerPaths{X: "x", Y: "y", LineWidth: "z"}},
+	} {
+		var widths []float64
+		for _, p := range render(NewPlot(tab).Add(l)).paths {
+			if len(p.path.Segs) == 2 && p.style.Stroke == color.Black {
+				widths = append(widths, p.style.StrokeWidth)
+			}
+		}
+		var want []float64
+		switch l.(type) {
+		case LayerLines:
+			want = []float64{1, 1 + 5.0/3, 1 + 10.0/3}
+		case LayerSteps:
+			// Each step has a horizontal and a vertical
+			// segment.
+			want = []float64{1, 1, 1 + 5.0/3, 1 + 5.0/3, 1 + 10.0/3, 1 + 10.0/3}
+		}
+		// The legend follows the data, so only check the
+		// data's segments.
+		if len(widths) < len(want) || !floatsEqual(widths[:len(want)], want) {
+			t.Errorf("%T: got stroke widths %v, want %v", l, widths, want)
+		}
+	}
+}
+
+func TestDrawPathWidths(t *testing.T) {
+	var c recordCanvas
+	style := PathStyle{Stroke: color.Black, Fill: color.Transparent, StrokeWidth: 1, Dash: []float64{2, 1}}
+	nan := math.NaN()
+	drawPathWidths(&c, []float64{0, 1, 2, nan, 4, 5}, []float64{0, 0, 0, 0, 0, 0}, []float64{1, nan, 2, 2, 3, 3}, style)
+
+	// The NaN width and NaN point omit three segments.
+	want := []PathStyle{
+		{Stroke: color.Black, StrokeWidth: 1, Dash: []float64{2, 1}},
+		{Stroke: color.Black, StrokeWidth: 3, Dash: []float64{6, 3}},
+	}
+	var got []PathStyle
+	for _, p := range c.paths {
+		got = append(got, p.style)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got segment styles %v, want %v", got, want)
+	}
+}
+
+// rulePaths returns the paths drawn by rule layers, which are the
+// only single-segment black paths in c with a stroke width of 2.
+func rulePaths(c *recordCanvas) []*Path {
+	var paths []*Path
+	for _, p := range c.paths {
+		if p.style.StrokeWidth == 2 && p.style.Stroke == color.Black && len(p.path.Segs) == 2 {
+			paths = append(paths, p.path)
+		}
+	}
+	return paths
+}
+
+func TestRuleValues(t *testing.T) {
+	t0 := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	tab := new(table.Builder).
+		Add("t", []time.Time{t0, t0.Add(2 * time.Hour)}).
+		Add("d", []time.Duration{0, 2 * time.Second}).
+		Done()
+
+	// Constant values on time and duration axes go through the
+	// scale like the data, so a value midway between two others
+	// is drawn midway between their lines.
+	c := render(NewPlot(tab).Add(
+		LayerPoints{X: "t", Y: "d"},
+		LayerVLine{XValues: []time.Time{t0, t0.Add(time.Hour), t0.Add(2 * time.Hour)}},
+		LayerHLine{YValues: []time.Duration{0, time.Second, 2 * time.Second}},
+	))
+	paths := rulePaths(c)
+	if len(paths) != 6 {
+		t.Fatalf("got %d rules, want 6", len(paths))
+	}
+	var xs, ys []float64
+	for i, p := range paths {
+		p0, p1 := p.Segs[0].Pts[0], p.Segs[1].Pts[0]
+		if i < 3 {
+			if p0.X != p1.X {
+				t.Errorf("VLine %d is not vertical: %v to %v", i, p0, p1)
+			}
+			xs = append(xs, p0.X)
+		} else {
+			if p0.Y != p1.Y {
+				t.Errorf("HLine %d is not horizontal: %v to %v", i-3, p0, p1)
+			}
+			ys = append(ys, p0.Y)
+		}
+	}
+	if !(xs[0] < xs[2]) || !floatsEqual([]float64{xs[1]}, []float64{(xs[0] + xs[2]) / 2}) {
+		t.Errorf("got VLines at %v, want increasing evenly spaced lines", xs)
+	}
+	if !(ys[0] > ys[2]) || !floatsEqual([]float64{ys[1]}, []float64{(ys[0] + ys[2]) / 2}) {
+		t.Errorf("got HLines at %v, want rising evenly spaced lines", ys)
+	}
+}
+
+func TestABLineScales(t *testing.T) {
+	var buf bytes.Buffer
+	Warning.SetOutput(&buf)
+	defer Warning.SetOutput(os.Stderr)
+
+	t0 := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	tab := new(table.Builder).
+		Add("x", []float64{1, 2}).
+		Add("t", []time.Time{t0, t0.Add(time.Hour)}).
+		Add("y", []float64{1, 2}).
+		Done()
+
+	// On linear scales, the line is drawn.
+	c := render(NewPlot(tab).Add(LayerPoints{X: "x", Y: "y"}, LayerABLine{SlopeValues: []float64{1}, InterceptValues: []float64{0}}))
+	if n := len(rulePaths(c)); n != 1 || buf.Len() != 0 {
+		t.Errorf("linear scales: got %d lines and warnings %q, want 1 line and no warnings", n, buf.String())
+	}
+
+	// On other scales, the line is dropped with a warning rather
+	// than a panic.
+	for _, p := range []*Plot{
+		NewPlot(tab).Add(LayerPoints{X: "t", Y: "y"}, LayerABLine{SlopeValues: []float64{1}, InterceptValues: []float64{0}}),
+		NewPlot(tab).SetScale("y", NewLogScaler(10)).Add(LayerPoints{X: "x", Y: "y"}, LayerABLine{SlopeValues: []float64{1}, InterceptValues: []float64{0}}),
+	} {
+		buf.Reset()
+		c := render(p)
+		if n := len(rulePaths(c)); n != 0 {
+			t.Errorf("got %d lines on non-linear scale, want 0", n)
+		}
+		if !strings.Contains(buf.String(), "LayerABLine requires linear x and y scales") {
+			t.Errorf("got warnings %q, want LayerABLine warning", buf.String())
+		}
+	}
+}
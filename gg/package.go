@@ -105,6 +105,11 @@
 // of the plot width or height, whichever is smaller). The default
 // ranger ranges from 1% (0.01) to 10% (0.1).
 //
+// "angle", "hjust", "vjust", "nudgex", and "nudgey" give the
+// rotation, justification, and offset of text labels, as described
+// by LayerText. Their data are already visual values, so they default
+// to identity scales.
+//
 // Related work
 //
 // gg draws ideas and inspiration from many sources. The core
@@ -0,0 +1,480 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/aclements/go-gg/generic/slice"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// WritePDF renders p to w as a single-page PDF document of the given
+// width and height in points. Text is drawn using a subset of an
+// embedded font, so the document is self-contained.
+func (p *Plot) WritePDF(w io.Writer, width, height int) error {
+	c := newPDFCanvas(float64(width), float64(height))
+	p.Render(c, float64(width), float64(height))
+	return c.writeTo(w)
+}
+
+// pdfCanvas is a canvas that records a PDF content stream and the
+// resources it uses.
+type pdfCanvas struct {
+	width, height float64
+	content       bytes.Buffer
+
+	// gstates maps fill and stroke alpha values to the index of
+	// the ExtGState resource that sets them in gstateList.
+	gstates    map[[2]float64]int
+	gstateList [][2]float64
+
+	images []image.Image
+
+	// fonts are the fonts used by c, in order of first use. Font
+	// i is the resource /Fi.
+	fonts []*pdfFont
+}
+
+// A pdfFont records the glyphs used from a font and the rune each
+// glyph was first used for.
+type pdfFont struct {
+	font   *Font
+	glyphs map[sfnt.GlyphIndex]rune
+}
+
+func newPDFCanvas(width, height float64) *pdfCanvas {
+	c := &pdfCanvas{
+		width:   width,
+		height:  height,
+		gstates: make(map[[2]float64]int),
+	}
+	// Flip the coordinate system so the origin is at the top
+	// left, like the other canvases.
+	fmt.Fprintf(&c.content, "1 0 0 -1 0 %s cm\n", pdfNum(height))
+	// Use round joins to match the other canvases.
+	c.content.WriteString("1 j\n")
+	return c
+}
+
+// pdfNum formats x compactly for a PDF content stream.
+func pdfNum(x float64) string {
+	s := strconv.FormatFloat(x, 'f', 3, 64)
+	for s[len(s)-1] == '0' {
+		s = s[:len(s)-1]
+	}
+	if s[len(s)-1] == '.' {
+		s = s[:len(s)-1]
+	}
+	if s == "-0" {
+		s = "0"
+	}
+	return s
+}
+
+// pdfColor returns the non-premultiplied RGB components of c as PDF
+// numbers and c's alpha.
+func pdfColor(c color.Color) (rgb string, alpha float64) {
+	nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+	rgb = pdfNum(float64(nc.R)/255) + " " + pdfNum(float64(nc.G)/255) + " " + pdfNum(float64(nc.B)/255)
+	return rgb, float64(nc.A) / 255
+}
+
+// setAlpha sets the fill and stroke alpha of the graphics state.
+func (c *pdfCanvas) setAlpha(fill, stroke float64) {
+	if fill == 1 && stroke == 1 {
+		return
+	}
+	k := [2]float64{fill, stroke}
+	i, ok := c.gstates[k]
+	if !ok {
+		i = len(c.gstateList)
+		c.gstates[k] = i
+		c.gstateList = append(c.gstateList, k)
+	}
+	fmt.Fprintf(&c.content, "/GS%d gs\n", i)
+}
+
+func (c *pdfCanvas) Path(p *Path, style PathStyle) {
+	fill, stroke := isPaint(style.Fill), isPaint(style.Stroke) && style.StrokeWidth > 0
+	if len(p.Segs) == 0 || !fill && !stroke {
+		return
+	}
+
+	b := &c.content
+	b.WriteString("q\n")
+	fillAlpha, strokeAlpha := 1.0, 1.0
+	if fill {
+		var rgb string
+		rgb, fillAlpha = pdfColor(style.Fill)
+		b.WriteString(rgb + " rg\n")
+	}
+	if stroke {
+		var rgb string
+		rgb, strokeAlpha = pdfColor(style.Stroke)
+		b.WriteString(rgb + " RG " + pdfNum(style.StrokeWidth) + " w\n")
+		if len(style.Dash) > 0 {
+			b.WriteString("[")
+			for i, d := range style.Dash {
+				if i > 0 {
+					b.WriteString(" ")
+				}
+				b.WriteString(pdfNum(d))
+			}
+			b.WriteString("] 0 d\n")
+		}
+	}
+	c.setAlpha(fillAlpha, strokeAlpha)
+
+	for _, seg := range p.Segs {
+		pt := seg.Pts
+		switch seg.Op {
+		case PathMoveTo:
+			fmt.Fprintf(b, "%s %s m\n", pdfNum(pt[0].X), pdfNum(pt[0].Y))
+		case PathLineTo:
+			fmt.Fprintf(b, "%s %s l\n", pdfNum(pt[0].X), pdfNum(pt[0].Y))
+		case PathCubeTo:
+			fmt.Fprintf(b, "%s %s %s %s %s %s c\n", pdfNum(pt[1].X), pdfNum(pt[1].Y), pdfNum(pt[2].X), pdfNum(pt[2].Y), pdfNum(pt[0].X), pdfNum(pt[0].Y))
+		case PathClose:
+			b.WriteString("h\n")
+		}
+	}
+
+	switch {
+	case fill && stroke:
+		b.WriteString("B\n")
+	case fill:
+		b.WriteString("f\n")
+	default:
+		b.WriteString("S\n")
+	}
+	b.WriteString("Q\n")
+}
+
+func (c *pdfCanvas) Circle(cx, cy, r float64, style PathStyle) {
+	c.Path(circlePath(cx, cy, r), style)
+}
+
+func (c *pdfCanvas) Rect(x, y, w, h float64, style PathStyle) {
+	c.Path(rectPath(x, y, w, h), style)
+}
+
+func (c *pdfCanvas) Text(x, y float64, text string, style TextStyle) {
+	f := style.Font.orDefault()
+	size := style.Size
+	if size == 0 {
+		size = fontSize
+	}
+	var fill color.Color = color.Black
+	if style.Fill != nil {
+		fill = style.Fill
+	}
+	glyphs, width := f.layout(size, text)
+	if len(glyphs) == 0 || !isPaint(fill) {
+		return
+	}
+
+	// Compute the text origin, as in rasterCanvas.Text.
+	var dx float64
+	switch style.Anchor {
+	case TextAnchorMiddle:
+		dx = -width / 2
+	case TextAnchorEnd:
+		dx = -width
+	}
+	dy := style.Baseline.shift() * size
+	th := style.Rotate * math.Pi / 180
+	sin, cos := math.Sin(th), math.Cos(th)
+	ox, oy := x+dx*cos-dy*sin, y+dx*sin+dy*cos
+
+	fi, pf := c.useFont(f)
+	b := &c.content
+	rgb, alpha := pdfColor(fill)
+	b.WriteString("q\n" + rgb + " rg\n")
+	c.setAlpha(alpha, 1)
+	// The text matrix undoes the page's Y flip.
+	fmt.Fprintf(b, "BT\n/F%d %s Tf\n%s %s %s %s %s %s Tm\n[<", fi, pdfNum(size),
+		pdfNum(cos), pdfNum(sin), pdfNum(sin), pdfNum(-cos), pdfNum(ox), pdfNum(oy))
+	var pen float64
+	for _, g := range glyphs {
+		if _, ok := pf.glyphs[g.idx]; !ok {
+			pf.glyphs[g.idx] = g.r
+		}
+		// Adjust for kerning, in thousandths of an em.
+		if adj := (pen - g.x) * 1000 / size; math.Abs(adj) > 0.01 {
+			fmt.Fprintf(b, "> %s <", pdfNum(adj))
+		}
+		fmt.Fprintf(b, "%04x", uint16(g.idx))
+		pen = g.x + g.adv
+		if g.idx == 0 {
+			// layout may have given .notdef a different
+			// advance than the font does.
+			pen = g.x + f.advance(0, size)
+		}
+	}
+	b.WriteString(">] TJ\nET\nQ\n")
+}
+
+func (c *pdfCanvas) Image(x, y, w, h float64, img image.Image) {
+	name := fmt.Sprintf("Im%d", len(c.images))
+	c.images = append(c.images, img)
+	// The image is drawn in the unit square with its first row
+	// at the top, so flip it back.
+	fmt.Fprintf(&c.content, "q\n%s 0 0 %s %s %s cm\n/%s Do\nQ\n", pdfNum(w), pdfNum(-h), pdfNum(x), pdfNum(y+h), name)
+}
+
+func (c *pdfCanvas) BeginGroup(clip *Rect) {
+	c.content.WriteString("q\n")
+	if clip != nil {
+		fmt.Fprintf(&c.content, "%s %s %s %s re W n\n", pdfNum(clip.X), pdfNum(clip.Y), pdfNum(clip.W), pdfNum(clip.H))
+	}
+}
+
+func (c *pdfCanvas) EndGroup() {
+	c.content.WriteString("Q\n")
+}
+
+// pdfWriter assembles the objects of a PDF document.
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int // offsets[i] is the offset of object i+1
+}
+
+// alloc reserves an object number.
+func (pw *pdfWriter) alloc() int {
+	pw.offsets = append(pw.offsets, -1)
+	return len(pw.offsets)
+}
+
+// obj writes object n with the given body.
+func (pw *pdfWriter) obj(n int, body string) {
+	pw.offsets[n-1] = pw.buf.Len()
+	fmt.Fprintf(&pw.buf, "%d 0 obj\n%s\nendobj\n", n, body)
+}
+
+// stream writes object n as a compressed stream with data and the
+// additional dictionary entries in dict.
+func (pw *pdfWriter) stream(n int, dict string, data []byte) {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(data)
+	zw.Close()
+	pw.offsets[n-1] = pw.buf.Len()
+	fmt.Fprintf(&pw.buf, "%d 0 obj\n<< %s /Length %d /Filter /FlateDecode >>\nstream\n", n, dict, z.Len())
+	pw.buf.Write(z.Bytes())
+	pw.buf.WriteString("\nendstream\nendobj\n")
+}
+
+func (c *pdfCanvas) writeTo(w io.Writer) error {
+	pw := new(pdfWriter)
+	pw.buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	catalog, pages, page, content := pw.alloc(), pw.alloc(), pw.alloc(), pw.alloc()
+
+	// Build the resource dictionary.
+	var res bytes.Buffer
+	res.WriteString("<< /ProcSet [/PDF /Text /ImageC]")
+	if len(c.fonts) > 0 {
+		res.WriteString(" /Font <<")
+		for i, pf := range c.fonts {
+			font, err := pf.write(pw)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&res, " /F%d %d 0 R", i, font)
+		}
+		res.WriteString(" >>")
+	}
+	if len(c.gstateList) > 0 {
+		res.WriteString(" /ExtGState <<")
+		for i, alphas := range c.gstateList {
+			fmt.Fprintf(&res, " /GS%d << /ca %s /CA %s >>", i, pdfNum(alphas[0]), pdfNum(alphas[1]))
+		}
+		res.WriteString(" >>")
+	}
+	if len(c.images) > 0 {
+		res.WriteString(" /XObject <<")
+		for i, img := range c.images {
+			fmt.Fprintf(&res, " /Im%d %d 0 R", i, writePDFImage(pw, img))
+		}
+		res.WriteString(" >>")
+	}
+	res.WriteString(" >>")
+
+	pw.obj(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages))
+	pw.obj(pages, fmt.Sprintf("<< /Type /Pages /Kids [%d 0 R] /Count 1 >>", page))
+	pw.obj(page, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources %s /Contents %d 0 R >>",
+		pages, pdfNum(c.width), pdfNum(c.height), res.String(), content))
+	pw.stream(content, "", c.content.Bytes())
+
+	// Write the cross-reference table and trailer.
+	xref := pw.buf.Len()
+	fmt.Fprintf(&pw.buf, "xref\n0 %d\n0000000000 65535 f \n", len(pw.offsets)+1)
+	for _, off := range pw.offsets {
+		fmt.Fprintf(&pw.buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&pw.buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(pw.offsets)+1, catalog, xref)
+
+	_, err := w.Write(pw.buf.Bytes())
+	return err
+}
+
+// writePDFImage writes img as an image XObject with a soft mask if
+// img is not opaque and returns its object number.
+func writePDFImage(pw *pdfWriter, img image.Image) int {
+	b := img.Bounds()
+	rgb := make([]byte, 0, 3*b.Dx()*b.Dy())
+	alpha := make([]byte, 0, b.Dx()*b.Dy())
+	opaque := true
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			rgb = append(rgb, c.R, c.G, c.B)
+			alpha = append(alpha, c.A)
+			opaque = opaque && c.A == 0xff
+		}
+	}
+
+	n := pw.alloc()
+	dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8", b.Dx(), b.Dy())
+	if !opaque {
+		mask := pw.alloc()
+		pw.stream(mask, fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8", b.Dx(), b.Dy()), alpha)
+		dict += fmt.Sprintf(" /SMask %d 0 R", mask)
+	}
+	pw.stream(n, dict, rgb)
+	return n
+}
+
+// useFont returns the resource index and glyph record of font f,
+// adding it to c's resources if necessary.
+func (c *pdfCanvas) useFont(f *Font) (int, *pdfFont) {
+	for i, pf := range c.fonts {
+		if pf.font == f {
+			return i, pf
+		}
+	}
+	pf := &pdfFont{font: f, glyphs: make(map[sfnt.GlyphIndex]rune)}
+	c.fonts = append(c.fonts, pf)
+	return len(c.fonts) - 1, pf
+}
+
+// write writes pf as a composite font containing only the glyphs
+// used from it and returns its object number.
+func (pf *pdfFont) write(pw *pdfWriter) (int, error) {
+	f, ttf := pf.font.font, pf.font.ttf
+	var buf sfnt.Buffer
+
+	gids := make([]sfnt.GlyphIndex, 0, len(pf.glyphs))
+	for gid := range pf.glyphs {
+		gids = append(gids, gid)
+	}
+	slice.Sort(gids)
+
+	subset, err := subsetTrueType(ttf, gids)
+	if err != nil {
+		return 0, err
+	}
+
+	// Metrics in thousandths of an em.
+	ppem := fixed.I(1000)
+	em := func(x fixed.Int26_6) string { return pdfNum(float64(x) / 64) }
+	var widths bytes.Buffer
+	for _, gid := range gids {
+		adv, err := f.GlyphAdvance(&buf, gid, ppem, font.HintingNone)
+		if err != nil {
+			return 0, err
+		}
+		fmt.Fprintf(&widths, "%d [%s] ", gid, em(adv))
+	}
+	bounds, err := f.Bounds(&buf, ppem, font.HintingNone)
+	if err != nil {
+		return 0, err
+	}
+	metrics, err := f.Metrics(&buf, ppem, font.HintingNone)
+	if err != nil {
+		return 0, err
+	}
+	capHeight := metrics.CapHeight
+	if capHeight == 0 {
+		capHeight = metrics.Ascent
+	}
+
+	// Subset fonts are named with a tag derived from the subset.
+	h := fnv.New32a()
+	for _, gid := range gids {
+		fmt.Fprintf(h, "%d,", gid)
+	}
+	tag, hv := make([]byte, 6), h.Sum32()
+	for i := range tag {
+		tag[i] = 'A' + byte(hv%26)
+		hv /= 26
+	}
+	name := string(tag) + "+" + pdfName(pf.font.name)
+
+	type0, cidFont, desc, file, toUnicode := pw.alloc(), pw.alloc(), pw.alloc(), pw.alloc(), pw.alloc()
+	pw.obj(type0, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>", name, cidFont, toUnicode))
+	pw.obj(cidFont, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /CIDToGIDMap /Identity /W [%s] >>", name, desc, widths.String()))
+	// Font bounds are in Y-down coordinates.
+	pw.obj(desc, fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 4 /FontBBox [%s %s %s %s] /ItalicAngle 0 /Ascent %s /Descent %s /CapHeight %s /StemV 80 /FontFile2 %d 0 R >>",
+		name, em(bounds.Min.X), em(-bounds.Max.Y), em(bounds.Max.X), em(-bounds.Min.Y),
+		em(metrics.Ascent), em(-metrics.Descent), em(capHeight), file))
+	pw.stream(file, fmt.Sprintf("/Length1 %d", len(subset)), subset)
+	pw.stream(toUnicode, "", pf.toUnicodeCMap(gids))
+	return type0, nil
+}
+
+// pdfName returns s with characters that aren't allowed in a PDF name
+// object removed.
+func pdfName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || strings.ContainsRune("/%()<>[]{}#", r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// toUnicodeCMap returns a CMap that maps the glyphs in gids back to
+// the runes they were used for, so text can be extracted from the
+// PDF.
+func (pf *pdfFont) toUnicodeCMap(gids []sfnt.GlyphIndex) []byte {
+	var b bytes.Buffer
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	// bfchar blocks are limited to 100 entries.
+	for len(gids) > 0 {
+		n := len(gids)
+		if n > 100 {
+			n = 100
+		}
+		fmt.Fprintf(&b, "%d beginbfchar\n", n)
+		for _, gid := range gids[:n] {
+			fmt.Fprintf(&b, "<%04x> <", uint16(gid))
+			for _, u := range utf16.Encode([]rune{pf.glyphs[gid]}) {
+				fmt.Fprintf(&b, "%04x", u)
+			}
+			b.WriteString(">\n")
+		}
+		b.WriteString("endbfchar\n")
+		gids = gids[n:]
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return b.Bytes()
+}
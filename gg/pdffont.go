@@ -0,0 +1,240 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// subsetTrueType returns a TrueType font containing only the tables
+// of ttf needed for embedding in a PDF or parsing as a font and only the outlines of the
+// glyphs in gids and the glyphs they are composed of. Glyph indexes
+// are preserved, so the subset can be used with an identity
+// CID-to-GID mapping.
+func subsetTrueType(ttf []byte, gids []sfnt.GlyphIndex) ([]byte, error) {
+	be := binary.BigEndian
+	tables, err := trueTypeTables(ttf)
+	if err != nil {
+		return nil, err
+	}
+	offsets, err := trueTypeGlyphOffsets(tables)
+	if err != nil {
+		return nil, err
+	}
+	head, glyf := tables["head"], tables["glyf"]
+	numGlyphs := len(offsets) - 1
+
+	glyph := func(gid int) ([]byte, error) {
+		if gid >= numGlyphs || offsets[gid] > offsets[gid+1] || int(offsets[gid+1]) > len(glyf) {
+			return nil, errBadTrueType
+		}
+		return glyf[offsets[gid]:offsets[gid+1]], nil
+	}
+
+	// Find the glyphs to keep, including the components of
+	// composite glyphs. Glyph 0 is the .notdef glyph and must
+	// always be present.
+	keep := map[int]bool{0: true}
+	work := []int{0}
+	for _, gid := range gids {
+		work = append(work, int(gid))
+	}
+	for len(work) > 0 {
+		gid := work[len(work)-1]
+		work = work[:len(work)-1]
+		keep[gid] = true
+		data, err := glyph(gid)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) < 10 || int16(be.Uint16(data)) >= 0 {
+			// Empty or simple glyph.
+			continue
+		}
+		// Walk the components of the composite glyph.
+		const (
+			argsAreWords  = 0x0001
+			haveScale     = 0x0008
+			moreComps     = 0x0020
+			haveXYScale   = 0x0040
+			haveTwoByTwo  = 0x0080
+			componentSize = 4
+		)
+		for p := 10; ; {
+			if len(data) < p+componentSize {
+				return nil, errBadTrueType
+			}
+			flags, comp := be.Uint16(data[p:]), int(be.Uint16(data[p+2:]))
+			if !keep[comp] {
+				work = append(work, comp)
+			}
+			p += componentSize
+			if flags&argsAreWords != 0 {
+				p += 4
+			} else {
+				p += 2
+			}
+			switch {
+			case flags&haveScale != 0:
+				p += 2
+			case flags&haveXYScale != 0:
+				p += 4
+			case flags&haveTwoByTwo != 0:
+				p += 8
+			}
+			if flags&moreComps == 0 {
+				break
+			}
+		}
+	}
+
+	// Build the new glyf and loca tables. Glyphs that aren't kept
+	// are empty.
+	var newGlyf []byte
+	newLoca := make([]byte, 4*(numGlyphs+1))
+	for gid := 0; gid < numGlyphs; gid++ {
+		be.PutUint32(newLoca[4*gid:], uint32(len(newGlyf)))
+		if !keep[gid] {
+			continue
+		}
+		data, _ := glyph(gid)
+		newGlyf = append(newGlyf, data...)
+		for len(newGlyf)%4 != 0 {
+			newGlyf = append(newGlyf, 0)
+		}
+	}
+	be.PutUint32(newLoca[4*numGlyphs:], uint32(len(newGlyf)))
+
+	// Use the long loca format and clear the checksum adjustment,
+	// which is not required by PDF consumers.
+	newHead := append([]byte(nil), head...)
+	be.PutUint16(newHead[50:], 1)
+	be.PutUint32(newHead[8:], 0)
+
+	out := map[string][]byte{
+		"head": newHead,
+		"loca": newLoca,
+		"glyf": newGlyf,
+	}
+	for _, tag := range []string{"cmap", "cvt ", "fpgm", "hhea", "hmtx", "maxp", "prep"} {
+		if t, ok := tables[tag]; ok {
+			out[tag] = t
+		}
+	}
+	// Keep the post table's header, but drop the glyph names by
+	// switching it to version 3.
+	if post := tables["post"]; len(post) >= 32 {
+		newPost := append([]byte(nil), post[:32]...)
+		be.PutUint32(newPost, 0x00030000)
+		out["post"] = newPost
+	}
+	return writeTrueType(out), nil
+}
+
+var errBadTrueType = errors.New("malformed TrueType font")
+
+// trueTypeTables returns the tables of TrueType font ttf, indexed by
+// tag.
+func trueTypeTables(ttf []byte) (map[string][]byte, error) {
+	be := binary.BigEndian
+	if len(ttf) < 12 {
+		return nil, errBadTrueType
+	}
+	tables := make(map[string][]byte)
+	numTables := int(be.Uint16(ttf[4:]))
+	if len(ttf) < 12+16*numTables {
+		return nil, errBadTrueType
+	}
+	for i := 0; i < numTables; i++ {
+		rec := ttf[12+16*i:]
+		off, length := be.Uint32(rec[8:]), be.Uint32(rec[12:])
+		if uint64(off)+uint64(length) > uint64(len(ttf)) {
+			return nil, errBadTrueType
+		}
+		tables[string(rec[:4])] = ttf[off : off+length]
+	}
+	return tables, nil
+}
+
+// trueTypeGlyphOffsets returns the offsets of the glyphs in the glyf
+// table of a TrueType font with the given tables. Glyph i is at
+// offsets[i]:offsets[i+1].
+func trueTypeGlyphOffsets(tables map[string][]byte) ([]uint32, error) {
+	be := binary.BigEndian
+	head, maxp, loca := tables["head"], tables["maxp"], tables["loca"]
+	if len(head) < 54 || len(maxp) < 6 || loca == nil || tables["glyf"] == nil {
+		return nil, errBadTrueType
+	}
+	numGlyphs := int(be.Uint16(maxp[4:]))
+	longLoca := be.Uint16(head[50:]) != 0
+	offsets := make([]uint32, numGlyphs+1)
+	for i := range offsets {
+		if longLoca {
+			if len(loca) < 4*i+4 {
+				return nil, errBadTrueType
+			}
+			offsets[i] = be.Uint32(loca[4*i:])
+		} else {
+			if len(loca) < 2*i+2 {
+				return nil, errBadTrueType
+			}
+			offsets[i] = 2 * uint32(be.Uint16(loca[2*i:]))
+		}
+	}
+	return offsets, nil
+}
+
+// writeTrueType returns a TrueType font containing tables, indexed by
+// tag.
+func writeTrueType(tables map[string][]byte) []byte {
+	be := binary.BigEndian
+	// Tables must be sorted by tag.
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	n := len(tags)
+	entrySelector := 0
+	for 1<<uint(entrySelector+1) <= n {
+		entrySelector++
+	}
+	searchRange := 16 << uint(entrySelector)
+
+	buf := make([]byte, 12+16*n)
+	be.PutUint32(buf[0:], 0x00010000)
+	be.PutUint16(buf[4:], uint16(n))
+	be.PutUint16(buf[6:], uint16(searchRange))
+	be.PutUint16(buf[8:], uint16(entrySelector))
+	be.PutUint16(buf[10:], uint16(16*n-searchRange))
+	for i, tag := range tags {
+		data := tables[tag]
+		rec := buf[12+16*i:]
+		copy(rec, tag)
+		be.PutUint32(rec[4:], ttfChecksum(data))
+		be.PutUint32(rec[8:], uint32(len(buf)))
+		be.PutUint32(rec[12:], uint32(len(data)))
+		buf = append(buf, data...)
+		for len(buf)%4 != 0 {
+			buf = append(buf, 0)
+		}
+	}
+	return buf
+}
+
+// ttfChecksum returns the TrueType table checksum of data.
+func ttfChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
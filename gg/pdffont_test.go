@@ -0,0 +1,164 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import (
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// checkSubset parses subset, a subset of ttf containing the glyphs
+// for the runes in keep, and checks that those glyphs are unchanged
+// and the glyphs for the runes in drop are empty.
+func checkSubset(t *testing.T, ttf, subset []byte, keep, drop string) {
+	f, err := sfnt.Parse(ttf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := sfnt.Parse(subset)
+	if err != nil {
+		t.Fatalf("parsing subset: %v", err)
+	}
+	if sub.NumGlyphs() != f.NumGlyphs() {
+		t.Errorf("subset has %d glyphs, want %d", sub.NumGlyphs(), f.NumGlyphs())
+	}
+
+	var buf sfnt.Buffer
+	ppem := fixed.I(1000)
+	outline := func(f *sfnt.Font, gid sfnt.GlyphIndex) sfnt.Segments {
+		segs, err := f.LoadGlyph(&buf, gid, ppem, nil)
+		if err != nil {
+			t.Fatalf("loading glyph %d: %v", gid, err)
+		}
+		// segs is only valid until the next use of buf.
+		return append(sfnt.Segments(nil), segs...)
+	}
+	for _, r := range keep + drop {
+		gid, err := f.GlyphIndex(&buf, r)
+		if err != nil || gid == 0 {
+			t.Fatalf("no glyph for %q", r)
+		}
+		// The cmap and metrics are unchanged.
+		if subGID, _ := sub.GlyphIndex(&buf, r); subGID != gid {
+			t.Errorf("%q is glyph %d in subset, want %d", r, subGID, gid)
+		}
+		adv, _ := f.GlyphAdvance(&buf, gid, ppem, font.HintingNone)
+		if subAdv, _ := sub.GlyphAdvance(&buf, gid, ppem, font.HintingNone); subAdv != adv {
+			t.Errorf("%q has advance %v in subset, want %v", r, subAdv, adv)
+		}
+
+		want := outline(f, gid)
+		got := outline(sub, gid)
+		if len(want) == 0 {
+			t.Fatalf("glyph for %q is empty", r)
+		}
+		if !strings.ContainsRune(keep, r) {
+			want = nil
+		}
+		if len(got) != len(want) {
+			t.Errorf("%q has %d segments in subset, want %d", r, len(got), len(want))
+		} else if len(want) > 0 && !reflect.DeepEqual(got, want) {
+			t.Errorf("%q has outline %v in subset, want %v", r, got, want)
+		}
+	}
+	// .notdef is always kept.
+	if len(outline(sub, 0)) == 0 {
+		t.Errorf(".notdef glyph is empty in subset")
+	}
+}
+
+func TestSubsetTrueType(t *testing.T) {
+	f, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf sfnt.Buffer
+	var gids []sfnt.GlyphIndex
+	for _, r := range "Go" {
+		gid, _ := f.GlyphIndex(&buf, r)
+		gids = append(gids, gid)
+	}
+	subset, err := subsetTrueType(goregular.TTF, gids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subset) >= len(goregular.TTF)/2 {
+		t.Errorf("subset is %d bytes, want much less than %d", len(subset), len(goregular.TTF))
+	}
+	checkSubset(t, goregular.TTF, subset, "Go", "gx")
+}
+
+func TestSubsetTrueTypeComposite(t *testing.T) {
+	// The Go fonts have no composite glyphs, so replace the glyph
+	// for 'c' with a composite of 'a' and 'b'.
+	be := binary.BigEndian
+	f, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf sfnt.Buffer
+	ga, _ := f.GlyphIndex(&buf, 'a')
+	gb, _ := f.GlyphIndex(&buf, 'b')
+	gc, _ := f.GlyphIndex(&buf, 'c')
+
+	tables, err := trueTypeTables(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offsets, err := trueTypeGlyphOffsets(tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	glyf := tables["glyf"]
+	composite := make([]byte, 10)
+	be.PutUint16(composite, 0xffff)
+	copy(composite[2:], glyf[offsets[ga]+2:offsets[ga]+10])
+	composite = append(composite,
+		// 'a' at word offset (0, 0), with more components.
+		0x00, 0x23, byte(ga>>8), byte(ga), 0, 0, 0, 0,
+		// 'b' at byte offset (10, 0), scaled by 1.0.
+		0x00, 0x0a, byte(gb>>8), byte(gb), 10, 0, 0x40, 0x00)
+
+	var newGlyf []byte
+	newLoca := make([]byte, 4*len(offsets))
+	for gid := 0; gid < len(offsets)-1; gid++ {
+		be.PutUint32(newLoca[4*gid:], uint32(len(newGlyf)))
+		if gid == int(gc) {
+			newGlyf = append(newGlyf, composite...)
+		} else {
+			newGlyf = append(newGlyf, glyf[offsets[gid]:offsets[gid+1]]...)
+		}
+		for len(newGlyf)%4 != 0 {
+			newGlyf = append(newGlyf, 0)
+		}
+	}
+	be.PutUint32(newLoca[4*(len(offsets)-1):], uint32(len(newGlyf)))
+	head := append([]byte(nil), tables["head"]...)
+	be.PutUint16(head[50:], 1)
+	tables["head"], tables["loca"], tables["glyf"] = head, newLoca, newGlyf
+	ttf := writeTrueType(tables)
+
+	// Subsetting 'c' keeps its components.
+	subset, err := subsetTrueType(ttf, []sfnt.GlyphIndex{gc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSubset(t, ttf, subset, "abc", "dx")
+}
+
+func TestSubsetTrueTypeMalformed(t *testing.T) {
+	for _, ttf := range [][]byte{nil, goregular.TTF[:100], goregular.TTF[:len(goregular.TTF)/2]} {
+		if _, err := subsetTrueType(ttf, nil); err == nil {
+			t.Errorf("subsetting %d-byte prefix of font: want error", len(ttf))
+		}
+	}
+}
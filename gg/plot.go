@@ -32,6 +32,7 @@ type Plot struct {
 	autoAxisLabels map[string][]string
 
 	title string
+	theme Theme
 
 	constNonce int
 }
@@ -48,6 +49,7 @@ func NewPlot(data table.Grouping) *Plot {
 		scaleSet:       make(map[scaleKey]bool),
 		axisLabels:     make(map[string]string),
 		autoAxisLabels: make(map[string][]string),
+		theme:          ThemeGray(),
 	}
 	return p
 }
@@ -81,21 +83,27 @@ func (p *Plot) Data() table.Grouping {
 //
 // TODO: Typically this should be used with PreScaled or physical types.
 func (p *Plot) Const(val interface{}) string {
+	col := p.tempCol("const")
+	p.SetData(table.MapTables(p.Data(), func(_ table.GroupID, t *table.Table) *table.Table {
+		return table.NewBuilder(t).AddConst(col, val).Done()
+	}))
+
+	return col
+}
+
+// tempCol returns a new column name that is not used by p's current
+// data. kind is included in the name for debugging.
+func (p *Plot) tempCol(kind string) string {
 	tab := p.Data()
 
 retry:
-	col := fmt.Sprintf("[gg-const-%d]", p.constNonce)
+	col := fmt.Sprintf("[gg-%s-%d]", kind, p.constNonce)
 	p.constNonce++
 	for _, col2 := range tab.Columns() {
 		if col == col2 {
 			goto retry
 		}
 	}
-
-	p.SetData(table.MapTables(tab, func(_ table.GroupID, t *table.Table) *table.Table {
-		return table.NewBuilder(t).AddConst(col, val).Done()
-	}))
-
 	return col
 }
 
@@ -148,11 +156,25 @@ func (p *Plot) getScales(aes string) scalerTree {
 	st, ok := p.scales[aes]
 	if !ok {
 		st = newScalerTree()
+		if unscaledAesthetics[aes] {
+			st.bind(table.RootGroupID, NewIdentityScale())
+		}
 		p.scales[aes] = st
 	}
 	return st
 }
 
+// unscaledAesthetics is the set of aesthetics whose data are already
+// visual values, such as text angles in degrees. These default to
+// identity scales.
+var unscaledAesthetics = map[string]bool{
+	"angle":  true,
+	"hjust":  true,
+	"vjust":  true,
+	"nudgex": true,
+	"nudgey": true,
+}
+
 func (p *Plot) copyScales(old, new table.GroupID) {
 	for _, st := range p.scales {
 		st.scales[new] = st.find(old)
@@ -191,9 +213,10 @@ func (p *Plot) GetScaleAt(aes string, gid table.GroupID) Scaler {
 }
 
 type scaledDataKey struct {
-	aes  string
-	data table.Grouping
-	col  string
+	aes       string
+	data      table.Grouping
+	col       string
+	untrained bool
 }
 
 // use binds a column of data to an aesthetic. It expands the domain
@@ -204,6 +227,26 @@ type scaledDataKey struct {
 //
 // TODO: Should aes be an enum?
 func (p *Plot) use(aes string, col string) *scaledData {
+	return p.useAs(aes, col, col)
+}
+
+// useAs is like use, but label gives the automatic axis label for
+// the data in col. If label is "", col does not contribute to the
+// axis label. This is useful for columns computed by layers.
+func (p *Plot) useAs(aes, col, label string) *scaledData {
+	return p.bind(aes, col, label, true)
+}
+
+// useUntrained is like use, but doesn't expand the domain of the
+// aesthetic's scale or contribute to the axis label. Values outside
+// the domain set by other data are mapped outside the aesthetic's
+// range.
+func (p *Plot) useUntrained(aes, col string) *scaledData {
+	return p.bind(aes, col, "", false)
+}
+
+// bind implements use, useAs, and useUntrained.
+func (p *Plot) bind(aes, col, label string, train bool) *scaledData {
 	if col == "" {
 		return nil
 	}
@@ -214,10 +257,13 @@ func (p *Plot) use(aes string, col string) *scaledData {
 	// scaler in the key. Or I could clean up the cache when the
 	// scale tree changes.
 
-	sd := p.scaledData[scaledDataKey{aes, p.Data(), col}]
+	key := scaledDataKey{aes, p.Data(), col, !train}
+	sd := p.scaledData[key]
 	if sd == nil {
 		// Construct the scaledData.
 		sd = &scaledData{
+			aes:  aes,
+			col:  col,
 			seqs: make(map[table.GroupID]scaledSeq),
 		}
 
@@ -237,7 +283,7 @@ func (p *Plot) use(aes string, col string) *scaledData {
 			p.scaleSet[scaleKey{gid, aes, scaler}] = true
 
 			// Train the scale.
-			if _, ok := seq.([]Unscaled); !ok {
+			if _, ok := seq.([]Unscaled); !ok && train {
 				scaler.ExpandDomain(seq)
 			}
 
@@ -245,12 +291,12 @@ func (p *Plot) use(aes string, col string) *scaledData {
 			sd.seqs[gid] = scaledSeq{seq, scaler}
 		}
 
-		p.scaledData[scaledDataKey{aes, p.Data(), col}] = sd
+		p.scaledData[key] = sd
 	}
 
 	// Update axis labels.
-	if aes == "x" || aes == "y" {
-		p.autoAxisLabels[aes] = append(p.autoAxisLabels[aes], col)
+	if label != "" && (aes == "x" || aes == "y") {
+		p.autoAxisLabels[aes] = append(p.autoAxisLabels[aes], label)
 	}
 
 	return sd
@@ -291,6 +337,9 @@ func (p *Plot) Add(plotters ...Plotter) *Plot {
 // Plot. By default, Plot constructs automatic axis labels from column
 // names, but AxisLabel lets callers override these.
 //
+// axis may also be a non-positional aesthetic such as "stroke", in
+// which case AxisLabel sets the title of that aesthetic's legend.
+//
 // TODO: Should labels be attached to aesthetics, generally?
 //
 // TODO: Should this really be a Plotter or just a method of Plot?
@@ -0,0 +1,365 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+
+	"github.com/aclements/go-gg/generic/slice"
+	"github.com/aclements/go-gg/table"
+)
+
+// A Position adjusts the positions of marks from different groups
+// that would otherwise overlap. Layers that support position
+// adjustments have a Position field.
+//
+// Positions that change the extent of the data, such as
+// PositionStack, adjust the data when the layer is added to the
+// plot, so the adjusted data is used to train the scales. Positions
+// that move marks within the band around each position, such as
+// PositionDodge, adjust them when the plot is rendered.
+//
+// The band around a position is the smallest distance between
+// distinct positions of the layer in a subplot. For ordinal scales,
+// this is the distance between adjacent levels.
+type Position interface {
+	// stack adds columns to p's data that give the lower and
+	// upper bounds of each value in column y after adjusting for
+	// other values at the same position in column x, and returns
+	// their names. If the Position doesn't adjust data, stack
+	// returns "", "".
+	stack(p *Plot, x, y string) (lo, hi string)
+
+	// place adjusts the rendered positions of marks in g.
+	place(g *placement)
+}
+
+// PositionIdentity leaves marks where they are, even if they
+// overlap.
+type PositionIdentity struct{}
+
+func (PositionIdentity) stack(p *Plot, x, y string) (lo, hi string) { return "", "" }
+func (PositionIdentity) place(g *placement)                         {}
+
+// PositionStack stacks the values of each group on top of the values
+// of the previous groups at the same position. Positive and negative
+// values are stacked separately, away from zero.
+type PositionStack struct{}
+
+func (PositionStack) stack(p *Plot, x, y string) (lo, hi string) {
+	return stackColumns(p, x, y, false)
+}
+
+func (PositionStack) place(g *placement) {}
+
+// PositionFill is like PositionStack, but normalizes each stack so
+// positive values stack up to 1 and negative values stack down to -1.
+// This shows the proportion each group contributes at each position.
+type PositionFill struct{}
+
+func (PositionFill) stack(p *Plot, x, y string) (lo, hi string) {
+	return stackColumns(p, x, y, true)
+}
+
+func (PositionFill) place(g *placement) {}
+
+// PositionDodge places the marks of each group side by side within
+// the band around each position. Only groups with marks at the same
+// position are placed side by side, so a mark with no other groups
+// at its position stays centered.
+type PositionDodge struct {
+	// Width is the total width of the marks of all groups at a
+	// position as a fraction of the band. If Width is 0, it
+	// defaults to the width of the layer's marks, such as
+	// LayerBars.Width, or 0.9 for layers without a width.
+	Width float64
+}
+
+func (d PositionDodge) stack(p *Plot, x, y string) (lo, hi string) { return "", "" }
+
+func (d PositionDodge) place(g *placement) {
+	width := d.Width
+	if width == 0 {
+		width = g.width
+	}
+	// Find the groups at each position. Marks are only dodged
+	// around groups at the same position, so a position with a
+	// single group keeps its mark centered.
+	type slot struct {
+		pos float64
+		k   int
+	}
+	rank := make(map[slot]int)
+	count := make(map[float64]int)
+	maxCount := 1
+	for k, pos := range g.pos {
+		for _, p := range pos {
+			if _, ok := rank[slot{p, k}]; ok {
+				continue
+			}
+			rank[slot{p, k}] = count[p]
+			count[p]++
+			if count[p] > maxCount {
+				maxCount = count[p]
+			}
+		}
+	}
+
+	total := width * g.posBand
+	g.slot = total / float64(maxCount)
+	g.dpos = make([][]float64, len(g.pos))
+	for k, pos := range g.pos {
+		g.dpos[k] = make([]float64, len(pos))
+		for i, p := range pos {
+			g.dpos[k][i] = (float64(rank[slot{p, k}]) + 0.5 - float64(count[p])/2) * g.slot
+		}
+	}
+}
+
+// PositionJitter randomly moves each mark within the band around its
+// position. This is useful for reducing overplotting of points with
+// discrete or rounded values.
+//
+// Jitter is pseudo-random, so a plot rendered multiple times with the
+// same Seed will have the same jitter.
+type PositionJitter struct {
+	// Width and Height are the amount of horizontal and vertical
+	// jitter as a fraction of the band along each axis. Each
+	// mark is moved by at most half this amount in either
+	// direction. If both are 0, Width defaults to 0.8 and Height
+	// to 0.
+	Width, Height float64
+
+	// Seed seeds the pseudo-random jitter.
+	Seed int64
+}
+
+func (j PositionJitter) stack(p *Plot, x, y string) (lo, hi string) { return "", "" }
+
+func (j PositionJitter) place(g *placement) {
+	width, height := j.Width, j.Height
+	if width == 0 && height == 0 {
+		width = 0.8
+	}
+	rng := rand.New(rand.NewSource(j.Seed))
+	jitter := func(coords [][]float64, amount float64) [][]float64 {
+		if amount == 0 {
+			return nil
+		}
+		offs := make([][]float64, len(coords))
+		for k, c := range coords {
+			offs[k] = make([]float64, len(c))
+			for i := range c {
+				offs[k][i] = (rng.Float64() - 0.5) * amount
+			}
+		}
+		return offs
+	}
+	g.dpos = jitter(g.pos, width*g.posBand)
+	if g.val != nil {
+		g.dval = jitter(g.val, height*g.valBand)
+	}
+}
+
+// stackColumns stacks the values in column y of each group on the
+// values of the previous groups in the same subplot at the same
+// position in column x. It adds columns giving the lower and upper
+// bound of each stacked value to p's data and returns their names. If
+// normalize is true, the stacks at each position are scaled to
+// [-1, 1].
+func stackColumns(p *Plot, x, y string, normalize bool) (lo, hi string) {
+	type sums struct{ pos, neg map[interface{}]float64 }
+	newSums := func() sums {
+		return sums{make(map[interface{}]float64), make(map[interface{}]float64)}
+	}
+	// stackTable stacks the rows of t on s and returns the
+	// stacked bounds.
+	stackTable := func(s sums, t *table.Table) (los, his []float64) {
+		var vals []float64
+		slice.Convert(&vals, t.MustColumn(y))
+		xv := reflect.ValueOf(t.MustColumn(x))
+		los, his = make([]float64, len(vals)), make([]float64, len(vals))
+		for i, v := range vals {
+			key := xv.Index(i).Interface()
+			switch {
+			case v >= 0:
+				los[i] = s.pos[key]
+				s.pos[key] += v
+				his[i] = s.pos[key]
+			case v < 0:
+				his[i] = s.neg[key]
+				s.neg[key] += v
+				los[i] = s.neg[key]
+			default:
+				los[i], his[i] = math.NaN(), math.NaN()
+			}
+		}
+		return los, his
+	}
+
+	// Compute the totals at each position for normalization.
+	totals := make(map[*subplot]sums)
+	if normalize {
+		for _, gid := range p.Data().Tables() {
+			sub := subplotOf(gid)
+			s, ok := totals[sub]
+			if !ok {
+				s = newSums()
+				totals[sub] = s
+			}
+			stackTable(s, p.Data().Table(gid))
+		}
+	}
+
+	lo, hi = p.tempCol("stack-lo"), p.tempCol("stack-hi")
+	subplots := make(map[*subplot]sums)
+	p.SetData(table.MapTables(p.Data(), func(gid table.GroupID, t *table.Table) *table.Table {
+		sub := subplotOf(gid)
+		s, ok := subplots[sub]
+		if !ok {
+			s = newSums()
+			subplots[sub] = s
+		}
+		los, his := stackTable(s, t)
+		if normalize {
+			xv := reflect.ValueOf(t.MustColumn(x))
+			total := totals[sub]
+			for i := range los {
+				key := xv.Index(i).Interface()
+				div := total.pos[key]
+				if his[i] <= 0 && los[i] < 0 {
+					div = -total.neg[key]
+				}
+				if div != 0 {
+					los[i], his[i] = los[i]/div, his[i]/div
+				}
+			}
+		}
+		return table.NewBuilder(t).Add(lo, los).Add(hi, his).Done()
+	}))
+	return lo, hi
+}
+
+// stackPosition adjusts p's data for position and returns the column
+// giving the adjusted position of each value in column y. If position
+// is nil or doesn't stack, it returns y.
+func stackPosition(p *Plot, position Position, x, y string) string {
+	if position == nil {
+		return y
+	}
+	if _, hi := position.stack(p, x, y); hi != "" {
+		return hi
+	}
+	return y
+}
+
+// defaultBandWidth is the default width of marks that fill a band,
+// as a fraction of the band.
+const defaultBandWidth = 0.9
+
+// A placement is the state for adjusting the rendered positions of
+// one layer's marks in one subplot. Coordinates are along the
+// "position" axis (usually x) and the "value" axis (usually y).
+type placement struct {
+	// pos and val are the mapped coordinates of each group. val
+	// is nil if the layer's values can't be adjusted.
+	pos, val [][]float64
+
+	// posBand and valBand are the band sizes along each axis in
+	// pixels.
+	posBand, valBand float64
+
+	// width is the width of the layer's marks as a fraction of
+	// posBand.
+	width float64
+
+	// dpos and dval are set by Position.place to the offsets to
+	// add to pos and val, or nil to leave them unchanged.
+	dpos, dval [][]float64
+
+	// slot is the width of each mark in pixels. It defaults to
+	// width*posBand, and Position.place may narrow it.
+	slot float64
+}
+
+// place adjusts the positions of the groups gids of a layer in this
+// subplot according to position. posSD and valSD are the layer's
+// position and value data; valSD may be nil. width is the width of
+// the layer's marks as a fraction of the band, and horizontal
+// indicates that the position axis is y rather than x.
+//
+// Until env.unplace is called, env.get returns the adjusted
+// coordinates for posSD and valSD.
+func (env *renderEnv) place(position Position, gids []table.GroupID, posSD, valSD *scaledData, width float64, horizontal bool) *placement {
+	g := &placement{width: width}
+	for _, gid := range gids {
+		env.gid = gid
+		g.pos = append(g.pos, env.get(posSD).([]float64))
+		if valSD != nil {
+			g.val = append(g.val, env.get(valSD).([]float64))
+		}
+	}
+	w, h := env.Size()
+	if horizontal {
+		w, h = h, w
+	}
+	g.posBand, g.valBand = bandWidth(g.pos, w), bandWidth(g.val, h)
+	g.slot = width * g.posBand
+
+	if position != nil {
+		position.place(g)
+	}
+
+	env.adjusted = make(map[renderCacheKey]table.Slice)
+	adjust := func(sd *scaledData, coords, offs [][]float64) {
+		if offs == nil {
+			return
+		}
+		for k, gid := range gids {
+			adj := make([]float64, len(coords[k]))
+			for i, c := range coords[k] {
+				adj[i] = c + offs[k][i]
+			}
+			env.adjusted[renderCacheKey{sd, gid}] = adj
+		}
+	}
+	adjust(posSD, g.pos, g.dpos)
+	adjust(valSD, g.val, g.dval)
+	return g
+}
+
+// unplace removes the adjustments made by place.
+func (env *renderEnv) unplace() {
+	env.adjusted = nil
+}
+
+// bandWidth returns the smallest distance between distinct
+// coordinates in coords, or def if there are fewer than two distinct
+// coordinates.
+func bandWidth(coords [][]float64, def float64) float64 {
+	var all []float64
+	for _, c := range coords {
+		all = append(all, c...)
+	}
+	if band := minGap(all); band > 0 {
+		return band
+	}
+	return def
+}
+
+// markPlaced draws each of the groups gids of m after adjusting the
+// coordinates in x and y according to position.
+func markPlaced(env *renderEnv, gids []table.GroupID, canvas Canvas, m marker, position Position, x, y *scaledData) {
+	if position != nil {
+		env.place(position, gids, x, y, defaultBandWidth, false)
+		defer env.unplace()
+	}
+	for _, gid := range gids {
+		env.gid = gid
+		m.mark(env, canvas)
+	}
+}
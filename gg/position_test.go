@@ -0,0 +1,103 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/aclements/go-gg/table"
+)
+
+func TestStackColumns(t *testing.T) {
+	nan := math.NaN()
+	tab := new(table.Builder).
+		Add("g", []string{"a", "a", "b", "b", "c", "c", "d"}).
+		Add("x", []int{1, 2, 1, 2, 1, 2, 1}).
+		Add("y", []float64{2, -1, -3, -2, 1, 4, nan}).
+		Done()
+
+	// Positive values stack up from 0 and negative values stack
+	// down from 0, each in group order. For normalization, the
+	// totals at x=1 are 3 and -3, and at x=2 are 4 and -3.
+	type bounds struct{ lo, hi []float64 }
+	for _, test := range []struct {
+		normalize bool
+		want      map[string]bounds
+	}{
+		{false, map[string]bounds{
+			"a": {[]float64{0, -1}, []float64{2, 0}},
+			"b": {[]float64{-3, -3}, []float64{0, -1}},
+			"c": {[]float64{2, 0}, []float64{3, 4}},
+			"d": {[]float64{nan}, []float64{nan}},
+		}},
+		{true, map[string]bounds{
+			"a": {[]float64{0, -1.0 / 3}, []float64{2.0 / 3, 0}},
+			"b": {[]float64{-1, -1}, []float64{0, -1.0 / 3}},
+			"c": {[]float64{2.0 / 3, 0}, []float64{1, 1}},
+			"d": {[]float64{nan}, []float64{nan}},
+		}},
+	} {
+		p := NewPlot(tab)
+		p.GroupBy("g")
+		lo, hi := stackColumns(p, "x", "y", test.normalize)
+		for _, gid := range p.Data().Tables() {
+			g := gid.Label().(string)
+			tab := p.Data().Table(gid)
+			los, his := tab.MustColumn(lo).([]float64), tab.MustColumn(hi).([]float64)
+			want := test.want[g]
+			if !floatsEqual(los, want.lo) || !floatsEqual(his, want.hi) {
+				t.Errorf("normalize %v: group %s stacked at %v to %v, want %v to %v", test.normalize, g, los, his, want.lo, want.hi)
+			}
+		}
+	}
+}
+
+// floatsEqual returns whether got and want are equal within rounding
+// error, treating NaNs as equal.
+func floatsEqual(got, want []float64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if math.IsNaN(got[i]) != math.IsNaN(want[i]) || math.Abs(got[i]-want[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPositionDodge(t *testing.T) {
+	// Three groups share x=10, group 0 repeats x=10, and groups 0
+	// and 2 are alone at x=20 and x=30.
+	pos := [][]float64{{10, 20, 10}, {10}, {10, 30}}
+	for _, test := range []struct {
+		dodge PositionDodge
+		slot  float64
+		dpos  [][]float64
+	}{
+		// By default, the marks fill the layer's width of the
+		// band, divided among the most groups at any position.
+		{PositionDodge{}, 3, [][]float64{{-3, 0, -3}, {0}, {3, 0}}},
+		{PositionDodge{Width: 0.6}, 2, [][]float64{{-2, 0, -2}, {0}, {2, 0}}},
+	} {
+		g := &placement{pos: pos, posBand: 10, width: 0.9}
+		test.dodge.place(g)
+		if g.slot != test.slot || !reflect.DeepEqual(g.dpos, test.dpos) {
+			t.Errorf("%+v: got slot %v and offsets %v, want %v and %v", test.dodge, g.slot, g.dpos, test.slot, test.dpos)
+		}
+	}
+
+	// Groups are ranked separately at each position, so a group
+	// alone at one position is centered there even if it's
+	// dodged elsewhere.
+	g := &placement{pos: [][]float64{{10}, {10, 20}, {20}}, posBand: 10, width: 1}
+	PositionDodge{}.place(g)
+	want := [][]float64{{-2.5}, {2.5, -2.5}, {2.5}}
+	if g.slot != 5 || !reflect.DeepEqual(g.dpos, want) {
+		t.Errorf("got slot %v and offsets %v, want 5 and %v", g.slot, g.dpos, want)
+	}
+}
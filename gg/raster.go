@@ -0,0 +1,396 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// WritePNG renders p to w as a PNG image of the given width and
+// height in pixels.
+func (p *Plot) WritePNG(w io.Writer, width, height int) error {
+	return png.Encode(w, p.RenderImage(width, height))
+}
+
+// RenderImage renders p to a new image of the given width and height
+// in pixels.
+func (p *Plot) RenderImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	p.Render(newRasterCanvas(img), float64(width), float64(height))
+	return img
+}
+
+// rasterCanvas is a canvas that draws to an in-memory image.
+type rasterCanvas struct {
+	img *image.RGBA
+
+	// clips is the stack of clip rectangles of the open groups.
+	// The last element is the current clip rectangle.
+	clips []image.Rectangle
+
+	z vector.Rasterizer
+}
+
+func newRasterCanvas(img *image.RGBA) *rasterCanvas {
+	return &rasterCanvas{img: img, clips: []image.Rectangle{img.Bounds()}}
+}
+
+func (c *rasterCanvas) clip() image.Rectangle {
+	return c.clips[len(c.clips)-1]
+}
+
+// polyline is a flattened subpath.
+type polyline struct {
+	pts    []Point
+	closed bool
+}
+
+// flatten approximates p with a sequence of polylines.
+func flatten(p *Path) []polyline {
+	var out []polyline
+	var cur *polyline
+	var pen Point
+	for _, seg := range p.Segs {
+		switch seg.Op {
+		case PathMoveTo:
+			out = append(out, polyline{pts: []Point{seg.Pts[0]}})
+			cur = &out[len(out)-1]
+			pen = seg.Pts[0]
+			continue
+		case PathClose:
+			if cur != nil {
+				cur.closed = true
+				pen = cur.pts[0]
+			}
+			cur = nil
+			continue
+		}
+		if cur == nil {
+			// Drawing after a close starts a new subpath
+			// at the current point.
+			out = append(out, polyline{pts: []Point{pen}})
+			cur = &out[len(out)-1]
+		}
+		switch seg.Op {
+		case PathLineTo:
+			cur.pts = append(cur.pts, seg.Pts[0])
+		case PathCubeTo:
+			p0, p1, p2, p3 := pen, seg.Pts[1], seg.Pts[2], seg.Pts[0]
+			// Pick the number of steps based on the length
+			// of the control polygon.
+			l := dist(p0, p1) + dist(p1, p2) + dist(p2, p3)
+			n := int(math.Ceil(math.Sqrt(l)))
+			if n < 1 {
+				n = 1
+			} else if n > 100 {
+				n = 100
+			}
+			for i := 1; i <= n; i++ {
+				t := float64(i) / float64(n)
+				mt := 1 - t
+				a, b, c, d := mt*mt*mt, 3*mt*mt*t, 3*mt*t*t, t*t*t
+				cur.pts = append(cur.pts, Point{
+					a*p0.X + b*p1.X + c*p2.X + d*p3.X,
+					a*p0.Y + b*p1.Y + c*p2.Y + d*p3.Y,
+				})
+			}
+		}
+		pen = seg.Pts[0]
+	}
+	return out
+}
+
+func dist(a, b Point) float64 {
+	return math.Hypot(b.X-a.X, b.Y-a.Y)
+}
+
+// dashes splits the polylines in lines into dashes according to
+// the dash pattern dash.
+func dashes(lines []polyline, dash []float64) []polyline {
+	var total float64
+	for _, d := range dash {
+		total += d
+	}
+	if total <= 0 {
+		return lines
+	}
+	var out []polyline
+	for _, l := range lines {
+		pts := l.pts
+		if l.closed && len(pts) > 0 {
+			pts = append(pts[:len(pts):len(pts)], pts[0])
+		}
+		// Walk the polyline, tracking our position in the
+		// dash pattern.
+		di, left, on := 0, dash[0], true
+		var cur []Point
+		if len(pts) > 0 {
+			cur = []Point{pts[0]}
+		}
+		for i := 1; i < len(pts); i++ {
+			p0, p1 := pts[i-1], pts[i]
+			segLen := dist(p0, p1)
+			pos := 0.0
+			for segLen-pos > left {
+				pos += left
+				t := pos / segLen
+				pt := Point{p0.X + t*(p1.X-p0.X), p0.Y + t*(p1.Y-p0.Y)}
+				if on {
+					out = append(out, polyline{pts: append(cur, pt)})
+				}
+				cur = []Point{pt}
+				on = !on
+				di = (di + 1) % len(dash)
+				left = dash[di]
+			}
+			left -= segLen - pos
+			cur = append(cur, p1)
+		}
+		if on && len(cur) > 1 {
+			out = append(out, polyline{pts: cur})
+		}
+	}
+	return out
+}
+
+// strokePolys returns polygons covering the stroke of lines with
+// width w. All of the returned polygons have the same orientation, so
+// their union can be rasterized in a single pass.
+func strokePolys(lines []polyline, w float64) [][]Point {
+	hw := w / 2
+	var polys [][]Point
+	add := func(poly []Point) {
+		if signedArea(poly) < 0 {
+			for i, j := 0, len(poly)-1; i < j; i, j = i+1, j-1 {
+				poly[i], poly[j] = poly[j], poly[i]
+			}
+		}
+		polys = append(polys, poly)
+	}
+	for _, l := range lines {
+		pts := l.pts
+		if l.closed && len(pts) > 0 {
+			pts = append(pts[:len(pts):len(pts)], pts[0])
+		}
+		for i := 1; i < len(pts); i++ {
+			p0, p1 := pts[i-1], pts[i]
+			d := dist(p0, p1)
+			if d == 0 {
+				continue
+			}
+			nx, ny := -(p1.Y-p0.Y)/d*hw, (p1.X-p0.X)/d*hw
+			add([]Point{
+				{p0.X + nx, p0.Y + ny}, {p1.X + nx, p1.Y + ny},
+				{p1.X - nx, p1.Y - ny}, {p0.X - nx, p0.Y - ny},
+			})
+		}
+		// Round joins.
+		start, end := 1, len(pts)-1
+		if l.closed {
+			start, end = 0, len(pts)
+		}
+		if hw >= 1 {
+			for i := start; i < end; i++ {
+				add(circlePoly(pts[i], hw))
+			}
+		}
+	}
+	return polys
+}
+
+func circlePoly(c Point, r float64) []Point {
+	n := int(math.Ceil(2*math.Pi*r/2)) + 4
+	poly := make([]Point, n)
+	for i := range poly {
+		th := 2 * math.Pi * float64(i) / float64(n)
+		poly[i] = Point{c.X + r*math.Cos(th), c.Y + r*math.Sin(th)}
+	}
+	return poly
+}
+
+func signedArea(poly []Point) float64 {
+	var a float64
+	for i, p := range poly {
+		q := poly[(i+1)%len(poly)]
+		a += p.X*q.Y - q.X*p.Y
+	}
+	return a / 2
+}
+
+// fill fills the union of polys with col, clipped to the current clip
+// rectangle.
+func (c *rasterCanvas) fill(polys [][]Point, col color.Color) {
+	if !isPaint(col) {
+		return
+	}
+	// Compute the bounds of the polygons so we only rasterize
+	// the affected part of the image.
+	minx, miny := math.Inf(1), math.Inf(1)
+	maxx, maxy := math.Inf(-1), math.Inf(-1)
+	for _, poly := range polys {
+		for _, p := range poly {
+			minx, maxx = math.Min(minx, p.X), math.Max(maxx, p.X)
+			miny, maxy = math.Min(miny, p.Y), math.Max(maxy, p.Y)
+		}
+	}
+	if minx > maxx {
+		return
+	}
+	// Clamp before converting to int in case of huge coordinates.
+	clip := c.clip()
+	clamp := func(v float64, lo, hi int) int {
+		return int(math.Max(float64(lo), math.Min(float64(hi), v)))
+	}
+	r := image.Rect(
+		clamp(math.Floor(minx), clip.Min.X, clip.Max.X),
+		clamp(math.Floor(miny), clip.Min.Y, clip.Max.Y),
+		clamp(math.Ceil(maxx), clip.Min.X, clip.Max.X),
+		clamp(math.Ceil(maxy), clip.Min.Y, clip.Max.Y),
+	)
+	if r.Empty() {
+		return
+	}
+
+	ox, oy := float64(r.Min.X), float64(r.Min.Y)
+	c.z.Reset(r.Dx(), r.Dy())
+	for _, poly := range polys {
+		if len(poly) < 3 {
+			continue
+		}
+		c.z.MoveTo(float32(poly[0].X-ox), float32(poly[0].Y-oy))
+		for _, p := range poly[1:] {
+			c.z.LineTo(float32(p.X-ox), float32(p.Y-oy))
+		}
+		c.z.ClosePath()
+	}
+	c.z.Draw(c.img, r, image.NewUniform(col), image.Point{})
+}
+
+func (c *rasterCanvas) Path(p *Path, style PathStyle) {
+	lines := flatten(p)
+	if isPaint(style.Fill) {
+		polys := make([][]Point, len(lines))
+		for i, l := range lines {
+			polys[i] = l.pts
+		}
+		c.fill(polys, style.Fill)
+	}
+	if isPaint(style.Stroke) && style.StrokeWidth > 0 {
+		if len(style.Dash) > 0 {
+			lines = dashes(lines, style.Dash)
+		}
+		c.fill(strokePolys(lines, style.StrokeWidth), style.Stroke)
+	}
+}
+
+func (c *rasterCanvas) Circle(cx, cy, r float64, style PathStyle) {
+	c.Path(circlePath(cx, cy, r), style)
+}
+
+func (c *rasterCanvas) Rect(x, y, w, h float64, style PathStyle) {
+	c.Path(rectPath(x, y, w, h), style)
+}
+
+func (c *rasterCanvas) Text(x, y float64, text string, style TextStyle) {
+	f := style.Font.orDefault()
+	size := style.Size
+	if size == 0 {
+		size = fontSize
+	}
+	var fill color.Color = color.Black
+	if style.Fill != nil {
+		fill = style.Fill
+	}
+	glyphs, width := f.layout(size, text)
+
+	// Compute the offset of the text origin from the anchor point
+	// in the text's coordinate system.
+	var dx float64
+	switch style.Anchor {
+	case TextAnchorMiddle:
+		dx = -width / 2
+	case TextAnchorEnd:
+		dx = -width
+	}
+	dy := style.Baseline.shift() * size
+
+	th := style.Rotate * math.Pi / 180
+	sin, cos := math.Sin(th), math.Cos(th)
+	xform := func(px, py float64) Point {
+		px, py = px+dx, py+dy
+		return Point{x + px*cos - py*sin, y + px*sin + py*cos}
+	}
+
+	var buf sfnt.Buffer
+	ppem := fixed.Int26_6(size * 64)
+	var polys [][]Point
+	for _, g := range glyphs {
+		segs, err := f.font.LoadGlyph(&buf, g.idx, ppem, nil)
+		if err != nil {
+			continue
+		}
+		var p Path
+		var pen Point
+		for _, seg := range segs {
+			a := func(i int) Point {
+				return xform(g.x+float64(seg.Args[i].X)/64, float64(seg.Args[i].Y)/64)
+			}
+			switch seg.Op {
+			case sfnt.SegmentOpMoveTo:
+				pen = a(0)
+				p.MoveTo(pen.X, pen.Y)
+			case sfnt.SegmentOpLineTo:
+				pen = a(0)
+				p.LineTo(pen.X, pen.Y)
+			case sfnt.SegmentOpQuadTo:
+				// Elevate to a cubic.
+				q, end := a(0), a(1)
+				p.CubeTo(pen.X+2.0/3*(q.X-pen.X), pen.Y+2.0/3*(q.Y-pen.Y),
+					end.X+2.0/3*(q.X-end.X), end.Y+2.0/3*(q.Y-end.Y),
+					end.X, end.Y)
+				pen = end
+			case sfnt.SegmentOpCubeTo:
+				c1, c2, end := a(0), a(1), a(2)
+				p.CubeTo(c1.X, c1.Y, c2.X, c2.Y, end.X, end.Y)
+				pen = end
+			}
+		}
+		for _, l := range flatten(&p) {
+			polys = append(polys, l.pts)
+		}
+	}
+	c.fill(polys, fill)
+}
+
+func (c *rasterCanvas) Image(x, y, w, h float64, img image.Image) {
+	dst := c.img.SubImage(c.clip()).(*image.RGBA)
+	r := image.Rect(round(x), round(y), round(x+w), round(y+h))
+	xdraw.NearestNeighbor.Scale(dst, r, img, img.Bounds(), xdraw.Over, nil)
+}
+
+func (c *rasterCanvas) BeginGroup(clip *Rect) {
+	r := c.clip()
+	if clip != nil {
+		r = r.Intersect(image.Rect(
+			int(math.Floor(clip.X)), int(math.Floor(clip.Y)),
+			int(math.Ceil(clip.X+clip.W)), int(math.Ceil(clip.Y+clip.H))))
+	}
+	c.clips = append(c.clips, r)
+}
+
+func (c *rasterCanvas) EndGroup() {
+	c.clips = c.clips[:len(c.clips)-1]
+}
@@ -0,0 +1,91 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+
+	"github.com/aclements/go-gg/table"
+)
+
+func TestRasterCanvas(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	c := newRasterCanvas(img)
+
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+	c.Rect(2, 2, 8, 8, PathStyle{Fill: red})
+	// Groups clip their contents.
+	c.BeginGroup(&Rect{0, 0, 5, 20})
+	c.Rect(0, 12, 20, 2, PathStyle{Fill: blue})
+	c.EndGroup()
+	// A horizontal line 2 pixels wide covers the rows on either
+	// side of it.
+	var p Path
+	p.MoveTo(0, 17)
+	p.LineTo(20, 17)
+	c.Path(&p, PathStyle{Stroke: color.Black, StrokeWidth: 2})
+
+	for _, test := range []struct {
+		x, y int
+		want color.Color
+	}{
+		{5, 5, red}, {1, 5, color.White}, {10, 5, color.White},
+		{2, 13, blue}, {10, 13, color.White},
+		{10, 16, color.Black}, {10, 17, color.Black}, {10, 15, color.White}, {10, 18, color.White},
+	} {
+		if got := img.At(test.x, test.y); !sameColor(got, test.want) {
+			t.Errorf("pixel (%d, %d) is %v, want %v", test.x, test.y, got, test.want)
+		}
+	}
+}
+
+func sameColor(a, b color.Color) bool {
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
+
+func TestWritePNG(t *testing.T) {
+	tab := new(table.Builder).
+		Add("x", []float64{1, 2, 3}).
+		Add("y", []float64{1, 4, 9}).
+		Done()
+	p := NewPlot(tab).Add(LayerLines{X: "x", Y: "y"})
+
+	var buf bytes.Buffer
+	if err := p.WritePNG(&buf, 200, 100); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b != image.Rect(0, 0, 200, 100) {
+		t.Fatalf("got bounds %v, want 200x100", b)
+	}
+	// The background is white and something is drawn on it.
+	if !sameColor(img.At(0, 0), color.White) {
+		t.Errorf("corner pixel is %v, want white", img.At(0, 0))
+	}
+	drawn := false
+	for y := 0; y < 100 && !drawn; y++ {
+		for x := 0; x < 200; x++ {
+			if !sameColor(img.At(x, y), color.White) {
+				drawn = true
+				break
+			}
+		}
+	}
+	if !drawn {
+		t.Errorf("image is blank")
+	}
+}
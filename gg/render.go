@@ -5,8 +5,8 @@
 package gg
 
 import (
-	"bytes"
-	"fmt"
+	"image"
+	"image/color"
 	"io"
 	"math"
 	"reflect"
@@ -14,43 +14,26 @@ import (
 
 	"github.com/aclements/go-gg/generic/slice"
 	"github.com/aclements/go-gg/table"
-	"github.com/ajstarks/svgo"
 )
 
-// fontSize is the font size in pixels.
-//
-// TODO: Theme.
+// fontSize is the default font size in pixels.
 const fontSize float64 = 14
 
-// facetLabelHeight is the height of facet labels, as a multiple of
-// the font height.
-//
-// TODO: Should this be a multiple of fontSize, em height, leading?
-// Currently it's leading.
-//
-// TODO: Theme.
-const facetLabelHeight = 1.3
-
-const xTickSep = 5 // TODO: Theme.
-
-const yTickSep = 5 // TODO: Theme.
-
-// plotMargins returns the top, right, bottom, and left margins for a
-// plot of the given width and height.
-//
-// By default, this adds a 5% margin based on the smaller of width and
-// height. This ensures that (with automatic scales), the extremes of
-// the data and its tick labels don't appear right at the edge of the
-// plot area.
-//
-// TODO: Theme.
-var plotMargins = func(w, h float64) (t, r, b, l float64) {
-	margin := 0.05 * math.Min(w, h)
-	return margin, margin, margin, margin
+// WriteSVG renders p to w as an SVG image of the given width and
+// height in pixels.
+func (p *Plot) WriteSVG(w io.Writer, width, height int) error {
+	c := newSVGCanvas(w, width, height, p.theme.FontFamily, p.theme.FontSize)
+	defer c.end()
+	p.Render(c, float64(width), float64(height))
+	return nil
 }
 
-func (p *Plot) WriteSVG(w io.Writer, width, height int) error {
-	// TODO: Legend, title.
+// Render lays out p in a region of the given width and height with
+// its top-left corner at the origin of canvas c, and draws it to c.
+// This can be used to render p to output formats not directly
+// supported by gg.
+func (p *Plot) Render(c Canvas, width, height float64) {
+	// TODO: Legend position and direction.
 
 	// TODO: Check if the same scaler is used for multiple
 	// aesthetics with conflicting rangers. Alternatively, if we
@@ -84,8 +67,6 @@ func (p *Plot) WriteSVG(w io.Writer, width, height int) error {
 
 	// TODO: Automatic aspect ratio by averaging slopes.
 
-	// TODO: Custom tick breaks.
-
 	// TODO: Make sure *all* Scalers have Rangers or the user will
 	// get confusing panics.
 
@@ -167,6 +148,15 @@ func (p *Plot) WriteSVG(w io.Writer, width, height int) error {
 	}
 	plotElts = addAxisLabels(plotElts, p.title, xlabel, ylabel)
 
+	// Add legend.
+	plotElts = addLegend(plotElts, p.legendGuides())
+
+	// Apply the theme.
+	theme := p.theme
+	for _, elt := range plotElts {
+		elt.setTheme(&theme)
+	}
+
 	// Compute plot element layout.
 	layout := layoutPlotElts(plotElts)
 
@@ -179,7 +169,7 @@ func (p *Plot) WriteSVG(w io.Writer, width, height int) error {
 	// compromise around the number of ticks.
 	//
 	// 1) Lay out the graphs without ticks.
-	layout.SetLayout(0, 0, float64(width), float64(height))
+	layout.SetLayout(0, 0, width, height)
 	// 2) Compute the number of ticks and tick labels for each
 	// tick element.
 	for _, elt := range plotElts {
@@ -188,24 +178,22 @@ func (p *Plot) WriteSVG(w io.Writer, width, height int) error {
 		}
 	}
 	// 3) Re-layout the plot and stick with the ticks we computed.
-	layout.SetLayout(0, 0, float64(width), float64(height))
+	layout.SetLayout(0, 0, width, height)
 
-	// Draw.
-	svg := svg.New(w)
-	svg.Start(width, height, fmt.Sprintf(`font-size="%.6gpx" font-family="Roboto,&quot;Helvetica Neue&quot;,Helvetica,Arial,sans-serif"`, fontSize))
-	defer svg.End()
+	// Draw the background.
+	if isPaint(theme.Background) {
+		c.Rect(0, 0, width, height, PathStyle{Fill: theme.Background})
+	}
 
 	// Render each plot element.
-	r := &eltRender{svg, 0}
+	r := &eltRender{c}
 	for _, elt := range plotElts {
 		elt.render(r)
 	}
-
-	return nil
 }
 
 func (e *eltSubplot) render(r *eltRender) {
-	svg := r.svg
+	c := r.canvas
 	x, y, w, h := e.Layout()
 	m := e.plotMargins
 
@@ -215,11 +203,7 @@ func (e *eltSubplot) render(r *eltRender) {
 	wi, hi := x2i-xi, y2i-yi
 
 	// Create clip region for plot area.
-	clipId, clipRef := r.genid("clip")
-	svg.ClipPath(`id="` + clipId + `"`)
-	svg.Rect(xi, yi, wi, hi)
-	svg.ClipEnd()
-	svg.Group(`clip-path="` + clipRef + `"`)
+	c.BeginGroup(&Rect{float64(xi), float64(yi), float64(wi), float64(hi)})
 
 	// Set scale ranges.
 	xRanger := NewFloatRanger(float64(xi)+m.l, float64(x2i)-m.r)
@@ -232,54 +216,63 @@ func (e *eltSubplot) render(r *eltRender) {
 	}
 
 	// Render grid.
-	renderBackground(svg, xi, yi, wi, hi)
+	t := e.theme
+	if isPaint(t.PanelBackground) {
+		c.Rect(float64(xi), float64(yi), float64(wi), float64(hi), PathStyle{Fill: t.PanelBackground})
+	}
 	for s := range e.scales["x"] {
-		renderGrid(svg, 'x', s, e.xTicks.ticks[s], yi, y2i)
+		renderGrid(c, t, 'x', s, e.xTicks.ticks[s], yi, y2i)
 	}
 	for s := range e.scales["y"] {
-		renderGrid(svg, 'y', s, e.yTicks.ticks[s], xi, x2i)
+		renderGrid(c, t, 'y', s, e.yTicks.ticks[s], xi, x2i)
 	}
 
 	// Create rendering environment.
 	env := &renderEnv{
 		cache: make(map[renderCacheKey]table.Slice),
 		area:  [4]float64{float64(xi), float64(yi), float64(wi), float64(hi)},
+		theme: t,
 	}
 
 	// Render marks.
 	for _, mark := range e.marks {
+		if gm, ok := mark.m.(groupMarker); ok {
+			gm.markGroups(env, mark.groups, c)
+			continue
+		}
 		for _, gid := range mark.groups {
 			env.gid = gid
-			mark.m.mark(env, svg)
+			mark.m.mark(env, c)
 		}
 	}
 
 	// End clip region.
-	svg.Gend()
+	c.EndGroup()
 
 	// Draw border and scale ticks.
-	//
-	// TODO: Theme.
 
 	// Render border.
-	svg.Path(fmt.Sprintf("M%d %dV%dH%d", xi, yi, y2i, x2i), "stroke:#888; fill:none; stroke-width:2") // TODO: Theme.
+	var border Path
+	border.MoveTo(float64(xi), float64(yi))
+	border.LineTo(float64(xi), float64(y2i))
+	border.LineTo(float64(x2i), float64(y2i))
+	c.Path(&border, t.AxisLine.style())
+	if isPaint(t.PanelBorder.Color) {
+		c.Rect(float64(xi), float64(yi), float64(wi), float64(hi), t.PanelBorder.style())
+	}
 
 	// Render scale ticks.
 	for s := range e.scales["x"] {
-		renderScale(svg, 'x', s, e.xTicks.ticks[s], y2i)
+		renderScale(c, t, 'x', s, e.xTicks.ticks[s], y2i)
 	}
 	for s := range e.scales["y"] {
-		renderScale(svg, 'y', s, e.yTicks.ticks[s], xi)
+		renderScale(c, t, 'y', s, e.yTicks.ticks[s], xi)
 	}
 }
 
 // TODO: Use shape-rendering: crispEdges?
 
-func renderBackground(svg *svg.SVG, x, y, w, h int) {
-	svg.Rect(x, y, w, h, "fill:#eee") // TODO: Theme.
-}
-
-func renderGrid(svg *svg.SVG, dir rune, scale Scaler, ticks plotEltTicks, start, end int) {
+func renderGrid(c Canvas, t *Theme, dir rune, scale Scaler, ticks plotEltTicks, start, end int) {
 	major := mapMany(scale, ticks.major).([]float64)
 
 	r := func(x float64) float64 {
@@ -287,22 +280,24 @@ func renderGrid(svg *svg.SVG, dir rune, scale Scaler, ticks plotEltTicks, start,
 		return math.Floor(x + 0.5)
 	}
 
-	var path []string
+	var path Path
 	for _, p := range major {
 		if dir == 'x' {
-			path = append(path, fmt.Sprintf("M%.6g %dv%d", r(p), start, end-start))
+			path.MoveTo(r(p), float64(start))
+			path.LineTo(r(p), float64(end))
 		} else {
-			path = append(path, fmt.Sprintf("M%d %.6gh%d", start, r(p), end-start))
+			path.MoveTo(float64(start), r(p))
+			path.LineTo(float64(end), r(p))
 		}
 	}
 
-	svg.Path(wrapPath(strings.Join(path, "")), "stroke: #fff; stroke-width:2") // TODO: Theme.
+	c.Path(&path, t.GridMajor.style())
 }
 
-func renderScale(svg *svg.SVG, dir rune, scale Scaler, ticks plotEltTicks, pos int) {
-	const length float64 = 4 // TODO: Theme
+func renderScale(c Canvas, t *Theme, dir rune, scale Scaler, ticks plotEltTicks, pos int) {
+	length := t.TickLength
 
-	var path bytes.Buffer
+	var path Path
 	have := map[float64]bool{}
 	for _, t := range []struct {
 		length float64
@@ -326,57 +321,194 @@ func renderScale(svg *svg.SVG, dir rune, scale Scaler, ticks plotEltTicks, pos i
 			}
 			have[p] = true
 			if dir == 'x' {
-				fmt.Fprintf(&path, "M%.6g %dv%.6g", p, pos, -t.length)
+				path.MoveTo(p, float64(pos))
+				path.LineTo(p, float64(pos)-t.length)
 			} else {
-				fmt.Fprintf(&path, "M%d %.6gh%.6g", pos, p, t.length)
+				path.MoveTo(float64(pos), p)
+				path.LineTo(float64(pos)+t.length, p)
 			}
 		}
 
 	}
-	svg.Path(wrapPath(path.String()), "stroke:#888; stroke-width:2") // TODO: Theme
+	c.Path(&path, t.AxisTicks.style())
 }
 
 func (e *eltTicks) render(r *eltRender) {
-	svg := r.svg
+	c := r.canvas
 	x, y, w, _ := e.Layout()
+	style := e.theme.textStyle(e.theme.TickLabel)
+	sep := e.theme.TickLabelSep
+	if e.axis == 'x' {
+		style.Anchor, style.Baseline = TextAnchorMiddle, TextBaselineTop
+	} else {
+		style.Anchor, style.Baseline = TextAnchorEnd, TextBaselineMiddle
+	}
 	for s := range e.scales() {
 		pos := e.mapTicks(s, e.ticks[s].major)
 		for i, label := range e.ticks[s].labels {
 			tick := pos[i]
 			if e.axis == 'x' {
-				svg.Text(int(tick), int(y+xTickSep), label, `text-anchor="middle" dy="1em" fill="#666"`) // TODO: Theme.
+				c.Text(float64(int(tick)), float64(int(y+sep)), label, style)
 			} else {
-				svg.Text(int(x+w-yTickSep), int(tick), label, `text-anchor="end" dy=".3em" fill="#666"`)
+				c.Text(float64(int(x+w-sep)), float64(int(tick)), label, style)
 			}
 		}
 	}
 }
 
 func (e *eltLabel) render(r *eltRender) {
-	svg := r.svg
+	c := r.canvas
 	x, y, w, h := e.Layout()
 
 	// Clip to label region.
-	clipId, clipRef := r.genid("clip")
-	svg.ClipPath(`id="` + clipId + `"`)
-	svg.Rect(int(x), int(y), int(w), int(h))
-	svg.ClipEnd()
-	svg.Group(`clip-path="` + clipRef + `"`)
-	defer svg.Gend()
-
-	if e.fill != "none" {
-		svg.Rect(int(x), int(y), int(w), int(h), "fill: "+e.fill)
-	}
-	// Vertical centering is very poorly
-	// supported. dy is the best chance.
-	style := `text-anchor="middle" dy=".3em"`
+	c.BeginGroup(&Rect{x, y, w, h})
+	defer c.EndGroup()
+
+	bg, text := e.style()
+	if isPaint(bg) {
+		c.Rect(x, y, w, h, PathStyle{Fill: bg})
+	}
+	style := e.theme.textStyle(text)
+	style.Anchor, style.Baseline = TextAnchorMiddle, TextBaselineMiddle
 	switch e.side {
 	case 'l':
-		style += fmt.Sprintf(` transform="rotate(-90 %d %d)"`, int(x+w/2), int(y+h/2))
+		style.Rotate = -90
 	case 'r':
-		style += fmt.Sprintf(` transform="rotate(90 %d %d)"`, int(x+w/2), int(y+h/2))
+		style.Rotate = 90
+	}
+	c.Text(float64(int(x+w/2)), float64(int(y+h/2)), e.label, style)
+}
+
+func (e *eltLegend) render(r *eltRender) {
+	x, y, _, h := e.Layout()
+	_, totalh, _, _ := e.SizeHint()
+
+	// Center the guides vertically.
+	x += e.theme.LegendPadding
+	if totalh < h {
+		y += (h - totalh) / 2
+	}
+	for _, g := range e.guides {
+		_, gh := e.guideSize(g)
+		e.renderGuide(r, g, x, y)
+		y += gh + e.theme.LegendSpacing
+	}
+}
+
+func (e *eltLegend) renderGuide(r *eltRender, g *legendGuide, x, y float64) {
+	c, t := r.canvas, e.theme
+	titleStyle, labelStyle := t.textStyle(t.LegendTitle), t.textStyle(t.LegendText)
+	titleStyle.Baseline, labelStyle.Baseline = TextBaselineMiddle, TextBaselineMiddle
+
+	title := t.measure(t.LegendTitle, g.title)
+	c.Text(float64(int(x)), float64(int(y+title.leading/2)), g.title, titleStyle)
+	y += title.leading
+
+	if g.bar != nil {
+		renderLegendBar(c, t, g.bar, x, y, labelStyle)
+		return
+	}
+
+	keyw, keyh := e.keySize(g)
+	var rowh float64 = keyh
+	for _, key := range g.keys {
+		rowh = math.Max(rowh, t.measure(t.LegendText, key.label).leading)
+	}
+	scale := e.pointScale()
+	for _, key := range g.keys {
+		kx, ky := x, y+(rowh-keyh)/2
+		if isPaint(t.LegendKeyBackground) {
+			c.Rect(kx, ky, keyw, keyh, PathStyle{Fill: t.LegendKeyBackground})
+		}
+
+		opacity := 1.0
+		if !math.IsNaN(key.opacity) {
+			opacity = key.opacity
+		}
+		if g.glyph&legendGlyphRect != 0 {
+			var fill color.Color = t.DataColor
+			if key.fill != nil {
+				fill = key.fill
+			} else if key.stroke != nil && g.glyph&legendGlyphLine == 0 {
+				fill = key.stroke
+			}
+			c.Rect(kx+1, ky+1, keyw-2, keyh-2, PathStyle{Fill: withOpacity(fill, opacity)})
+		}
+		if g.glyph&legendGlyphLine != 0 {
+			var stroke color.Color = t.DataColor
+			if key.stroke != nil {
+				stroke = key.stroke
+			}
+			width := float64(defaultLineWidth)
+			if !math.IsNaN(key.linewidth) {
+				width = key.linewidth
+			}
+			var line Path
+			line.MoveTo(kx, ky+keyh/2)
+			line.LineTo(kx+keyw, ky+keyh/2)
+			c.Path(&line, PathStyle{Stroke: withOpacity(stroke, opacity), StrokeWidth: width, Dash: key.linetype.dash(width)})
+		}
+		if g.glyph&legendGlyphPoint != 0 {
+			var stroke color.Color = t.DataColor
+			if key.stroke != nil {
+				stroke = key.stroke
+			}
+			var fill color.Color
+			if key.fill != nil {
+				fill = withOpacity(key.fill, opacity)
+			}
+			size := 0.01
+			if !math.IsNaN(key.size) {
+				size = key.size
+			}
+			drawShape(c, key.shape, kx+keyw/2, ky+keyh/2, size*scale, withOpacity(stroke, opacity), fill)
+		}
+		if g.glyph&legendGlyphText != 0 {
+			style := t.textStyle(t.LegendText)
+			style.Fill = t.DataColor
+			if key.stroke != nil {
+				style.Fill = key.stroke
+			}
+			style.Fill = withOpacity(style.Fill, opacity)
+			if !math.IsNaN(key.size) {
+				// See markText.
+				style.Size = 2 * key.size * scale
+			}
+			style.Anchor, style.Baseline = TextAnchorMiddle, TextBaselineMiddle
+			c.Text(kx+keyw/2, ky+keyh/2, "a", style)
+		}
+
+		c.Text(float64(int(kx+keyw+t.LegendKeySep)), float64(int(y+rowh/2)), key.label, labelStyle)
+		y += rowh
 	}
-	svg.Text(int(x+w/2), int(y+h/2), e.label, style)
+}
+
+func renderLegendBar(c Canvas, t *Theme, bar *legendBar, x, y float64, labelStyle TextStyle) {
+	w, h := t.LegendKeySize, t.LegendKeySize*t.LegendBarLength
+
+	// Draw the gradient as an image with the maximum value at
+	// the top.
+	rows := int(h)
+	img := image.NewRGBA(image.Rect(0, 0, 1, rows))
+	for i := 0; i < rows; i++ {
+		img.Set(0, i, bar.ranger.Map(1-(float64(i)+0.5)/float64(rows)).(color.Color))
+	}
+	c.Image(x, y, w, h, img)
+
+	// Draw ticks and labels.
+	var path Path
+	for i, tick := range bar.ticks {
+		if tick < 0 || tick > 1 {
+			continue
+		}
+		ty := y + h*(1-tick)
+		path.MoveTo(x, ty)
+		path.LineTo(x+w/4, ty)
+		path.MoveTo(x+w, ty)
+		path.LineTo(x+w-w/4, ty)
+		c.Text(float64(int(x+w+t.LegendKeySep)), float64(int(ty)), bar.labels[i], labelStyle)
+	}
+	c.Path(&path, t.LegendBarTicks.style())
 }
 
 func (e *eltPadding) render(r *eltRender) {
@@ -386,6 +518,11 @@ type renderEnv struct {
 	gid   table.GroupID
 	cache map[renderCacheKey]table.Slice
 	area  [4]float64
+	theme *Theme
+
+	// adjusted overrides the mapped data for marks whose
+	// positions have been adjusted. See renderEnv.place.
+	adjusted map[renderCacheKey]table.Slice
 }
 
 type renderCacheKey struct {
@@ -398,6 +535,10 @@ type renderCacheKey struct {
 // representation of the visually-mapped data that becomes available
 // once all of the scales have been trained.
 type scaledData struct {
+	// aes and col are the aesthetic and the data column this
+	// scaledData was bound from.
+	aes, col string
+
 	seqs map[table.GroupID]scaledSeq
 }
 
@@ -408,6 +549,9 @@ type scaledSeq struct {
 
 func (env *renderEnv) get(sd *scaledData) table.Slice {
 	cacheKey := renderCacheKey{sd, env.gid}
+	if adjusted := env.adjusted[cacheKey]; adjusted != nil {
+		return adjusted
+	}
 	if mapped := env.cache[cacheKey]; mapped != nil {
 		return mapped
 	}
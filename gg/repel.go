@@ -0,0 +1,77 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import "math"
+
+// repelBoxes moves boxes so they don't overlap each other or cover
+// any of points, while keeping them within bounds and close to where
+// they started. Boxes are kept at least pad pixels away from each
+// other and from points. It returns the moved boxes.
+//
+// Boxes are placed greedily in order. Each box is tried at positions
+// on successively larger rings around where it started, and is put at
+// the closest position that doesn't overlap an already placed box and
+// covers the fewest points. If every position overlaps a placed box,
+// the box stays where it started.
+func repelBoxes(boxes []Rect, points []Point, bounds Rect, pad float64) []Rect {
+	const (
+		step   = 4  // Distance between rings in pixels
+		angles = 16 // Positions per ring
+	)
+	maxRings := int(math.Max(bounds.W, bounds.H)/step) + 1
+
+	clamp := func(b Rect) Rect {
+		b.X = math.Max(bounds.X, math.Min(b.X, bounds.X+bounds.W-b.W))
+		b.Y = math.Max(bounds.Y, math.Min(b.Y, bounds.Y+bounds.H-b.H))
+		return b
+	}
+	grow := func(b Rect) Rect {
+		return Rect{b.X - pad, b.Y - pad, b.W + 2*pad, b.H + 2*pad}
+	}
+	covered := func(b Rect) int {
+		b = grow(b)
+		n := 0
+		for _, p := range points {
+			if b.X <= p.X && p.X <= b.X+b.W && b.Y <= p.Y && p.Y <= b.Y+b.H {
+				n++
+			}
+		}
+		return n
+	}
+
+	var placed []Rect
+	out := make([]Rect, len(boxes))
+	for i, orig := range boxes {
+		best, bestCover, bestDist := clamp(orig), -1, 0.0
+	search:
+		for ring := 0; ring < maxRings; ring++ {
+			n := angles
+			if ring == 0 {
+				n = 1
+			}
+			for k := 0; k < n; k++ {
+				sin, cos := math.Sincos(2 * math.Pi * float64(k) / float64(n))
+				r := float64(ring * step)
+				b := clamp(Rect{orig.X + r*cos, orig.Y + r*sin, orig.W, orig.H})
+				if overlapsAny(grow(b), placed) {
+					continue
+				}
+				cover := covered(b)
+				dist := math.Hypot(b.X-orig.X, b.Y-orig.Y)
+				if bestCover < 0 || cover < bestCover || (cover == bestCover && dist < bestDist) {
+					best, bestCover, bestDist = b, cover, dist
+				}
+			}
+			if bestCover == 0 {
+				// Nothing on later rings can be better.
+				break search
+			}
+		}
+		placed = append(placed, best)
+		out[i] = best
+	}
+	return out
+}
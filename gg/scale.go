@@ -36,11 +36,6 @@ import (
 //
 // It's not really "continuous", it's more specifically cardinal.
 
-// TODO: time.Time and time.Duration scalers. For time.Duration,
-// handle sub-second as (10 seconds)^n (for n <= 0), handle seconds to
-// minutes at multiples of 10 seconds, and likewise minutes to hours
-// as multiples of 10 minutes, and handle hours as (10 hours)^n.
-
 // XXX
 //
 // A Scaler can be cardinal, discrete, or identity.
@@ -158,17 +153,86 @@ type Scaler interface {
 	// even for more specific input types).
 	SetFormatter(f interface{})
 
+	// SetTicks customizes the tick marks returned by Ticks, which
+	// are used for both axes and legends.
+	SetTicks(t TickSpec)
+
 	CloneScaler() Scaler
 }
 
+// TickSpec customizes the tick marks of a Scaler.
+//
+// The zero TickSpec uses the Scaler's default ticks.
+type TickSpec struct {
+	// Major and Minor, if Major is non-nil, are the locations of
+	// the major and minor ticks. They must be slices of values
+	// convertible to the Scaler's domain type. If Minor is nil,
+	// there are no minor ticks between the major ticks.
+	//
+	// Ticks outside of the Scaler's domain are not shown on axes.
+	// Use ContinuousScaler.Include to expand the domain if
+	// necessary.
+	Major, Minor table.Slice
+
+	// Labels, if non-nil, gives the label of each tick in Major.
+	// Otherwise, ticks are labeled using the Scaler's formatter.
+	Labels []string
+
+	// Func, if non-nil and Major is nil, computes the ticks of
+	// the Scaler.
+	Func TickFunc
+
+	// Count, if non-zero, is the maximum number of major ticks.
+	// Otherwise, the number of ticks depends on the space
+	// available for their labels.
+	Count int
+}
+
+// A TickFunc computes the ticks of a Scaler. domain is a slice of the
+// Scaler's domain type giving the extent of its input domain: the
+// minimum and maximum values for a continuous scale, or each value in
+// order for an ordinal scale. max is the maximum number of major
+// ticks that can be shown.
+//
+// A TickFunc returns tick marks like Scaler.Ticks, except that minor
+// or labels may be nil, as for TickSpec.
+type TickFunc func(domain table.Slice, max int) (major, minor table.Slice, labels []string)
+
+// apply computes ticks according to t. domain returns the domain to
+// pass to t.Func, auto computes the Scaler's default ticks, and
+// format labels ticks using the Scaler's formatter.
+func (t *TickSpec) apply(max int, pred func(major, minor table.Slice, labels []string) bool, domain func() table.Slice, auto func(int, func(major, minor table.Slice, labels []string) bool) (table.Slice, table.Slice, []string), format func(table.Slice) []string) (major, minor table.Slice, labels []string) {
+	if t.Count > 0 {
+		max = t.Count
+	}
+	switch {
+	case t.Major != nil:
+		major, minor, labels = t.Major, t.Minor, t.Labels
+	case t.Func != nil:
+		major, minor, labels = t.Func(domain(), max)
+		if major == nil {
+			return nil, nil, nil
+		}
+	default:
+		return auto(max, pred)
+	}
+
+	n := reflect.ValueOf(major).Len()
+	if labels == nil {
+		labels = format(major)
+	} else if len(labels) != n {
+		panic(fmt.Sprintf("got %d labels for %d major ticks", len(labels), n))
+	}
+	if minor == nil {
+		// Minor ticks must be a superset of major ticks.
+		minor = major
+	}
+	return major, minor, labels
+}
+
 type ContinuousScaler interface {
 	Scaler
 
-	// TODO: There are two variations on min/max. 1) We can force
-	// the min/max, even if there's data beyond it. 2) We can say
-	// cap the scale to some min/max, but a smaller range is okay.
-	// Currently we can't express 2.
-
 	// SetMin and SetMax set the minimum and maximum values of
 	// this Scalar's domain and return the Scalar. If v is nil, it
 	// unsets the bound.
@@ -180,6 +244,32 @@ type ContinuousScaler interface {
 	SetMin(v interface{}) ContinuousScaler
 	SetMax(v interface{}) ContinuousScaler
 
+	// SetSoftMin and SetSoftMax cap the minimum and maximum
+	// values of this Scaler's domain and return the Scaler. Unlike
+	// SetMin and SetMax, the domain may be smaller than these
+	// bounds if the data doesn't extend to them. If v is nil, it
+	// unsets the bound. SetMin and SetMax take precedence over
+	// these.
+	//
+	// v must be convertible to the Scaler's domain type.
+	SetSoftMin(v interface{}) ContinuousScaler
+	SetSoftMax(v interface{}) ContinuousScaler
+
+	// SetOOB sets how this Scaler maps values outside of its
+	// domain and returns the Scaler. The default is OOBKeep,
+	// except for log scales, where it is OOBSquish.
+	//
+	// Values that have no position on a scale, such as 0 on a
+	// log scale, are beyond the nearest bound of the domain.
+	// OOBKeep maps them to NaN.
+	SetOOB(oob OOB) ContinuousScaler
+
+	// SetReverse sets whether this Scaler maps larger values to
+	// lower positions in its range and returns the Scaler. For
+	// example, reversing the X scale flips the X axis to run
+	// right to left.
+	SetReverse(reverse bool) ContinuousScaler
+
 	// TODO: Should Include work on any Scaler?
 
 	// Include requires that v be included in this Scaler's
@@ -192,6 +282,70 @@ type ContinuousScaler interface {
 	Include(v interface{}) ContinuousScaler
 }
 
+// OOB is a policy for mapping values outside of the domain of a
+// ContinuousScaler, such as data beyond the bounds set by SetMax.
+//
+// These policies apply to Scalers with continuous Rangers. Scalers
+// with discrete Rangers always map out-of-bounds values to the
+// nearest level.
+type OOB int
+
+const (
+	// OOBKeep maps out-of-bounds values like any other value, so
+	// they fall outside of the output range. Marks outside of a
+	// plot's panel are clipped when rendered.
+	OOBKeep OOB = iota
+
+	// OOBCensor maps out-of-bounds values to NaN. Marks omit NaN
+	// positions, sizes, and opacities, and the default color
+	// Ranger maps NaN to gray.
+	OOBCensor
+
+	// OOBSquish maps out-of-bounds values to the nearest bound of
+	// the domain.
+	OOBSquish
+)
+
+// apply applies policy o to x, a value that has been mapped from a
+// Scaler's domain to the unit interval.
+func (o OOB) apply(x float64) float64 {
+	// Allow for rounding error at the bounds.
+	const eps = 1e-9
+	switch o {
+	case OOBCensor:
+		if x < -eps || x > 1+eps {
+			return math.NaN()
+		}
+	case OOBSquish:
+		return math.Max(0, math.Min(1, x))
+	}
+	return x
+}
+
+// mapUnit maps x, a value that has been mapped from a continuous
+// Scaler's domain to the unit interval, through Ranger r, applying
+// policy oob.
+func mapUnit(r Ranger, x float64, oob OOB) interface{} {
+	switch r := r.(type) {
+	case ContinuousRanger:
+		return r.Map(oob.apply(x))
+
+	case DiscreteRanger:
+		_, levels := r.Levels()
+		// Bin the scaled value into 'levels' bins.
+		level := int(x * float64(levels))
+		if level < 0 || math.IsNaN(x) {
+			level = 0
+		} else if level >= levels {
+			level = levels - 1
+		}
+		return r.MapLevel(level, levels)
+
+	default:
+		panic("Ranger must be a ContinuousRanger or DiscreteRanger")
+	}
+}
+
 // Unscaled represents a value that should not be scaled, but instead
 // mapped directly to the output range. For continuous scales, this
 // should be a value between 0 and 1. For discrete scales, this should
@@ -238,6 +392,7 @@ type defaultScale struct {
 	// Pre-instantiation state.
 	r         Ranger
 	formatter interface{}
+	ticks     *TickSpec
 }
 
 func (s *defaultScale) String() string {
@@ -275,6 +430,10 @@ func (s *defaultScale) instantiate() {
 		s.scale.SetFormatter(s.formatter)
 		s.formatter = nil
 	}
+	if s.ticks != nil {
+		s.scale.SetTicks(*s.ticks)
+		s.ticks = nil
+	}
 }
 
 func (s *defaultScale) Ranger(r Ranger) Ranger {
@@ -312,11 +471,19 @@ func (s *defaultScale) SetFormatter(f interface{}) {
 	s.scale.SetFormatter(f)
 }
 
+func (s *defaultScale) SetTicks(t TickSpec) {
+	if s.scale == nil {
+		s.ticks = &t
+		return
+	}
+	s.scale.SetTicks(t)
+}
+
 func (s *defaultScale) CloneScaler() Scaler {
 	if s.scale == nil {
-		return &defaultScale{nil, s.r, s.formatter}
+		return &defaultScale{nil, s.r, s.formatter, s.ticks}
 	}
-	return &defaultScale{s.scale.CloneScaler(), nil, s.formatter}
+	return &defaultScale{s.scale.CloneScaler(), nil, s.formatter, s.ticks}
 }
 
 func DefaultScale(seq table.Slice) (Scaler, error) {
@@ -330,6 +497,13 @@ func DefaultScale(seq table.Slice) (Scaler, error) {
 
 	case []time.Time:
 		return NewTimeScaler(), nil
+
+	case []time.Duration:
+		return NewDurationScaler(), nil
+
+	case []Shape, []LineType, []LineWidth:
+		// Like colors, these are already visual values.
+		return NewIdentityScale(), nil
 	}
 
 	rt := reflect.TypeOf(seq).Elem()
@@ -376,6 +550,24 @@ func defaultRanger(aes string) Ranger {
 		// Default to ranging between 1% and 10% of the
 		// minimum plot dimension.
 		return NewFloatRanger(0.01, 0.1)
+
+	case "shape":
+		return NewShapeRanger(defaultShapes)
+
+	case "linewidth":
+		// Default to ranging between 1 and 6 pixels.
+		return NewFloatRanger(1, 6)
+
+	case "linetype":
+		return NewLineTypeRanger([]LineType{
+			LineSolid, LineDashed, LineDotted,
+			LineDotDash, LineLongDash, LineTwoDash,
+		})
+
+	case "angle", "hjust", "vjust", "nudgex", "nudgey":
+		// These default to identity scales, which don't need
+		// Rangers.
+		return nil
 	}
 
 	panic(fmt.Sprintf("unknown aesthetic %q", aes))
@@ -410,6 +602,8 @@ func (s *identityScale) Ticks(max int, pred func(major, minor table.Slice, label
 
 func (s *identityScale) SetFormatter(f interface{}) {}
 
+func (s *identityScale) SetTicks(t TickSpec) {}
+
 func (s *identityScale) CloneScaler() Scaler {
 	s2 := *s
 	return &s2
@@ -426,36 +620,65 @@ func NewLinearScaler() ContinuousScaler {
 	return &moremathScale{
 		min:     math.NaN(),
 		max:     math.NaN(),
+		softMin: math.NaN(),
+		softMax: math.NaN(),
 		dataMin: math.NaN(),
 		dataMax: math.NaN(),
 	}
 }
 
+// NewLogScaler returns a continuous logarithmic scale in the given
+// base. Unlike other continuous scales, it squishes values outside
+// its domain to the nearest bound by default. See SetOOB.
 func NewLogScaler(base int) ContinuousScaler {
 	return &moremathScale{
 		min:     math.NaN(),
 		max:     math.NaN(),
+		softMin: math.NaN(),
+		softMax: math.NaN(),
 		base:    base,
+		oob:     OOBSquish,
 		dataMin: math.NaN(),
 		dataMax: math.NaN(),
 	}
 }
 
 type moremathScale struct {
-	r Ranger
-	f interface{}
+	r     Ranger
+	f     interface{}
+	ticks TickSpec
 
 	domainType       reflect.Type
 	base             int
+	trans            scaleTransform
+	reverse          bool
+	oob              OOB
 	min, max         float64
+	softMin, softMax float64
 	dataMin, dataMax float64
 }
 
 func (s *moremathScale) String() string {
+	rev := ""
+	if s.reverse {
+		rev = "reverse "
+	}
 	if s.base > 0 {
-		return fmt.Sprintf("log [%d,%g,%g] => %s", s.base, s.min, s.max, s.r)
+		return fmt.Sprintf("%slog [%d,%g,%g] => %s", rev, s.base, s.min, s.max, s.r)
+	}
+	name := "linear"
+	if s.trans != nil {
+		name = s.trans.String()
 	}
-	return fmt.Sprintf("linear [%g,%g] => %s", s.min, s.max, s.r)
+	return fmt.Sprintf("%s%s [%g,%g] => %s", rev, name, s.min, s.max, s.r)
+}
+
+// inDomain returns whether v is a value this scale can map.
+func (s *moremathScale) inDomain(v float64) bool {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return false
+	}
+	return s.trans == nil || isFinite(s.trans.transform(v))
 }
 
 func (s *moremathScale) ExpandDomain(vs table.Slice) {
@@ -467,7 +690,7 @@ func (s *moremathScale) ExpandDomain(vs table.Slice) {
 	slice.Convert(&data, vs)
 	min, max := s.dataMin, s.dataMax
 	for _, v := range data {
-		if math.IsNaN(v) || math.IsInf(v, 0) {
+		if !s.inDomain(v) {
 			continue
 		}
 		if v < min || math.IsNaN(min) {
@@ -500,14 +723,48 @@ func (s *moremathScale) SetMax(v interface{}) ContinuousScaler {
 	return s
 }
 
+func (s *moremathScale) SetSoftMin(v interface{}) ContinuousScaler {
+	if v == nil {
+		s.softMin = math.NaN()
+		return s
+	}
+	s.softMin = reflect.ValueOf(v).Convert(float64Type).Float()
+	return s
+}
+
+func (s *moremathScale) SetSoftMax(v interface{}) ContinuousScaler {
+	if v == nil {
+		s.softMax = math.NaN()
+		return s
+	}
+	s.softMax = reflect.ValueOf(v).Convert(float64Type).Float()
+	return s
+}
+
+func (s *moremathScale) SetOOB(oob OOB) ContinuousScaler {
+	s.oob = oob
+	return s
+}
+
+func (s *moremathScale) SetReverse(reverse bool) ContinuousScaler {
+	s.reverse = reverse
+	return s
+}
+
 func (s *moremathScale) Include(v interface{}) ContinuousScaler {
 	if v == nil {
 		return s
 	}
 	vfloat := reflect.ValueOf(v).Convert(float64Type).Float()
-	if math.IsNaN(vfloat) || math.IsInf(vfloat, 0) {
+	if !isFinite(vfloat) {
 		return s
 	}
+	if !s.inDomain(vfloat) {
+		if s.trans == nil {
+			return s
+		}
+		vfloat = s.trans.clampBound(vfloat)
+	}
 	if math.IsNaN(s.dataMin) {
 		s.dataMin, s.dataMax = vfloat, vfloat
 	} else {
@@ -520,31 +777,87 @@ func (s *moremathScale) Include(v interface{}) ContinuousScaler {
 type tickMapper interface {
 	scale.Ticker
 	Map(float64) float64
+	Unmap(float64) float64
 }
 
 func (s *moremathScale) get() tickMapper {
-	min, max := s.min, s.max
+	m := s.getForward()
+	if s.reverse {
+		return reverseMapper{m}
+	}
+	return m
+}
+
+// bounds returns the bounds of s's domain.
+func (s *moremathScale) bounds() (min, max float64) {
+	min, max = s.min, s.max
 	if min > max {
 		min, max = max, min
 	}
 	if math.IsNaN(min) {
 		min = s.dataMin
+		if min < s.softMin {
+			min = s.softMin
+		}
 	}
 	if math.IsNaN(max) {
 		max = s.dataMax
+		if max > s.softMax {
+			max = s.softMax
+		}
 	}
 	if math.IsNaN(min) {
 		// Only possible if both dataMin and dataMax are NaN.
 		min, max = -1, 1
 	}
+	if min > max {
+		// The data is entirely beyond one of the soft
+		// bounds, so collapse the domain to that bound.
+		if max == s.softMax {
+			min = max
+		} else {
+			max = min
+		}
+	}
+	if s.trans != nil {
+		// Replace bounds the transform can't map, such as 0
+		// on a logit scale, but keep the data in the domain.
+		if !isFinite(s.trans.transform(min)) {
+			min = s.trans.clampBound(min)
+			if s.dataMin < min {
+				min = s.dataMin
+			}
+		}
+		if !isFinite(s.trans.transform(max)) {
+			max = s.trans.clampBound(max)
+			if s.dataMax > max {
+				max = s.dataMax
+			}
+		}
+	}
+	return min, max
+}
+
+func (s *moremathScale) getForward() tickMapper {
+	min, max := s.bounds()
 	if s.base > 0 {
+		if min == max {
+			min, max = min/float64(s.base), max*float64(s.base)
+		}
 		ls, err := scale.NewLog(min, max, s.base)
 		if err != nil {
 			panic(err)
 		}
-		ls.SetClamp(true)
 		return &ls
 	}
+	if s.trans != nil {
+		return newTransformMapper(s.trans, min, max)
+	}
+	if min == max {
+		// Center a single value in the range. Otherwise it
+		// would map to NaN and have no ticks.
+		min, max = min-0.5, max+0.5
+	}
 	return &scale.Linear{
 		Min: min, Max: max,
 	}
@@ -563,42 +876,56 @@ func (s *moremathScale) RangeType() reflect.Type {
 }
 
 func (s *moremathScale) Map(x interface{}) interface{} {
-	ls := s.get()
-	var scaled float64
+	var v float64
 	switch x := x.(type) {
 	case float64:
-		scaled = ls.Map(x)
+		v = x
 	case Unscaled:
-		scaled = float64(x)
+		return mapUnit(s.r, float64(x), OOBKeep)
 	default:
-		v := reflect.ValueOf(x).Convert(float64Type).Float()
-		scaled = ls.Map(v)
-	}
-
-	switch r := s.r.(type) {
-	case ContinuousRanger:
-		return r.Map(scaled)
-
-	case DiscreteRanger:
-		_, levels := r.Levels()
-		// Bin the scaled value into 'levels' bins.
-		level := int(scaled * float64(levels))
-		if level < 0 {
-			level = 0
-		} else if level >= levels {
-			level = levels - 1
+		v = reflect.ValueOf(x).Convert(float64Type).Float()
+	}
+	scaled := s.get().Map(v)
+	if isFinite(v) && !isFinite(scaled) {
+		// v has no position on this scale, such as 0 on a
+		// log scale, so it's beyond the nearest bound.
+		if s.oob == OOBKeep {
+			scaled = math.NaN()
+		} else {
+			scaled = math.Inf(1)
+			if min, _ := s.bounds(); v < min {
+				scaled = math.Inf(-1)
+			}
+			if s.reverse {
+				scaled = -scaled
+			}
 		}
-		return r.MapLevel(level, levels)
-
-	default:
-		panic("Ranger must be a ContinuousRanger or DiscreteRanger")
 	}
+	return mapUnit(s.r, scaled, s.oob)
 }
 
 func (s *moremathScale) Ticks(max int, pred func(major, minor table.Slice, labels []string) bool) (major, minor table.Slice, labels []string) {
-	type Stringer interface {
-		String() string
+	return s.ticks.apply(max, pred, s.domain, s.autoTicks, func(major table.Slice) []string {
+		var xs []float64
+		slice.Convert(&xs, major)
+		return s.formatTicks(xs)
+	})
+}
+
+// domain returns the bounds of s's domain as a slice of the domain
+// type.
+func (s *moremathScale) domain() table.Slice {
+	min, max := s.bounds()
+	dt := s.domainType
+	if dt == nil {
+		dt = float64Type
 	}
+	v := reflect.New(reflect.SliceOf(dt))
+	slice.Convert(v.Interface(), []float64{min, max})
+	return v.Elem().Interface()
+}
+
+func (s *moremathScale) autoTicks(max int, pred func(major, minor table.Slice, labels []string) bool) (major, minor table.Slice, labels []string) {
 	if s.domainType == nil {
 		// There are no values and no domain type, so we can't
 		// compute ticks or return slices of the domain type.
@@ -626,52 +953,11 @@ func (s *moremathScale) Ticks(max int, pred func(major, minor table.Slice, label
 		return nil, nil, nil
 	}
 
-	mkLabels := func(major []float64) []string {
-		// Compute labels.
-		labels = make([]string, len(major))
-		if s.f != nil {
-			// Use custom formatter.
-			if f, ok := s.f.(func(float64) string); ok {
-				// Fast path.
-				for i, x := range major {
-					labels[i] = f(x)
-				}
-				return labels
-			}
-			// TODO: Type check for better error.
-			fv := reflect.ValueOf(s.f)
-			at := fv.Type().In(0)
-			var avs [1]reflect.Value
-			for i, x := range major {
-				avs[0] = reflect.ValueOf(x).Convert(at)
-				rvs := fv.Call(avs[:])
-				labels[i] = rvs[0].Interface().(string)
-			}
-			return labels
-		}
-		if s.domainType != nil {
-			z := reflect.Zero(s.domainType).Interface()
-			if _, ok := z.(Stringer); ok {
-				// Convert the ticks back into the domain type
-				// and use its String method.
-				for i, x := range major {
-					v := reflect.ValueOf(x).Convert(s.domainType)
-					labels[i] = v.Interface().(Stringer).String()
-				}
-				return labels
-			}
-		}
-		// Otherwise, just format them as floats.
-		for i, x := range major {
-			labels[i] = fmt.Sprintf("%.6g", x)
-		}
-		return labels
-	}
 	// Adjust level to satisfy pred.
 	for ; level <= o.MaxLevel; level++ {
 		majorx := ls.TicksAtLevel(level)
 		minorx := ls.TicksAtLevel(level - 1)
-		labels := mkLabels(majorx.([]float64))
+		labels := s.formatTicks(majorx.([]float64))
 
 		// Convert to domain type.
 		majorv := reflect.New(reflect.SliceOf(s.domainType))
@@ -688,10 +974,65 @@ func (s *moremathScale) Ticks(max int, pred func(major, minor table.Slice, label
 	return nil, nil, nil
 }
 
+// formatTicks returns the labels of the ticks in major.
+func (s *moremathScale) formatTicks(major []float64) []string {
+	type Stringer interface {
+		String() string
+	}
+	labels := make([]string, len(major))
+	if s.f != nil {
+		// Use custom formatter.
+		if f, ok := s.f.(func(float64) string); ok {
+			// Fast path.
+			for i, x := range major {
+				labels[i] = f(x)
+			}
+			return labels
+		}
+		// TODO: Type check for better error.
+		fv := reflect.ValueOf(s.f)
+		at := fv.Type().In(0)
+		var avs [1]reflect.Value
+		for i, x := range major {
+			avs[0] = reflect.ValueOf(x).Convert(at)
+			rvs := fv.Call(avs[:])
+			labels[i] = rvs[0].Interface().(string)
+		}
+		return labels
+	}
+	if s.domainType == durationType {
+		for i, x := range major {
+			labels[i] = formatDuration(time.Duration(x))
+		}
+		return labels
+	}
+	if s.domainType != nil {
+		z := reflect.Zero(s.domainType).Interface()
+		if _, ok := z.(Stringer); ok {
+			// Convert the ticks back into the domain type
+			// and use its String method.
+			for i, x := range major {
+				v := reflect.ValueOf(x).Convert(s.domainType)
+				labels[i] = v.Interface().(Stringer).String()
+			}
+			return labels
+		}
+	}
+	// Otherwise, just format them as floats.
+	for i, x := range major {
+		labels[i] = fmt.Sprintf("%.6g", x)
+	}
+	return labels
+}
+
 func (s *moremathScale) SetFormatter(f interface{}) {
 	s.f = f
 }
 
+func (s *moremathScale) SetTicks(t TickSpec) {
+	s.ticks = t
+}
+
 func (s *moremathScale) CloneScaler() Scaler {
 	s2 := *s
 	return &s2
@@ -706,7 +1047,11 @@ func NewTimeScaler() *timeScale {
 type timeScale struct {
 	r                Ranger
 	f                func(time.Time) string
+	ticks            TickSpec
+	oob              OOB
+	reverse          bool
 	min, max         time.Time
+	softMin, softMax time.Time
 	dataMin, dataMax time.Time
 }
 
@@ -739,6 +1084,34 @@ func (s *timeScale) SetMax(v interface{}) ContinuousScaler {
 	return s
 }
 
+func (s *timeScale) SetSoftMin(v interface{}) ContinuousScaler {
+	if v == nil {
+		s.softMin = time.Time{}
+		return s
+	}
+	s.softMin = v.(time.Time)
+	return s
+}
+
+func (s *timeScale) SetSoftMax(v interface{}) ContinuousScaler {
+	if v == nil {
+		s.softMax = time.Time{}
+		return s
+	}
+	s.softMax = v.(time.Time)
+	return s
+}
+
+func (s *timeScale) SetOOB(oob OOB) ContinuousScaler {
+	s.oob = oob
+	return s
+}
+
+func (s *timeScale) SetReverse(reverse bool) ContinuousScaler {
+	s.reverse = reverse
+	return s
+}
+
 func (s *timeScale) Include(v interface{}) ContinuousScaler {
 	tv := v.(time.Time)
 	if s.dataMin.IsZero() {
@@ -770,10 +1143,25 @@ func (s *timeScale) getMinMax() (time.Time, time.Time) {
 	min := s.min
 	if min.IsZero() {
 		min = s.dataMin
+		if !s.softMin.IsZero() && min.Before(s.softMin) {
+			min = s.softMin
+		}
 	}
 	max := s.max
 	if max.IsZero() {
 		max = s.dataMax
+		if !s.softMax.IsZero() && max.After(s.softMax) {
+			max = s.softMax
+		}
+	}
+	if min.After(max) {
+		// The data is entirely beyond one of the soft
+		// bounds, so collapse the domain to that bound.
+		if max.Equal(s.softMax) {
+			min = max
+		} else {
+			max = min
+		}
 	}
 	return min, max
 }
@@ -782,25 +1170,10 @@ func (s *timeScale) Map(x interface{}) interface{} {
 	min, max := s.getMinMax()
 	t := x.(time.Time)
 	var scaled float64 = float64(t.Sub(min)) / float64(max.Sub(min))
-
-	switch r := s.r.(type) {
-	case ContinuousRanger:
-		return r.Map(scaled)
-
-	case DiscreteRanger:
-		_, levels := r.Levels()
-		// Bin the scaled value into 'levels' bins.
-		level := int(scaled * float64(levels))
-		if level < 0 {
-			level = 0
-		} else if level >= levels {
-			level = levels - 1
-		}
-		return r.MapLevel(level, levels)
-
-	default:
-		panic("Ranger must be a ContinuousRanger or DiscreteRanger")
+	if s.reverse {
+		scaled = 1 - scaled
 	}
+	return mapUnit(s.r, scaled, s.oob)
 }
 
 type durationTicks time.Duration
@@ -912,6 +1285,31 @@ func (t *timeTicker) GuessLevel() int {
 	return int(2 * (math.Log10(float64(dur)/1e9) - 2))
 }
 
+// alignedLevel returns the highest tick level at which every time
+// in ts is a tick, so ts can be labeled like ticks at that level.
+func (t *timeTicker) alignedLevel(ts []time.Time) int {
+	// Limit the search to levels with a reasonable number of
+	// ticks.
+	const maxTicks = 10000
+	level := t.MaxLevel()
+levels:
+	for ; level > -21 && t.CountTicks(level-1) <= maxTicks; level-- {
+		ticks := make(map[int64]bool)
+		for _, tick := range t.TicksAtLevel(level).([]time.Time) {
+			ticks[tick.UnixNano()] = true
+		}
+		for _, x := range ts {
+			if !ticks[x.UnixNano()] {
+				continue levels
+			}
+		}
+		return level
+	}
+	// ts isn't aligned to any level we can check, so use the
+	// finest one.
+	return level
+}
+
 func (timeTicker) MaxLevel() int {
 	return len(timeTickerLevels) - 1
 }
@@ -954,6 +1352,19 @@ func (timeTicker) Label(cur, prev time.Time, level int) string {
 }
 
 func (s *timeScale) Ticks(maxTicks int, pred func(major, minor table.Slice, labels []string) bool) (table.Slice, table.Slice, []string) {
+	return s.ticks.apply(maxTicks, pred, func() table.Slice {
+		min, max := s.getMinMax()
+		return []time.Time{min, max}
+	}, s.autoTicks, func(major table.Slice) []string {
+		var ts []time.Time
+		slice.Convert(&ts, major)
+		ticker := &timeTicker{}
+		ticker.min, ticker.max = s.getMinMax()
+		return s.formatTicks(ts, ticker, ticker.alignedLevel(ts))
+	})
+}
+
+func (s *timeScale) autoTicks(maxTicks int, pred func(major, minor table.Slice, labels []string) bool) (table.Slice, table.Slice, []string) {
 	min, max := s.getMinMax()
 	ticker := &timeTicker{min, max}
 	o := scale.TickOptions{Max: maxTicks, MinLevel: -21, MaxLevel: ticker.MaxLevel()}
@@ -962,24 +1373,6 @@ func (s *timeScale) Ticks(maxTicks int, pred func(major, minor table.Slice, labe
 		// TODO(quentin): Better handling of too-large time range.
 		return nil, nil, nil
 	}
-	mkLabels := func(major []time.Time) []string {
-		// TODO(quentin): Pick a format based on which parts
-		// of the time have changed and are non-zero.
-		labels := make([]string, len(major))
-		if s.f != nil {
-			// Use custom formatter.
-			for i, x := range major {
-				labels[i] = s.f(x)
-			}
-			return labels
-		}
-		var prev time.Time
-		for i, t := range major {
-			labels[i] = ticker.Label(t, prev, level)
-			prev = t
-		}
-		return labels
-	}
 	var majors, minors []time.Time
 	var labels []string
 	for ; level <= o.MaxLevel; level++ {
@@ -987,7 +1380,7 @@ func (s *timeScale) Ticks(maxTicks int, pred func(major, minor table.Slice, labe
 		if level > o.MinLevel {
 			minors = ticker.TicksAtLevel(level - 1).([]time.Time)
 		}
-		labels = mkLabels(majors)
+		labels = s.formatTicks(majors, ticker, level)
 		if pred == nil || pred(majors, minors, labels) {
 			break
 		}
@@ -995,10 +1388,35 @@ func (s *timeScale) Ticks(maxTicks int, pred func(major, minor table.Slice, labe
 	return majors, minors, labels
 }
 
+// formatTicks returns the labels of the ticks in major, which are
+// ticks at level of ticker.
+func (s *timeScale) formatTicks(major []time.Time, ticker *timeTicker, level int) []string {
+	// TODO(quentin): Pick a format based on which parts
+	// of the time have changed and are non-zero.
+	labels := make([]string, len(major))
+	if s.f != nil {
+		// Use custom formatter.
+		for i, x := range major {
+			labels[i] = s.f(x)
+		}
+		return labels
+	}
+	var prev time.Time
+	for i, t := range major {
+		labels[i] = ticker.Label(t, prev, level)
+		prev = t
+	}
+	return labels
+}
+
 func (s *timeScale) SetFormatter(f interface{}) {
 	s.f = f.(func(time.Time) string)
 }
 
+func (s *timeScale) SetTicks(t TickSpec) {
+	s.ticks = t
+}
+
 func (s *timeScale) CloneScaler() Scaler {
 	s2 := *s
 	return &s2
@@ -1018,6 +1436,7 @@ type ordinalScale struct {
 	allData []slice.T
 	r       Ranger
 	f       interface{}
+	ticks   TickSpec
 	ordered table.Slice
 	index   map[interface{}]int
 }
@@ -1094,14 +1513,37 @@ func (s *ordinalScale) Map(x interface{}) interface{} {
 }
 
 func (s *ordinalScale) Ticks(max int, pred func(major, minor table.Slice, labels []string) bool) (major, minor table.Slice, labels []string) {
+	return s.ticks.apply(max, pred, func() table.Slice {
+		s.makeIndex()
+		return s.ordered
+	}, s.autoTicks, s.formatTicks)
+}
+
+func (s *ordinalScale) autoTicks(max int, pred func(major, minor table.Slice, labels []string) bool) (major, minor table.Slice, labels []string) {
 	// TODO: Return *no* ticks and only labels. Can't currently
 	// express this.
 
 	// TODO: Honor constraints.
 
 	s.makeIndex()
-	labels = make([]string, len(s.index))
+	major = s.ordered
 	ov := reflect.ValueOf(s.ordered)
+	if n := s.ticks.Count; n > 0 && ov.Len() > n {
+		// Show every k'th level.
+		k := (ov.Len() + n - 1) / n
+		mv := reflect.MakeSlice(ov.Type(), 0, n)
+		for i := 0; i < ov.Len(); i += k {
+			mv = reflect.Append(mv, ov.Index(i))
+		}
+		major = mv.Interface()
+	}
+	return major, nil, s.formatTicks(major)
+}
+
+// formatTicks returns the labels of the ticks in major.
+func (s *ordinalScale) formatTicks(major table.Slice) []string {
+	ov := reflect.ValueOf(major)
+	labels := make([]string, ov.Len())
 
 	if s.f != nil {
 		// Use custom formatter.
@@ -1120,13 +1562,17 @@ func (s *ordinalScale) Ticks(max int, pred func(major, minor table.Slice, labels
 			labels[i] = fmt.Sprintf("%v", ov.Index(i).Interface())
 		}
 	}
-	return s.ordered, nil, labels
+	return labels
 }
 
 func (s *ordinalScale) SetFormatter(f interface{}) {
 	s.f = f
 }
 
+func (s *ordinalScale) SetTicks(t TickSpec) {
+	s.ticks = t
+}
+
 func (s *ordinalScale) CloneScaler() Scaler {
 	ns := &ordinalScale{
 		allData: make([]slice.T, len(s.allData)),
@@ -1235,6 +1681,11 @@ func (r *defaultColorRanger) RangeType() reflect.Type {
 }
 
 func (r *defaultColorRanger) Map(x float64) interface{} {
+	if math.IsNaN(x) {
+		// Show missing values, such as those censored by
+		// OOBCensor, in gray.
+		return color.Gray{127}
+	}
 	return palette.Viridis.Map(x)
 }
 
@@ -0,0 +1,236 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/aclements/go-gg/table"
+)
+
+func TestOOB(t *testing.T) {
+	scalers := []struct {
+		name string
+		new  func() ContinuousScaler
+	}{
+		{"linear", NewLinearScaler},
+		{"log", func() ContinuousScaler { return NewLogScaler(10) }},
+		{"sqrt", NewSqrtScaler},
+	}
+	// check reports whether got is acceptable for a value that is
+	// below the domain, in the domain, or above the domain.
+	type check func(got float64) bool
+	isNaN := func(got float64) bool { return math.IsNaN(got) }
+	is := func(want float64) check {
+		return func(got float64) bool { return got == want }
+	}
+	below := func(got float64) bool { return got < 0 }
+	above := func(got float64) bool { return got > 1 }
+	inside := func(got float64) bool { return 0 < got && got < 1 }
+	for _, test := range []struct {
+		oob                  OOB
+		below, inside, above check
+	}{
+		{OOBKeep, below, inside, above},
+		{OOBCensor, isNaN, inside, isNaN},
+		{OOBSquish, is(0), inside, is(1)},
+	} {
+		for _, sc := range scalers {
+			s := sc.new().SetMin(1).SetMax(100).SetOOB(test.oob)
+			s.Ranger(NewFloatRanger(0, 1))
+			for _, c := range []struct {
+				x  float64
+				ok check
+			}{
+				{0.5, test.below},
+				{10, test.inside},
+				{1000, test.above},
+			} {
+				got := s.Map(c.x).(float64)
+				if !c.ok(got) {
+					t.Errorf("%s scale with OOB %d: Map(%v) = %v", sc.name, test.oob, c.x, got)
+				}
+			}
+		}
+	}
+}
+
+func TestOOBDefault(t *testing.T) {
+	// Linear scales keep out-of-bounds values by default, but log
+	// scales squish them, as they did before SetOOB.
+	for _, test := range []struct {
+		name             string
+		s                ContinuousScaler
+		below, above, x0 float64
+	}{
+		{"linear", NewLinearScaler(), -0.5 / 99, 999.0 / 99, -1.0 / 99},
+		{"log", NewLogScaler(10), 0, 1, 0},
+	} {
+		s := test.s.SetMin(1).SetMax(100)
+		s.Ranger(NewFloatRanger(0, 1))
+		for _, c := range []struct{ x, want float64 }{
+			{0.5, test.below},
+			{1000, test.above},
+			{0, test.x0},
+		} {
+			if got := s.Map(c.x).(float64); math.Abs(got-c.want) > 1e-9 {
+				t.Errorf("%s scale: Map(%v) = %v, want %v", test.name, c.x, got, c.want)
+			}
+		}
+	}
+}
+
+func TestOOBNoPosition(t *testing.T) {
+	// Values that a scale can't map, such as 0 on a log scale,
+	// are beyond the nearest bound.
+	nan := math.NaN()
+	for _, test := range []struct {
+		name string
+		s    ContinuousScaler
+		x    float64
+		// want gives the result for OOBKeep, OOBCensor, and
+		// OOBSquish.
+		want [3]float64
+	}{
+		{"log", NewLogScaler(10).SetMin(1).SetMax(100), 0, [3]float64{nan, nan, 0}},
+		{"log", NewLogScaler(10).SetMin(1).SetMax(100), -1, [3]float64{nan, nan, 0}},
+		{"negative log", NewLogScaler(10).SetMin(-100).SetMax(-1), 1, [3]float64{nan, nan, 1}},
+		{"reverse log", NewLogScaler(10).SetMin(1).SetMax(100).SetReverse(true), 0, [3]float64{nan, nan, 1}},
+		{"logit", NewLogitScaler().SetMin(0.1).SetMax(0.9), 0, [3]float64{nan, nan, 0}},
+		{"logit", NewLogitScaler().SetMin(0.1).SetMax(0.9), 1, [3]float64{nan, nan, 1}},
+		{"log duration", NewLogDurationScaler().SetMin(1e6).SetMax(1e9), 0, [3]float64{nan, nan, 0}},
+	} {
+		test.s.Ranger(NewFloatRanger(0, 1))
+		for i, oob := range []OOB{OOBKeep, OOBCensor, OOBSquish} {
+			got := test.s.SetOOB(oob).Map(test.x).(float64)
+			want := test.want[i]
+			if !(got == want || math.IsNaN(got) && math.IsNaN(want)) {
+				t.Errorf("%s scale with OOB %d: Map(%v) = %v, want %v", test.name, oob, test.x, got, want)
+			}
+		}
+	}
+}
+
+func TestTickSpecApply(t *testing.T) {
+	// auto records the max it was called with and returns fixed
+	// ticks.
+	var autoMax int
+	auto := func(max int, pred func(major, minor table.Slice, labels []string) bool) (table.Slice, table.Slice, []string) {
+		autoMax = max
+		return []float64{0, 10}, []float64{0, 5, 10}, []string{"auto0", "auto10"}
+	}
+	domain := func() table.Slice { return []float64{0, 10} }
+	format := func(major table.Slice) []string {
+		var labels []string
+		for _, x := range major.([]float64) {
+			labels = append(labels, fmt.Sprintf("f%g", x))
+		}
+		return labels
+	}
+	pred := func(major, minor table.Slice, labels []string) bool { return true }
+
+	// funcMax records the domain and max passed to a TickFunc.
+	var funcDomain table.Slice
+	var funcMax int
+	tickFunc := func(major, minor table.Slice, labels []string) TickFunc {
+		return func(domain table.Slice, max int) (table.Slice, table.Slice, []string) {
+			funcDomain, funcMax = domain, max
+			return major, minor, labels
+		}
+	}
+
+	for _, test := range []struct {
+		name                  string
+		spec                  TickSpec
+		major, minor          table.Slice
+		labels                []string
+		wantAutoMax, wantFunc int
+	}{
+		{
+			"zero", TickSpec{},
+			[]float64{0, 10}, []float64{0, 5, 10}, []string{"auto0", "auto10"}, 5, 0,
+		},
+		{
+			// Count caps the ticks computed by auto.
+			"count", TickSpec{Count: 2},
+			[]float64{0, 10}, []float64{0, 5, 10}, []string{"auto0", "auto10"}, 2, 0,
+		},
+		{
+			// Without Minor or Labels, the major ticks are
+			// also the minor ticks and are formatted.
+			"major", TickSpec{Major: []float64{1, 2}},
+			[]float64{1, 2}, []float64{1, 2}, []string{"f1", "f2"}, 0, 0,
+		},
+		{
+			"major minor labels", TickSpec{Major: []float64{1, 2}, Minor: []float64{1, 1.5, 2}, Labels: []string{"one", "two"}},
+			[]float64{1, 2}, []float64{1, 1.5, 2}, []string{"one", "two"}, 0, 0,
+		},
+		{
+			// Major takes precedence over Func.
+			"major and func", TickSpec{Major: []float64{1}, Func: tickFunc([]float64{2}, nil, nil)},
+			[]float64{1}, []float64{1}, []string{"f1"}, 0, 0,
+		},
+		{
+			"func", TickSpec{Func: tickFunc([]float64{3, 4}, nil, nil)},
+			[]float64{3, 4}, []float64{3, 4}, []string{"f3", "f4"}, 0, 5,
+		},
+		{
+			"func count labels", TickSpec{Count: 3, Func: tickFunc([]float64{3, 4}, []float64{3, 4, 5}, []string{"c", "d"})},
+			[]float64{3, 4}, []float64{3, 4, 5}, []string{"c", "d"}, 0, 3,
+		},
+		{
+			// A TickFunc can return no ticks.
+			"func nil", TickSpec{Func: tickFunc(nil, []float64{1}, []string{"x"})},
+			nil, nil, nil, 0, 5,
+		},
+	} {
+		autoMax, funcDomain, funcMax = 0, nil, 0
+		major, minor, labels := test.spec.apply(5, pred, domain, auto, format)
+		if !reflect.DeepEqual(major, test.major) || !reflect.DeepEqual(minor, test.minor) || !reflect.DeepEqual(labels, test.labels) {
+			t.Errorf("%s: got ticks %v, %v, %v; want %v, %v, %v", test.name, major, minor, labels, test.major, test.minor, test.labels)
+		}
+		if autoMax != test.wantAutoMax {
+			t.Errorf("%s: auto called with max %d, want %d", test.name, autoMax, test.wantAutoMax)
+		}
+		if funcMax != test.wantFunc {
+			t.Errorf("%s: Func called with max %d, want %d", test.name, funcMax, test.wantFunc)
+		}
+		if test.wantFunc != 0 && !reflect.DeepEqual(funcDomain, []float64{0, 10}) {
+			t.Errorf("%s: Func called with domain %v, want [0 10]", test.name, funcDomain)
+		}
+	}
+
+	// Labels must match the major ticks.
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("mismatched labels: want panic")
+			}
+		}()
+		spec := TickSpec{Major: []float64{1, 2}, Labels: []string{"one"}}
+		spec.apply(5, pred, domain, auto, format)
+	}()
+}
+
+func TestTickSpecScale(t *testing.T) {
+	s := NewLinearScaler()
+	s.ExpandDomain([]float64{0, 100})
+	s.Ranger(NewFloatRanger(0, 1))
+	all := func(major, minor table.Slice, labels []string) bool { return true }
+
+	s.SetTicks(TickSpec{Count: 3})
+	if major, _, _ := s.Ticks(10, all); major == nil || reflect.ValueOf(major).Len() > 3 {
+		t.Errorf("Count 3: got major ticks %v", major)
+	}
+
+	s.SetTicks(TickSpec{Major: []int{25, 75}})
+	major, minor, labels := s.Ticks(10, all)
+	if !reflect.DeepEqual(major, []int{25, 75}) || !reflect.DeepEqual(minor, []int{25, 75}) || !reflect.DeepEqual(labels, []string{"25", "75"}) {
+		t.Errorf("Major: got ticks %v, %v, %v", major, minor, labels)
+	}
+}
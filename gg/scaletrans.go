@@ -0,0 +1,515 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aclements/go-moremath/scale"
+)
+
+// NewSqrtScaler returns a continuous square root scale. This is
+// useful for counts and areas. Negative values are mapped
+// symmetrically to their absolute values.
+func NewSqrtScaler() ContinuousScaler {
+	return NewPowerScaler(0.5)
+}
+
+// NewPowerScaler returns a continuous scale that maps x to x^exp.
+// exp must be positive. Negative values are mapped symmetrically to
+// their absolute values, so the scale is defined everywhere.
+func NewPowerScaler(exp float64) ContinuousScaler {
+	if !(exp > 0) || math.IsInf(exp, 0) {
+		panic(fmt.Sprintf("power scale exponent must be positive; got %g", exp))
+	}
+	s := NewLinearScaler().(*moremathScale)
+	s.trans = powerTransform(exp)
+	return s
+}
+
+// NewSymLogScaler returns a continuous "symmetric log" scale. This
+// is like a log scale in the given base, but is defined for zero
+// and negative values, which makes it useful for signed data that
+// spans many orders of magnitude. The scale is approximately linear
+// for values whose magnitude is less than threshold, and
+// approximately logarithmic beyond that. threshold must be positive.
+func NewSymLogScaler(base int, threshold float64) ContinuousScaler {
+	if base < 2 {
+		panic(fmt.Sprintf("symlog scale base must be at least 2; got %d", base))
+	}
+	if !(threshold > 0) || math.IsInf(threshold, 0) {
+		panic(fmt.Sprintf("symlog scale threshold must be positive; got %g", threshold))
+	}
+	s := NewLinearScaler().(*moremathScale)
+	s.trans = symLogTransform{float64(base), threshold}
+	return s
+}
+
+// NewLogitScaler returns a continuous logit scale, which maps p to
+// log(p/(1-p)). This is useful for proportions and probabilities,
+// since it spreads out values near 0 and 1. The domain is the open
+// interval (0, 1) and values outside of it are ignored when training
+// the scale. Bounds of 0 or 1 given to SetMin, SetMax, or Include
+// extend the domain to 0.001 or 0.999, or to the data if it extends
+// further.
+func NewLogitScaler() ContinuousScaler {
+	s := NewLinearScaler().(*moremathScale)
+	s.trans = logitTransform{}
+	return s
+}
+
+// A scaleTransform is a monotonically increasing, invertible
+// function applied to values before a moremathScale maps them
+// linearly to [0, 1].
+type scaleTransform interface {
+	String() string
+
+	transform(x float64) float64
+	inverse(y float64) float64
+
+	// ticker returns a Ticker for "nice" ticks between min and
+	// max in the untransformed domain.
+	ticker(min, max float64) scale.Ticker
+
+	// clampBound returns a bound to use in place of x, a domain
+	// bound that transforms to a non-finite value.
+	clampBound(x float64) float64
+}
+
+// transformMapper is a tickMapper that applies a scaleTransform and
+// then maps the transformed domain [lo, hi] linearly to [0, 1].
+type transformMapper struct {
+	scale.Ticker
+	t      scaleTransform
+	lo, hi float64
+}
+
+func newTransformMapper(t scaleTransform, min, max float64) *transformMapper {
+	lo, hi := t.transform(min), t.transform(max)
+	if isFinite(lo) && lo == hi {
+		// Center a single value in the range, like a linear
+		// scale, but in the transformed space.
+		lo, hi = lo-0.5, hi+0.5
+		min, max = t.inverse(lo), t.inverse(hi)
+	}
+	if !isFinite(lo) || !isFinite(hi) {
+		panic(fmt.Sprintf("domain [%g,%g] is invalid for %s scale", min, max, t))
+	}
+	return &transformMapper{t.ticker(min, max), t, lo, hi}
+}
+
+func (m *transformMapper) Map(x float64) float64 {
+	return (m.t.transform(x) - m.lo) / (m.hi - m.lo)
+}
+
+func (m *transformMapper) Unmap(y float64) float64 {
+	return m.t.inverse(m.lo + y*(m.hi-m.lo))
+}
+
+// reverseMapper is a tickMapper that reverses the output of another
+// tickMapper.
+type reverseMapper struct {
+	tickMapper
+}
+
+func (m reverseMapper) Map(x float64) float64 {
+	return 1 - m.tickMapper.Map(x)
+}
+
+func (m reverseMapper) Unmap(y float64) float64 {
+	return m.tickMapper.Unmap(1 - y)
+}
+
+// powerTransform maps x to sign(x)*|x|^exp.
+type powerTransform float64
+
+func (t powerTransform) String() string {
+	if t == 0.5 {
+		return "sqrt"
+	}
+	return fmt.Sprintf("power(%g)", float64(t))
+}
+
+func (t powerTransform) transform(x float64) float64 {
+	if x < 0 {
+		return -math.Pow(-x, float64(t))
+	}
+	return math.Pow(x, float64(t))
+}
+
+func (t powerTransform) inverse(y float64) float64 {
+	return powerTransform(1 / t).transform(y)
+}
+
+func (t powerTransform) ticker(min, max float64) scale.Ticker {
+	// Power scales don't distort values enough to make linear
+	// ticks hard to read, and linear ticks have the nicest
+	// values.
+	return &scale.Linear{Min: min, Max: max}
+}
+
+func (t powerTransform) clampBound(x float64) float64 {
+	return x
+}
+
+// symLogTransform maps x to sign(x)*log_base(1+|x|/threshold).
+type symLogTransform struct {
+	base, threshold float64
+}
+
+func (t symLogTransform) String() string {
+	return fmt.Sprintf("symlog(%g,%g)", t.base, t.threshold)
+}
+
+func (t symLogTransform) transform(x float64) float64 {
+	y := math.Log1p(math.Abs(x)/t.threshold) / math.Log(t.base)
+	return math.Copysign(y, x)
+}
+
+func (t symLogTransform) inverse(y float64) float64 {
+	x := t.threshold * math.Expm1(math.Abs(y)*math.Log(t.base))
+	return math.Copysign(x, y)
+}
+
+func (t symLogTransform) ticker(min, max float64) scale.Ticker {
+	if math.Max(math.Abs(min), math.Abs(max)) < t.threshold*t.base {
+		// The domain doesn't span any powers of the base, so
+		// the scale is nearly linear.
+		return &scale.Linear{Min: min, Max: max}
+	}
+	return &symLogTicker{t, min, max}
+}
+
+func (t symLogTransform) clampBound(x float64) float64 {
+	return x
+}
+
+// symLogTicker generates ticks for a symLogTransform at 0 and at
+// ±threshold*base^k for k >= 0, which are evenly spaced in the
+// transformed space.
+type symLogTicker struct {
+	t        symLogTransform
+	min, max float64
+}
+
+func (t *symLogTicker) CountTicks(level int) int {
+	if level < minLogTickLevel {
+		return math.MaxInt32
+	}
+	return len(t.TicksAtLevel(level).([]float64))
+}
+
+func (t *symLogTicker) TicksAtLevel(level int) interface{} {
+	var ticks []float64
+	add := func(x float64) {
+		if t.min <= x && x <= t.max {
+			ticks = append(ticks, x)
+		}
+	}
+	add(0)
+	limit := math.Max(math.Abs(t.min), math.Abs(t.max))
+	decade := t.t.threshold
+	for k := 1; decade <= limit; k++ {
+		if logTickDecade(level, k) {
+			for _, m := range logTickMantissas(level, t.t.base) {
+				add(m * decade)
+				add(-m * decade)
+			}
+		}
+		decade *= t.t.base
+	}
+	sort.Float64s(ticks)
+	return ticks
+}
+
+// logitTransform maps p to log(p/(1-p)).
+type logitTransform struct{}
+
+func (logitTransform) String() string {
+	return "logit"
+}
+
+func (logitTransform) transform(p float64) float64 {
+	return math.Log(p / (1 - p))
+}
+
+func (logitTransform) inverse(y float64) float64 {
+	return 1 / (1 + math.Exp(-y))
+}
+
+func (logitTransform) ticker(min, max float64) scale.Ticker {
+	return &logitTicker{min, max}
+}
+
+func (logitTransform) clampBound(p float64) float64 {
+	if p <= 0 {
+		return 0.001
+	} else if p >= 1 {
+		return 0.999
+	}
+	return p
+}
+
+// logitTicker generates ticks for a logitTransform at 1/2, 10^-k, and
+// 1-10^-k for k >= 1, which are roughly evenly spaced in the
+// transformed space.
+type logitTicker struct {
+	min, max float64
+}
+
+func (t *logitTicker) CountTicks(level int) int {
+	if level < minLogTickLevel {
+		return math.MaxInt32
+	}
+	return len(t.TicksAtLevel(level).([]float64))
+}
+
+func (t *logitTicker) TicksAtLevel(level int) interface{} {
+	seen := make(map[float64]bool)
+	var ticks []float64
+	add := func(p float64) {
+		if t.min <= p && p <= t.max && !seen[p] {
+			seen[p] = true
+			ticks = append(ticks, p)
+		}
+	}
+	add(0.5)
+	limit := math.Min(t.min, 1-t.max)
+	for k := 1; math.Pow(10, float64(1-k)) > limit; k++ {
+		if !logTickDecade(level, k) {
+			continue
+		}
+		for _, m := range logTickMantissas(level, 10) {
+			// Compute 1-p as (d-m)/d so it rounds the
+			// same as the equivalent p.
+			d := math.Pow(10, float64(k))
+			add(m / d)
+			add((d - m) / d)
+		}
+	}
+	sort.Float64s(ticks)
+	return ticks
+}
+
+// minLogTickLevel is the lowest tick level of log-like tickers. At
+// this level, every integer mantissa of every decade has a tick.
+// Lower levels report an unbounded number of ticks so tick level
+// searches stop here.
+const minLogTickLevel = -2
+
+// logTickDecade returns whether the k'th decade away from the center
+// tick of a log-like ticker has ticks at level. At levels above 0,
+// decades are skipped to reduce the number of ticks. The center tick
+// is decade 0, so it is always present.
+func logTickDecade(level, k int) bool {
+	return level <= 0 || k%(level+1) == 0
+}
+
+// logTickMantissas returns the mantissas of the ticks in each decade
+// of a log-like ticker at level.
+func logTickMantissas(level int, base float64) []float64 {
+	var ms []float64
+	switch {
+	case level >= 0:
+		return []float64{1}
+	case level == -1:
+		for _, m := range []float64{1, 2, 5} {
+			if m < base {
+				ms = append(ms, m)
+			}
+		}
+	default:
+		for m := 1.0; m < base; m++ {
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
+
+// NewDurationScaler returns a continuous linear scale for
+// time.Duration values. Its ticks fall on round durations such as
+// multiples of 10 milliseconds, 30 seconds, or 6 hours, and are
+// labeled like "250ms", "1m30s", or "2h".
+func NewDurationScaler() ContinuousScaler {
+	s := NewLinearScaler().(*moremathScale)
+	s.trans = durationTransform{}
+	return s
+}
+
+// NewLogDurationScaler is like NewDurationScaler, but returns a
+// logarithmic scale. Durations that are not positive are ignored
+// when training the scale. A bound of 0 given to SetMin or Include
+// extends the domain to 1ns.
+func NewLogDurationScaler() ContinuousScaler {
+	s := NewLinearScaler().(*moremathScale)
+	s.trans = durationTransform{log: true}
+	return s
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// formatDuration formats d like time.Duration.String, but omits
+// trailing zero units, so 2 hours is "2h" rather than "2h0m0s".
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// durationTransform maps a duration in nanoseconds to seconds, or to
+// the log of seconds if log is true. Using seconds rather than
+// nanoseconds keeps a single value centered in a reasonable range.
+type durationTransform struct {
+	log bool
+}
+
+func (t durationTransform) String() string {
+	if t.log {
+		return "log duration"
+	}
+	return "duration"
+}
+
+func (t durationTransform) transform(x float64) float64 {
+	if t.log {
+		return math.Log10(x / 1e9)
+	}
+	return x / 1e9
+}
+
+func (t durationTransform) inverse(y float64) float64 {
+	if t.log {
+		return math.Pow(10, y) * 1e9
+	}
+	return y * 1e9
+}
+
+func (t durationTransform) ticker(min, max float64) scale.Ticker {
+	if t.log {
+		lt := &logDurationTicker{min, max}
+		if lt.CountTicks(0) >= 2 {
+			return lt
+		}
+		// The domain is too narrow for log ticks, so
+		// fall back to linear duration ticks.
+	}
+	return &durationTicker{min, max}
+}
+
+func (t durationTransform) clampBound(x float64) float64 {
+	if t.log && x <= 0 {
+		// Extend the domain to the shortest duration.
+		return 1
+	}
+	return x
+}
+
+// durationSteps are the tick spacings of a durationTicker from 1
+// second to 1 day. Each step is a multiple of the previous step, so
+// minor ticks are a superset of major ticks.
+var durationSteps = []time.Duration{
+	time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute, 10 * time.Minute, 30 * time.Minute,
+	time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour,
+	24 * time.Hour,
+}
+
+// durationTicker generates ticks at multiples of round durations.
+// Level 0 is 1ns. Below 1 second, the levels alternate between
+// multiples of 1 and 5 times a power of ten, like a linear scale.
+// From 1 second to 1 day, they follow durationSteps. Beyond that,
+// they alternate between 5 and 10 times a power of ten days.
+type durationTicker struct {
+	min, max float64
+}
+
+// subSecondLevels is the number of durationTicker levels below 1
+// second.
+const subSecondLevels = 18
+
+func (t *durationTicker) spacing(level int) float64 {
+	if level < 0 {
+		level = 0
+	}
+	mult := [2]float64{1, 5}
+	if level < subSecondLevels {
+		return mult[level%2] * math.Pow(10, float64(level/2))
+	}
+	level -= subSecondLevels
+	if level < len(durationSteps) {
+		return float64(durationSteps[level])
+	}
+	level -= len(durationSteps)
+	return float64(24*time.Hour) * mult[(level+1)%2] * math.Pow(10, float64((level+1)/2))
+}
+
+func (t *durationTicker) CountTicks(level int) int {
+	s := t.spacing(level)
+	return int(math.Floor(t.max/s) - math.Ceil(t.min/s) + 1)
+}
+
+func (t *durationTicker) TicksAtLevel(level int) interface{} {
+	s := t.spacing(level)
+	var ticks []float64
+	for k := math.Ceil(t.min / s); k*s <= t.max; k++ {
+		ticks = append(ticks, k*s)
+	}
+	return ticks
+}
+
+// logDurations are the durations at which logDurationTicker has
+// ticks at level 1. They are roughly evenly spaced on a log scale.
+var logDurations = []time.Duration{
+	time.Nanosecond, 10 * time.Nanosecond, 100 * time.Nanosecond,
+	time.Microsecond, 10 * time.Microsecond, 100 * time.Microsecond,
+	time.Millisecond, 10 * time.Millisecond, 100 * time.Millisecond,
+	time.Second, 10 * time.Second, time.Minute, 10 * time.Minute,
+	time.Hour, 10 * time.Hour, 100 * time.Hour, 1000 * time.Hour,
+	10000 * time.Hour, 100000 * time.Hour,
+}
+
+// logDurationTicker generates ticks for a log duration scale. At
+// level 1, there are ticks at each of logDurations. Level 0 adds
+// ticks at small multiples of these, and higher levels skip
+// durations.
+type logDurationTicker struct {
+	min, max float64
+}
+
+func (t *logDurationTicker) CountTicks(level int) int {
+	return len(t.TicksAtLevel(level).([]float64))
+}
+
+func (t *logDurationTicker) TicksAtLevel(level int) interface{} {
+	var ticks []float64
+	for i, d := range logDurations {
+		mults := []float64{1}
+		if level <= 0 {
+			mults = []float64{1, 2, 5}
+			if i+1 < len(logDurations) && logDurations[i+1] != 10*d {
+				mults = []float64{1, 2, 3}
+			}
+		} else if i%level != 0 {
+			continue
+		}
+		for _, m := range mults {
+			x := m * float64(d)
+			if t.min <= x && x <= t.max {
+				ticks = append(ticks, x)
+			}
+		}
+	}
+	return ticks
+}
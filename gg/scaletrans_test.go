@@ -0,0 +1,153 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDurationTicks(t *testing.T) {
+	const (
+		ms = time.Millisecond
+		s  = time.Second
+		m  = time.Minute
+		h  = time.Hour
+	)
+	for _, test := range []struct {
+		log      bool
+		min, max time.Duration
+		maxTicks int
+		want     []string
+	}{
+		{false, 200 * ms, 300 * ms, 3, []string{"200ms", "250ms", "300ms"}},
+		{false, 1 * m, 2 * m, 3, []string{"1m", "1m30s", "2m"}},
+		{false, 0, 4 * h, 5, []string{"0s", "1h", "2h", "3h", "4h"}},
+		{false, 0, 3 * 24 * h, 4, []string{"0s", "24h", "48h", "72h"}},
+
+		{true, 1 * ms, 1 * h, 5, []string{"1ms", "100ms", "10s", "10m"}},
+		{true, 1 * s, 1 * m, 10, []string{"1s", "2s", "5s", "10s", "20s", "30s", "1m"}},
+		// Too narrow for any of the log ticks, so these fall
+		// back to linear duration ticks.
+		{true, 1500 * ms, 1800 * ms, 5, []string{"1.5s", "1.6s", "1.7s", "1.8s"}},
+		{true, 900 * ms, 1100 * ms, 3, []string{"900ms", "1s", "1.1s"}},
+	} {
+		sc, kind := NewDurationScaler(), "linear"
+		if test.log {
+			sc, kind = NewLogDurationScaler(), "log"
+		}
+		sc.ExpandDomain([]time.Duration{test.min, test.max})
+		_, _, labels := sc.Ticks(test.maxTicks, nil)
+		if !reflect.DeepEqual(labels, test.want) {
+			t.Errorf("%s [%v, %v] with %d ticks: got labels %q, want %q", kind, test.min, test.max, test.maxTicks, labels, test.want)
+		}
+	}
+}
+
+func TestTransformScalers(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		s      ContinuousScaler
+		domain []float64
+		// xs and ys are points in the domain and where they
+		// map to in [0, 1].
+		xs, ys []float64
+		// ticks are the labels of the major ticks with at most
+		// maxTicks ticks.
+		maxTicks int
+		ticks    []string
+	}{
+		{
+			"sqrt", NewSqrtScaler(), []float64{0, 100},
+			[]float64{0, 25, 100}, []float64{0, 0.5, 1},
+			6, []string{"0", "50", "100"},
+		},
+		{
+			"power", NewPowerScaler(2), []float64{0, 10},
+			[]float64{0, 5, 10}, []float64{0, 0.25, 1},
+			6, []string{"0", "5", "10"},
+		},
+		{
+			"symlog", NewSymLogScaler(10, 1), []float64{-99, 99},
+			[]float64{-99, -9, 0, 9, 99}, []float64{0, 0.25, 0.5, 0.75, 1},
+			7, []string{"-10", "-1", "0", "1", "10"},
+		},
+		{
+			"logit", NewLogitScaler(), []float64{0.1, 0.9},
+			[]float64{0.1, 0.5, 0.9}, []float64{0, 0.5, 1},
+			5, []string{"0.1", "0.2", "0.5", "0.8", "0.9"},
+		},
+		{
+			"reverse linear", NewLinearScaler().SetReverse(true), []float64{0, 10},
+			[]float64{0, 2, 10}, []float64{1, 0.8, 0},
+			3, []string{"0", "5", "10"},
+		},
+		{
+			"reverse log", NewLogScaler(10).SetReverse(true), []float64{1, 100},
+			[]float64{1, 10, 100}, []float64{1, 0.5, 0},
+			3, []string{"1", "10", "100"},
+		},
+		{
+			"reverse sqrt", NewSqrtScaler().SetReverse(true), []float64{0, 100},
+			[]float64{0, 25, 100}, []float64{1, 0.5, 0},
+			6, []string{"0", "50", "100"},
+		},
+	} {
+		test.s.ExpandDomain(test.domain)
+		test.s.Ranger(NewFloatRanger(0, 1))
+		m := test.s.(*moremathScale).get()
+		for i, x := range test.xs {
+			if got := test.s.Map(x).(float64); math.Abs(got-test.ys[i]) > 1e-9 {
+				t.Errorf("%s: Map(%v) = %v, want %v", test.name, x, got, test.ys[i])
+			}
+			if got := m.Unmap(test.ys[i]); math.Abs(got-x) > 1e-9 {
+				t.Errorf("%s: Unmap(%v) = %v, want %v", test.name, test.ys[i], got, x)
+			}
+		}
+		_, _, labels := test.s.Ticks(test.maxTicks, nil)
+		if !reflect.DeepEqual(labels, test.ticks) {
+			t.Errorf("%s: got tick labels %q, want %q", test.name, labels, test.ticks)
+		}
+	}
+}
+
+func TestTransformBounds(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		s        ContinuousScaler
+		data     []float64
+		min, max float64
+	}{
+		{"sqrt", NewSqrtScaler().SetMin(0), []float64{4, 16}, 0, 16},
+		{"sqrt include", NewSqrtScaler().Include(25), []float64{4, 16}, 4, 25},
+		{"symlog include", NewSymLogScaler(10, 1).Include(-1000), []float64{1, 10}, -1000, 10},
+		{"power", NewPowerScaler(2).SetMin(-5).SetMax(5), []float64{1, 2}, -5, 5},
+
+		// Bounds of 0 and 1 are replaced on logit scales.
+		{"logit", NewLogitScaler().SetMin(0).SetMax(1), []float64{0.2, 0.8}, 0.001, 0.999},
+		{"logit include 0", NewLogitScaler().Include(0), []float64{0.2, 0.8}, 0.001, 0.8},
+		{"logit include 1", NewLogitScaler().Include(1), []float64{0.2, 0.8}, 0.2, 0.999},
+		{"logit data", NewLogitScaler().SetMin(0).SetMax(1), []float64{1e-5, 0.5}, 1e-5, 0.999},
+		{"logit ignore", NewLogitScaler(), []float64{0, 0.2, 0.8, 1}, 0.2, 0.8},
+		{"logit empty", NewLogitScaler(), nil, 0.001, 0.999},
+		{"log duration", NewLogDurationScaler().SetMin(0), []float64{1e6, 1e9}, 1, 1e9},
+	} {
+		if test.data != nil {
+			test.s.ExpandDomain(test.data)
+		}
+		test.s.Ranger(NewFloatRanger(0, 1))
+		min, max := test.s.(*moremathScale).bounds()
+		if min != test.min || max != test.max {
+			t.Errorf("%s: domain is [%v, %v], want [%v, %v]", test.name, min, max, test.min, test.max)
+		}
+		// Mapping must not panic and the domain must map to
+		// [0, 1].
+		if lo, hi := test.s.Map(min).(float64), test.s.Map(max).(float64); math.Abs(lo) > 1e-9 || math.Abs(hi-1) > 1e-9 {
+			t.Errorf("%s: domain maps to [%v, %v], want [0, 1]", test.name, lo, hi)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import (
+	"image/color"
+	"math"
+	"reflect"
+)
+
+// A Shape is the shape of a point mark. Shapes are the range type of
+// the "shape" aesthetic.
+//
+// Solid shapes are filled with the point's color. Open shapes are
+// outlined with the point's color and filled with the point's fill
+// color, if any. ShapeCross and ShapePlus are always drawn as lines.
+type Shape int
+
+const (
+	ShapeCircle Shape = iota
+	ShapeTriangle
+	ShapeSquare
+	ShapeDiamond
+	ShapePlus
+	ShapeCross
+	ShapeCircleOpen
+	ShapeTriangleOpen
+	ShapeSquareOpen
+	ShapeDiamondOpen
+)
+
+var shapeType = reflect.TypeOf(Shape(0))
+
+// open returns whether shape is drawn with a stroke rather than a
+// fill.
+func (s Shape) open() bool {
+	return s >= ShapePlus
+}
+
+// defaultShapes is the palette of shapes used by the default "shape"
+// Ranger. It alternates solid and open shapes so that adjacent levels
+// differ in more than just their outline.
+var defaultShapes = []Shape{
+	ShapeCircle, ShapeTriangleOpen, ShapeSquare, ShapePlus,
+	ShapeDiamond, ShapeCircleOpen, ShapeTriangle, ShapeCross,
+	ShapeSquareOpen, ShapeDiamondOpen,
+}
+
+// NewShapeRanger returns a DiscreteRanger that maps each level to
+// the corresponding Shape in shapes. Levels beyond the end of shapes
+// map to the last Shape.
+func NewShapeRanger(shapes []Shape) DiscreteRanger {
+	return &shapeRanger{shapes}
+}
+
+type shapeRanger struct {
+	shapes []Shape
+}
+
+func (r *shapeRanger) RangeType() reflect.Type {
+	return shapeType
+}
+
+func (r *shapeRanger) Levels() (min, max int) {
+	return len(r.shapes), len(r.shapes)
+}
+
+func (r *shapeRanger) MapLevel(i, j int) interface{} {
+	if i < 0 {
+		i = 0
+	} else if i >= len(r.shapes) {
+		i = len(r.shapes) - 1
+	}
+	return r.shapes[i]
+}
+
+// drawShape draws shape centered at (x, y) with a size comparable to
+// a circle of radius r. Solid shapes are filled with c. Open shapes
+// are stroked with c and filled with fill, which may be nil.
+func drawShape(canvas Canvas, shape Shape, x, y, r float64, c, fill color.Color) {
+	if shape == ShapeCircle {
+		canvas.Circle(x, y, r, PathStyle{Fill: c})
+		return
+	}
+
+	style := PathStyle{Fill: c}
+	if shape.open() {
+		// Inset the outline so open shapes cover the same
+		// area as solid shapes.
+		width := math.Max(1, r/3)
+		if shape == ShapePlus || shape == ShapeCross {
+			fill = nil
+		}
+		style = PathStyle{Stroke: c, Fill: fill, StrokeWidth: width}
+		r -= width / 2
+	}
+
+	var p Path
+	switch shape {
+	case ShapeCircleOpen:
+		canvas.Circle(x, y, r, style)
+		return
+
+	case ShapeTriangle, ShapeTriangleOpen:
+		// Use an equilateral triangle whose centroid is at
+		// (x, y). Its circumradius is larger than r so it
+		// looks about as large as a circle.
+		R := 1.3 * r
+		p.MoveTo(x, y-R)
+		p.LineTo(x+R*math.Sqrt(3)/2, y+R/2)
+		p.LineTo(x-R*math.Sqrt(3)/2, y+R/2)
+		p.Close()
+
+	case ShapeSquare, ShapeSquareOpen:
+		// A square with the same area as the circle.
+		h := r * math.Sqrt(math.Pi) / 2
+		p = *rectPath(x-h, y-h, 2*h, 2*h)
+
+	case ShapeDiamond, ShapeDiamondOpen:
+		h := 1.2 * r
+		p.MoveTo(x, y-h)
+		p.LineTo(x+h, y)
+		p.LineTo(x, y+h)
+		p.LineTo(x-h, y)
+		p.Close()
+
+	case ShapePlus:
+		p.MoveTo(x-r, y)
+		p.LineTo(x+r, y)
+		p.MoveTo(x, y-r)
+		p.LineTo(x, y+r)
+
+	case ShapeCross:
+		h := r / math.Sqrt2
+		p.MoveTo(x-h, y-h)
+		p.LineTo(x+h, y+h)
+		p.MoveTo(x-h, y+h)
+		p.LineTo(x+h, y-h)
+	}
+	canvas.Path(&p, style)
+}
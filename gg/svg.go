@@ -0,0 +1,214 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import (
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+
+	"github.com/ajstarks/svgo"
+)
+
+// svgCanvas is a canvas that writes SVG.
+type svgCanvas struct {
+	svg *svg.SVG
+	id  int
+
+	// fontSize is the default font size of the document.
+	fontSize float64
+}
+
+// newSVGCanvas returns a canvas that writes an SVG image of the given
+// width and height to w. fontFamily and fontSize give the default
+// font.
+func newSVGCanvas(w io.Writer, width, height int, fontFamily string, fontSize float64) *svgCanvas {
+	c := &svgCanvas{svg: svg.New(w), fontSize: fontSize}
+	c.svg.Start(width, height, fmt.Sprintf(`font-size="%.6gpx" font-family="%s"`, fontSize, html.EscapeString(fontFamily)))
+	return c
+}
+
+// end finishes the SVG document.
+func (c *svgCanvas) end() {
+	c.svg.End()
+}
+
+// genid returns a new unique element ID with the given prefix and a
+// URL reference to that ID.
+func (c *svgCanvas) genid(prefix string) (id, ref string) {
+	id = fmt.Sprintf("%s%d", prefix, c.id)
+	ref = "url(#" + id + ")"
+	c.id++
+	return
+}
+
+// svgStyle returns the CSS style for s.
+func svgStyle(s PathStyle) string {
+	var stroke, fill color.Color = color.Transparent, color.Transparent
+	if s.Stroke != nil {
+		stroke = s.Stroke
+	}
+	if s.Fill != nil {
+		fill = s.Fill
+	}
+	style := cssPaint("stroke", stroke) + ";" + cssPaint("fill", fill)
+	if isPaint(stroke) {
+		style += ";stroke-width:" + strconv.FormatFloat(s.StrokeWidth, 'g', 6, 64)
+		if len(s.Dash) > 0 {
+			style += ";stroke-dasharray:"
+			for i, d := range s.Dash {
+				if i > 0 {
+					style += ","
+				}
+				style += strconv.FormatFloat(d, 'g', 6, 64)
+			}
+		}
+	}
+	return style
+}
+
+// svgPathData returns the SVG path data for p.
+func svgPathData(p *Path) string {
+	var d []byte
+	coord := func(pt Point) {
+		d = strconv.AppendFloat(d, pt.X, 'g', 6, 64)
+		d = append(d, ' ')
+		d = strconv.AppendFloat(d, pt.Y, 'g', 6, 64)
+	}
+	// implicit indicates that a coordinate pair appended now
+	// will be an implicit lineto.
+	var implicit bool
+	var cur, start Point
+	for _, seg := range p.Segs {
+		pt := seg.Pts[0]
+		switch seg.Op {
+		case PathMoveTo:
+			d = append(d, 'M')
+			coord(pt)
+			implicit, start = true, pt
+		case PathLineTo:
+			switch {
+			case pt.Y == cur.Y:
+				d = append(d, 'H')
+				d = strconv.AppendFloat(d, pt.X, 'g', 6, 64)
+				implicit = false
+			case pt.X == cur.X:
+				d = append(d, 'V')
+				d = strconv.AppendFloat(d, pt.Y, 'g', 6, 64)
+				implicit = false
+			case implicit:
+				d = append(d, ' ')
+				coord(pt)
+			default:
+				d = append(d, 'L')
+				coord(pt)
+				implicit = true
+			}
+		case PathCubeTo:
+			d = append(d, 'C')
+			coord(seg.Pts[1])
+			d = append(d, ' ')
+			coord(seg.Pts[2])
+			d = append(d, ' ')
+			coord(pt)
+			implicit = false
+		case PathClose:
+			d = append(d, 'Z')
+			pt, implicit = start, false
+		}
+		cur = pt
+	}
+	return wrapPath(string(d))
+}
+
+// isBlack returns whether c is opaque black, the default SVG fill.
+func isBlack(c color.Color) bool {
+	r, g, b, a := c.RGBA()
+	return r == 0 && g == 0 && b == 0 && a == 0xffff
+}
+
+// svgVisible returns whether a shape with the given style paints
+// anything.
+func svgVisible(style PathStyle) bool {
+	return isPaint(style.Fill) || isPaint(style.Stroke)
+}
+
+func (c *svgCanvas) Path(p *Path, style PathStyle) {
+	if len(p.Segs) == 0 || !svgVisible(style) {
+		return
+	}
+	c.svg.Path(svgPathData(p), svgStyle(style))
+}
+
+func (c *svgCanvas) Circle(cx, cy, r float64, style PathStyle) {
+	if !svgVisible(style) {
+		return
+	}
+	c.svg.Circle(int(cx), int(cy), int(r), svgStyle(style))
+}
+
+func (c *svgCanvas) Rect(x, y, w, h float64, style PathStyle) {
+	if !svgVisible(style) {
+		return
+	}
+	c.svg.Rect(int(x), int(y), int(w), int(h), svgStyle(style))
+}
+
+func (c *svgCanvas) Text(x, y float64, text string, style TextStyle) {
+	attrs := make([]string, 0, 5)
+	switch style.Anchor {
+	case TextAnchorMiddle:
+		attrs = append(attrs, `text-anchor="middle"`)
+	case TextAnchorEnd:
+		attrs = append(attrs, `text-anchor="end"`)
+	}
+	// Vertical centering is very poorly supported. dy is the
+	// best chance.
+	switch style.Baseline {
+	case TextBaselineMiddle:
+		attrs = append(attrs, `dy=".3em"`)
+	case TextBaselineTop:
+		attrs = append(attrs, `dy="1em"`)
+	}
+	// Omit attributes that match the document defaults.
+	if style.Size != 0 && style.Size != c.fontSize {
+		attrs = append(attrs, fmt.Sprintf(`font-size="%.6gpx"`, style.Size))
+	}
+	if style.Fill != nil && !isBlack(style.Fill) {
+		attrs = append(attrs, cssPaint("fill", style.Fill))
+	}
+	if style.Rotate != 0 {
+		attrs = append(attrs, fmt.Sprintf(`transform="rotate(%.6g %d %d)"`, style.Rotate, int(x), int(y)))
+	}
+	c.svg.Text(int(x), int(y), text, attrs...)
+}
+
+func (c *svgCanvas) Image(x, y, w, h float64, img image.Image) {
+	uri, err := pngDataURI(img)
+	if err != nil {
+		Warning.Println("error encoding image:", err)
+		return
+	}
+	c.svg.Image(int(x), int(y), int(w), int(h), uri, `preserveAspectRatio="none" style="image-rendering:optimizeSpeed;image-rendering:-moz-crisp-edges;image-rendering:-webkit-optimize-contrast;image-rendering:pixelated"`)
+}
+
+func (c *svgCanvas) BeginGroup(clip *Rect) {
+	if clip == nil {
+		c.svg.Group()
+		return
+	}
+	clipId, clipRef := c.genid("clip")
+	c.svg.ClipPath(`id="` + clipId + `"`)
+	c.svg.Rect(int(clip.X), int(clip.Y), int(clip.W), int(clip.H))
+	c.svg.ClipEnd()
+	c.svg.Group(`clip-path="` + clipRef + `"`)
+}
+
+func (c *svgCanvas) EndGroup() {
+	c.svg.Gend()
+}
@@ -4,26 +4,167 @@
 
 package gg
 
-import "unicode/utf8"
+import (
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// A Font is a TrueType font used to measure and draw text.
+//
+// SVG output leaves drawing text to the viewer, so when using a
+// Font, set the Theme's FontFamily to the same font so that text is
+// drawn with the metrics it was laid out with.
+type Font struct {
+	name string
+	ttf  []byte
+	font *sfnt.Font
+
+	// leading is the distance between lines of text, in ems.
+	leading float64
+}
+
+// ParseFont parses a TrueType font. The font must have TrueType
+// outlines in order to be embedded in PDF output.
+func ParseFont(ttf []byte) (*Font, error) {
+	f, err := sfnt.Parse(ttf)
+	if err != nil {
+		return nil, err
+	}
+	var buf sfnt.Buffer
+	name, err := f.Name(&buf, sfnt.NameIDPostScript)
+	if err != nil || name == "" {
+		name = "Font"
+	}
+	// Compute metrics in fixed point with 1000 units per em.
+	m, err := f.Metrics(&buf, fixed.I(1000), font.HintingNone)
+	if err != nil {
+		return nil, err
+	}
+	leading := float64(m.Height) / 64 / 1000
+	if leading == 0 {
+		leading = 1.25
+	}
+	return &Font{name: name, ttf: ttf, font: f, leading: leading}, nil
+}
+
+var defaultFontState struct {
+	once sync.Once
+	font *Font
+}
+
+// defaultFont returns the font used to measure text and to draw it in
+// output formats that draw glyphs themselves, such as PNG and PDF,
+// when no other font is configured.
+func defaultFont() *Font {
+	defaultFontState.once.Do(func() {
+		f, err := ParseFont(goregular.TTF)
+		if err != nil {
+			panic("failed to parse embedded font: " + err.Error())
+		}
+		defaultFontState.font = f
+	})
+	return defaultFontState.font
+}
+
+// orDefault returns f, or the default font if f is nil.
+func (f *Font) orDefault() *Font {
+	if f == nil {
+		return defaultFont()
+	}
+	return f
+}
 
 type textMetrics struct {
 	width   float64
 	leading float64
 }
 
-// measureString returns the metrics in pixels of s rendered in a font
-// with pixel size pxSize.
+// measureString returns the metrics in pixels of s rendered in font f
+// with pixel size pxSize. If f is nil, it uses the default font.
 //
 // TODO: Often all I want is the leading, which is much cheaper to get
 // than the width. Maybe textMetrics should have methods?
-func measureString(pxSize float64, s string) textMetrics {
-	// TODO: This is absolutely horribly awful. Make it real,
-	// perhaps using the freetype package.
-
-	// Chrome's default font-size is 16px, so 20px is a reasonable
-	// leading.
+func measureString(f *Font, pxSize float64, s string) textMetrics {
+	f = f.orDefault()
+	_, width := f.layout(pxSize, s)
 	return textMetrics{
-		width:   0.5 * pxSize * float64(utf8.RuneCountInString(s)),
-		leading: 1.25 * pxSize,
+		width:   width,
+		leading: f.leading * pxSize,
+	}
+}
+
+// A glyphPos is a glyph positioned on a line of text.
+type glyphPos struct {
+	idx sfnt.GlyphIndex
+	r   rune
+
+	// x is the offset of the glyph's origin from the start of the
+	// line and adv is the glyph's advance width, both in pixels.
+	x, adv float64
+}
+
+// wideScripts are scripts whose characters are typically drawn one
+// em wide.
+var wideScripts = []*unicode.RangeTable{unicode.Han, unicode.Hangul, unicode.Hiragana, unicode.Katakana}
+
+// layout lays out text on a single line in font f with pixel size
+// pxSize. It returns the positioned glyphs and the total width of the
+// line in pixels. Like SVG, layout treats newlines as spaces.
+//
+// Characters that f has no glyph for are laid out as f's .notdef
+// glyph, except that characters from wide scripts like Han are given
+// an advance of one em, which better approximates how they'll appear
+// in viewers that substitute another font.
+func (f *Font) layout(pxSize float64, text string) (glyphs []glyphPos, width float64) {
+	text = strings.Replace(text, "\n", " ", -1)
+
+	// Lay out in font units and scale at the end so the result
+	// is exactly proportional to pxSize.
+	var buf sfnt.Buffer
+	upem := f.font.UnitsPerEm()
+	ppem := fixed.I(int(upem))
+	scale := pxSize / float64(upem) / 64
+
+	var pen fixed.Int26_6
+	prev := sfnt.GlyphIndex(0)
+	for _, r := range text {
+		idx, err := f.font.GlyphIndex(&buf, r)
+		if err != nil {
+			continue
+		}
+		if prev != 0 && idx != 0 {
+			if k, err := f.font.Kern(&buf, prev, idx, ppem, font.HintingNone); err == nil {
+				pen += k
+			}
+		}
+		adv, err := f.font.GlyphAdvance(&buf, idx, ppem, font.HintingNone)
+		if err != nil {
+			adv = 0
+		}
+		if idx == 0 && unicode.In(r, wideScripts...) {
+			adv = ppem
+		}
+		glyphs = append(glyphs, glyphPos{idx, r, float64(pen) * scale, float64(adv) * scale})
+		pen += adv
+		prev = idx
+	}
+	return glyphs, float64(pen) * scale
+}
+
+// advance returns the advance width in pixels of glyph idx in font f
+// with pixel size pxSize.
+func (f *Font) advance(idx sfnt.GlyphIndex, pxSize float64) float64 {
+	var buf sfnt.Buffer
+	upem := f.font.UnitsPerEm()
+	adv, err := f.font.GlyphAdvance(&buf, idx, fixed.I(int(upem)), font.HintingNone)
+	if err != nil {
+		return 0
 	}
+	return float64(adv) * pxSize / float64(upem) / 64
 }
@@ -0,0 +1,44 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMeasureString(t *testing.T) {
+	width := func(size float64, s string) float64 {
+		return measureString(nil, size, s).width
+	}
+
+	if w := width(10, ""); w != 0 {
+		t.Errorf("empty string has width %v, want 0", w)
+	}
+	// Text is measured with the font's advances, so narrow
+	// letters are narrower than wide letters.
+	if ii, ww := width(10, "ii"), width(10, "WW"); !(0 < ii && ii < ww) {
+		t.Errorf("got widths %v for ii and %v for WW, want 0 < ii < WW", ii, ww)
+	}
+	// Widths and leading are proportional to the size.
+	for _, s := range []string{"x", "Hello, world", "AVAVA"} {
+		w10, w20 := width(10, s), width(20, s)
+		if math.Abs(w20-2*w10) > 1e-9 {
+			t.Errorf("%q has width %v at size 10 and %v at size 20, want twice", s, w10, w20)
+		}
+	}
+	if l10, l20 := measureString(nil, 10, "x").leading, measureString(nil, 20, "x").leading; !(l10 > 0) || math.Abs(l20-2*l10) > 1e-9 {
+		t.Errorf("got leading %v at size 10 and %v at size 20, want positive and twice", l10, l20)
+	}
+	// Newlines are laid out as spaces.
+	if w1, w2 := width(10, "a\nb"), width(10, "a b"); w1 != w2 {
+		t.Errorf("a\\nb has width %v, want %v like a b", w1, w2)
+	}
+	// The default font has no Han glyphs, so they're laid out
+	// one em wide.
+	if w := width(10, "中文"); w != 20 {
+		t.Errorf("中文 has width %v, want 20", w)
+	}
+}
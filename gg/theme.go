@@ -0,0 +1,263 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gg
+
+import (
+	"image/color"
+	"math"
+)
+
+// A Theme controls the appearance of the non-data elements of a
+// plot, such as text, backgrounds, grid lines, and facet strips.
+//
+// A Theme is also a Plotter that sets the theme of a Plot. By
+// default, Plots use ThemeGray. To override individual elements of a
+// theme, modify a copy of it before adding it to a plot. For
+// example:
+//
+//	t := gg.ThemeMinimal()
+//	t.GridMajor.Color = color.Gray{0xcc}
+//	plot.Add(t)
+type Theme struct {
+	// FontFamily is the CSS font family used for text in SVG
+	// output.
+	FontFamily string
+
+	// Font is used to measure text and to draw it in output
+	// formats other than SVG. If nil, an embedded Go Regular font
+	// is used.
+	Font *Font
+
+	// FontSize is the default size of text in pixels.
+	FontSize float64
+
+	// TextColor is the default color of text.
+	TextColor color.Color
+
+	// Title, AxisTitle, TickLabel, StripText, LegendTitle, and
+	// LegendText give the appearance of the plot title, axis
+	// labels, tick labels, facet labels, legend guide titles,
+	// and legend key labels, respectively.
+	Title, AxisTitle, TickLabel, StripText, LegendTitle, LegendText TextTheme
+
+	// DataColor is the color of marks that don't have a color
+	// aesthetic.
+	DataColor color.Color
+
+	// Background is the background color of the whole plot. If
+	// nil, the background is transparent.
+	Background color.Color
+
+	// PanelBackground is the background color of each subplot.
+	PanelBackground color.Color
+
+	// PanelBorder is drawn around each subplot.
+	PanelBorder LineTheme
+
+	// GridMajor is drawn at each major tick in each subplot.
+	GridMajor LineTheme
+
+	// AxisLine is drawn along the left and bottom edges of each
+	// subplot.
+	AxisLine LineTheme
+
+	// AxisTicks is used to draw tick marks along the left and
+	// bottom edges of each subplot. TickLength is the length of
+	// minor tick marks in pixels. Major tick marks are twice as
+	// long.
+	AxisTicks  LineTheme
+	TickLength float64
+
+	// TickLabelSep is the distance in pixels between tick labels
+	// and the subplot.
+	TickLabelSep float64
+
+	// TickLabelSpacing is the minimum distance in pixels between
+	// the tick labels of an axis.
+	TickLabelSpacing float64
+
+	// SubplotPadding is the space in pixels around each subplot.
+	SubplotPadding float64
+
+	// StripBackground is the background color of facet labels.
+	StripBackground color.Color
+
+	// LabelHeight is the height of facet labels and axis titles,
+	// as a multiple of the leading of their text. The plot title
+	// is 1.5 times this height.
+	LabelHeight float64
+
+	// LegendKeyBackground is the background color of legend keys.
+	LegendKeyBackground color.Color
+
+	// LegendPadding is the distance in pixels between the plot
+	// and the legend, and LegendSpacing is the distance between
+	// guides in the legend.
+	LegendPadding, LegendSpacing float64
+
+	// LegendKeySize is the minimum width and height of legend
+	// keys in pixels, and LegendKeySep is the distance between a
+	// key and its label.
+	LegendKeySize, LegendKeySep float64
+
+	// LegendMaxKeys is the maximum number of keys to show for a
+	// continuous, non-color scale, and the maximum number of ticks
+	// on a color bar.
+	LegendMaxKeys int
+
+	// LegendBarLength is the length of color bars, as a multiple
+	// of LegendKeySize. LegendBarTicks is drawn at each tick of a
+	// color bar.
+	LegendBarLength float64
+	LegendBarTicks  LineTheme
+
+	// PlotMargins returns the top, right, bottom, and left margins
+	// for a subplot of the given width and height. This space is
+	// left between the data and the edges of each subplot.
+	PlotMargins func(w, h float64) (t, r, b, l float64)
+}
+
+// TextTheme gives the appearance of a text element of a Theme.
+type TextTheme struct {
+	// Size is the size of the text in pixels. If Size is 0, the
+	// Theme's FontSize is used.
+	Size float64
+
+	// Color is the color of the text. If nil, the Theme's
+	// TextColor is used.
+	Color color.Color
+}
+
+// LineTheme gives the appearance of a line element of a Theme. If
+// Color is nil or Width is 0, the line is not drawn.
+type LineTheme struct {
+	Color color.Color
+	Width float64
+
+	// Dash is the dash pattern of the line. See PathStyle.Dash.
+	Dash []float64
+}
+
+func (t Theme) Apply(p *Plot) {
+	p.theme = t
+}
+
+// textSize returns the size of text element tt in pixels.
+func (t *Theme) textSize(tt TextTheme) float64 {
+	if tt.Size == 0 {
+		return t.FontSize
+	}
+	return tt.Size
+}
+
+// textStyle returns the style of text element tt.
+func (t *Theme) textStyle(tt TextTheme) TextStyle {
+	style := TextStyle{Size: t.textSize(tt), Fill: tt.Color, Font: t.Font}
+	if style.Fill == nil {
+		style.Fill = t.TextColor
+	}
+	return style
+}
+
+// measure returns the metrics of s in text element tt.
+func (t *Theme) measure(tt TextTheme, s string) textMetrics {
+	return measureString(t.Font, t.textSize(tt), s)
+}
+
+// style returns the style for stroking l.
+func (l LineTheme) style() PathStyle {
+	return PathStyle{Stroke: l.Color, StrokeWidth: l.Width, Dash: l.Dash}
+}
+
+// defaultPlotMargins adds a 5% margin based on the smaller of w and
+// h. This ensures that (with automatic scales), the extremes of the
+// data and its tick labels don't appear right at the edge of the
+// plot area.
+func defaultPlotMargins(w, h float64) (t, r, b, l float64) {
+	margin := 0.05 * math.Min(w, h)
+	return margin, margin, margin, margin
+}
+
+// ThemeGray returns the default theme, which draws data on a light
+// gray background with white grid lines.
+func ThemeGray() Theme {
+	return Theme{
+		FontFamily: `Roboto,"Helvetica Neue",Helvetica,Arial,sans-serif`,
+		FontSize:   fontSize,
+		TextColor:  color.Black,
+
+		TickLabel: TextTheme{Color: color.Gray{0x66}},
+
+		DataColor: color.Black,
+
+		PanelBackground:  color.Gray{0xee},
+		GridMajor:        LineTheme{Color: color.White, Width: 2},
+		AxisLine:         LineTheme{Color: color.Gray{0x88}, Width: 2},
+		AxisTicks:        LineTheme{Color: color.Gray{0x88}, Width: 2},
+		TickLength:       4,
+		TickLabelSep:     5,
+		TickLabelSpacing: 30,
+		SubplotPadding:   4,
+
+		StripBackground: color.Gray{0xcc},
+		LabelHeight:     1.3,
+
+		LegendKeyBackground: color.Gray{0xee},
+		LegendPadding:       10,
+		LegendSpacing:       10,
+		LegendKeySize:       17,
+		LegendKeySep:        5,
+		LegendMaxKeys:       5,
+		LegendBarLength:     5,
+		LegendBarTicks:      LineTheme{Color: color.White, Width: 1},
+
+		PlotMargins: defaultPlotMargins,
+	}
+}
+
+// ThemeMinimal returns a theme with no backgrounds, axis lines, or
+// tick marks, and thin light gray grid lines.
+func ThemeMinimal() Theme {
+	t := ThemeGray()
+	t.PanelBackground = nil
+	t.GridMajor = LineTheme{Color: color.Gray{0xdd}, Width: 1}
+	t.AxisLine = LineTheme{}
+	t.AxisTicks = LineTheme{}
+	t.StripBackground = nil
+	t.LegendKeyBackground = nil
+	return t
+}
+
+// ThemeBW returns a black-and-white theme suitable for print, with
+// a black border around each subplot and light gray grid lines.
+func ThemeBW() Theme {
+	t := ThemeGray()
+	t.TickLabel.Color = color.Gray{0x33}
+	t.PanelBackground = nil
+	t.PanelBorder = LineTheme{Color: color.Black, Width: 1}
+	t.GridMajor = LineTheme{Color: color.Gray{0xdd}, Width: 1}
+	t.AxisLine = LineTheme{}
+	t.AxisTicks = LineTheme{Color: color.Black, Width: 1}
+	t.StripBackground = color.Gray{0xe5}
+	t.LegendKeyBackground = nil
+	return t
+}
+
+// ThemeDark returns a theme with light text and data on a dark
+// background.
+func ThemeDark() Theme {
+	t := ThemeGray()
+	t.TextColor = color.Gray{0xdd}
+	t.TickLabel.Color = color.Gray{0xaa}
+	t.DataColor = color.Gray{0xee}
+	t.Background = color.Gray{0x22}
+	t.PanelBackground = color.Gray{0x33}
+	t.GridMajor = LineTheme{Color: color.Gray{0x44}, Width: 2}
+	t.AxisLine = LineTheme{Color: color.Gray{0x77}, Width: 2}
+	t.AxisTicks = LineTheme{Color: color.Gray{0x77}, Width: 2}
+	t.StripBackground = color.Gray{0x55}
+	t.LegendKeyBackground = color.Gray{0x33}
+	return t
+}
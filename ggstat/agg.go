@@ -14,7 +14,7 @@ import (
 	"github.com/aclements/go-moremath/vec"
 )
 
-// TODO: AggFirst, AggTukey. StdDev?
+// TODO: AggFirst. StdDev?
 
 // Agg constructs an Aggregate transform from a grouping column and a
 // set of Aggregators.
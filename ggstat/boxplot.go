@@ -0,0 +1,144 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ggstat
+
+import (
+	"math"
+	"reflect"
+	"sort"
+
+	"github.com/aclements/go-gg/generic/slice"
+	"github.com/aclements/go-gg/table"
+	"github.com/aclements/go-moremath/stats"
+)
+
+// BoxPlot computes the Tukey summary of the samples in column Y for
+// each distinct value of column X. This is the data drawn by a box
+// plot.
+//
+// The result of BoxPlot has a "summary" row for each distinct X and
+// an "outlier" row for each sample beyond the whiskers, in addition
+// to constant columns from the input. It has the following columns:
+//
+// - Column X is the value of X. If X is "", there is no X column.
+//
+// - Columns "lower whisker", "lower quartile", "median", "upper
+// quartile", and "upper whisker" are the five-number summary of the
+// samples. The whiskers extend to the most extreme samples within
+// Whisker times the interquartile range of the quartiles.
+//
+// - Columns "lower notch" and "upper notch" are the bounds of an
+// approximate 95% confidence interval of the median, which is
+// 1.58 times the interquartile range divided by the square root of
+// the number of samples.
+//
+// - Column "count" is the number of samples.
+//
+// - Column "outlier" is NaN in summary rows. In outlier rows, it is
+// the value of the outlying sample, and all other columns repeat
+// the summary row.
+type BoxPlot struct {
+	// X is the name of the column to group samples by. If X is
+	// "", each table is summarized as a single group.
+	X string
+
+	// Y is the name of the column to use for samples. Samples
+	// that are NaN or infinite are ignored.
+	Y string
+
+	// Whisker is the maximum length of each whisker as a
+	// multiple of the interquartile range. If Whisker is 0, it
+	// defaults to 1.5. If Whisker is +Inf, the whiskers extend to
+	// the minimum and maximum samples and there are no outliers.
+	Whisker float64
+}
+
+func (s BoxPlot) F(g table.Grouping) table.Grouping {
+	whisker := s.Whisker
+	if whisker == 0 {
+		whisker = 1.5
+	}
+
+	return table.MapTables(g, func(_ table.GroupID, t *table.Table) *table.Table {
+		cols := make([][]float64, len(boxPlotCols))
+		for i := range cols {
+			cols[i] = []float64{}
+		}
+		outlier := []float64{}
+		var xs reflect.Value
+		var groups table.Grouping = t
+		if s.X != "" {
+			xs = reflect.MakeSlice(table.ColType(t, s.X), 0, 0)
+			groups = table.GroupBy(t, s.X)
+		}
+
+		for _, gid := range groups.Tables() {
+			var ys []float64
+			slice.Convert(&ys, groups.Table(gid).MustColumn(s.Y))
+			finite := ys[:0:0]
+			for _, y := range ys {
+				if !math.IsNaN(y) && !math.IsInf(y, 0) {
+					finite = append(finite, y)
+				}
+			}
+			if len(finite) == 0 {
+				continue
+			}
+			sort.Float64s(finite)
+
+			sample := stats.Sample{Xs: finite, Sorted: true}
+			q1, q2, q3 := sample.Quantile(0.25), sample.Quantile(0.5), sample.Quantile(0.75)
+			iqr := q3 - q1
+			loFence, hiFence := q1-whisker*iqr, q3+whisker*iqr
+			if math.IsInf(whisker, 1) {
+				loFence, hiFence = math.Inf(-1), math.Inf(1)
+			}
+			var outliers []float64
+			lo, hi := q1, q3
+			for _, y := range finite {
+				if y < loFence || y > hiFence {
+					outliers = append(outliers, y)
+					continue
+				}
+				lo, hi = math.Min(lo, y), math.Max(hi, y)
+			}
+			notch := 1.58 * iqr / math.Sqrt(float64(len(finite)))
+
+			// Add the summary row followed by a row for
+			// each outlier.
+			summary := []float64{lo, q1, q2, q3, hi, q2 - notch, q2 + notch, float64(len(finite))}
+			for i := 0; i <= len(outliers); i++ {
+				for j := range cols {
+					cols[j] = append(cols[j], summary[j])
+				}
+				if i == 0 {
+					outlier = append(outlier, math.NaN())
+				} else {
+					outlier = append(outlier, outliers[i-1])
+				}
+				if s.X != "" {
+					xs = reflect.Append(xs, reflect.ValueOf(gid.Label()))
+				}
+			}
+		}
+
+		nt := new(table.Builder)
+		if s.X != "" {
+			nt.Add(s.X, xs.Interface())
+		}
+		for i, name := range boxPlotCols {
+			nt.Add(name, cols[i])
+		}
+		nt.Add("outlier", outlier)
+		preserveConsts(nt, t)
+		return nt.Done()
+	})
+}
+
+// boxPlotCols is the summary columns added by BoxPlot, in order.
+var boxPlotCols = []string{
+	"lower whisker", "lower quartile", "median", "upper quartile", "upper whisker",
+	"lower notch", "upper notch", "count",
+}
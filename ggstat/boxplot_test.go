@@ -0,0 +1,103 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ggstat
+
+import (
+	"math"
+	"testing"
+
+	"github.com/aclements/go-gg/table"
+)
+
+func TestBoxPlot(t *testing.T) {
+	inf := math.Inf(1)
+	var xs []string
+	var ys []float64
+	add := func(x string, vals ...float64) {
+		for _, v := range vals {
+			xs, ys = append(xs, x), append(ys, v)
+		}
+	}
+	// Non-finite samples are ignored, so "b" has no summary.
+	add("a", 5, 1, 100, 3, math.NaN(), 2, 4, inf, 6, 7, 9, 8, -inf)
+	add("b", math.NaN(), inf)
+	add("c", 4)
+	tab := new(table.Builder).Add("x", xs).Add("y", ys).Done()
+
+	// The quartiles of "a" use R's type 8 interpolation.
+	q1, q2, q3 := 2+11.0/12, 5.5, 8+1.0/12
+	notchA := 1.58 * (q3 - q1) / math.Sqrt(10)
+
+	type row struct {
+		x                   string
+		lw, lq, med, uq, uw float64
+		ln, un, count       float64
+		outlier             float64
+	}
+	nan := math.NaN()
+	for _, test := range []struct {
+		whisker float64
+		want    []row
+	}{
+		{
+			// The default fences are 1.5 IQRs beyond the
+			// quartiles, so 100 is an outlier.
+			0,
+			[]row{
+				{"a", 1, q1, q2, q3, 9, q2 - notchA, q2 + notchA, 10, nan},
+				{"a", 1, q1, q2, q3, 9, q2 - notchA, q2 + notchA, 10, 100},
+				{"c", 4, 4, 4, 4, 4, 4, 4, 1, nan},
+			},
+		},
+		{
+			// The whiskers extend to the extreme samples.
+			inf,
+			[]row{
+				{"a", 1, q1, q2, q3, 100, q2 - notchA, q2 + notchA, 10, nan},
+				{"c", 4, 4, 4, 4, 4, 4, 4, 1, nan},
+			},
+		},
+	} {
+		res := BoxPlot{X: "x", Y: "y", Whisker: test.whisker}.F(tab)
+		rt := res.Table(res.Tables()[0])
+		col := func(name string) []float64 { return rt.MustColumn(name).([]float64) }
+		gotX := rt.MustColumn("x").([]string)
+		lw, lq, med := col("lower whisker"), col("lower quartile"), col("median")
+		uq, uw := col("upper quartile"), col("upper whisker")
+		ln, un, count := col("lower notch"), col("upper notch"), col("count")
+		outlier := col("outlier")
+
+		if len(gotX) != len(test.want) {
+			t.Errorf("Whisker %v: got %d rows, want %d", test.whisker, len(gotX), len(test.want))
+			continue
+		}
+		for i, w := range test.want {
+			got := row{gotX[i], lw[i], lq[i], med[i], uq[i], uw[i], ln[i], un[i], count[i], outlier[i]}
+			if !rowsClose(
+				[]float64{got.lw, got.lq, got.med, got.uq, got.uw, got.ln, got.un, got.count, got.outlier},
+				[]float64{w.lw, w.lq, w.med, w.uq, w.uw, w.ln, w.un, w.count, w.outlier},
+			) || got.x != w.x {
+				t.Errorf("Whisker %v: row %d is %+v, want %+v", test.whisker, i, got, w)
+			}
+		}
+	}
+}
+
+// rowsClose returns whether got and want are equal within rounding
+// error, treating NaNs as equal.
+func rowsClose(got, want []float64) bool {
+	for i := range got {
+		if math.IsNaN(got[i]) || math.IsNaN(want[i]) {
+			if math.IsNaN(got[i]) != math.IsNaN(want[i]) {
+				return false
+			}
+			continue
+		}
+		if math.Abs(got[i]-want[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
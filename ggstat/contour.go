@@ -0,0 +1,417 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ggstat
+
+import (
+	"math"
+	"sort"
+
+	"github.com/aclements/go-gg/generic/slice"
+	"github.com/aclements/go-gg/table"
+	"github.com/aclements/go-moremath/scale"
+)
+
+// Contour computes the contours of a function sampled on a regular
+// grid. Each row of the input gives the value Z of the function at
+// (X, Y). The grid is made up of the distinct values of X and Y,
+// which need not be evenly spaced; grid points without a row are
+// treated as missing.
+//
+// By default, the result of Contour is a set of contour lines. It
+// has the following columns in addition to constant columns from the
+// input:
+//
+// - Columns X and Y are the vertices of the contour lines.
+//
+// - Column "level" is the value of Z along each line.
+//
+// - Column "path" identifies each line. Each contour level may
+// consist of several disjoint lines. The vertices of each line are
+// consecutive rows, and a line is closed if its first and last
+// vertices are the same.
+//
+// If Filled is true, the result is instead a set of polygons
+// covering the regions where Z is between adjacent levels. Column
+// "level" is the lower bound of the region, column "upper level" is
+// its upper bound, and column "path" identifies each polygon. Each
+// region may consist of many polygons.
+type Contour struct {
+	// X, Y, and Z name the columns giving the grid coordinates
+	// and the value of the function at each grid point.
+	X, Y, Z string
+
+	// Levels gives the values of Z at which to compute contours.
+	// If Levels is nil, about N levels are chosen at "nice"
+	// values spanning the range of Z.
+	Levels []float64
+
+	// N is the approximate number of levels to choose if Levels
+	// is nil. If N is 0, it defaults to 10.
+	N int
+
+	// Filled indicates to compute the regions between levels
+	// rather than lines at each level.
+	Filled bool
+}
+
+func (s Contour) F(g table.Grouping) table.Grouping {
+	return table.MapTables(g, func(_ table.GroupID, t *table.Table) *table.Table {
+		grid := newContourGrid(t, s.X, s.Y, s.Z)
+		levels := s.Levels
+		if levels == nil {
+			levels = grid.levels(s.N)
+		}
+
+		var c contourBuilder
+		if s.Filled {
+			grid.bands(&c, levels)
+		} else {
+			for _, level := range levels {
+				grid.lines(&c, level)
+			}
+		}
+
+		nt := new(table.Builder)
+		nt.Add(s.X, c.xs).Add(s.Y, c.ys).Add("level", c.levels)
+		if s.Filled {
+			nt.Add("upper level", c.uppers)
+		}
+		nt.Add("path", c.paths)
+		preserveConsts(nt, t)
+		return nt.Done()
+	})
+}
+
+// contourGrid is a function sampled on a rectilinear grid.
+type contourGrid struct {
+	xs, ys []float64
+
+	// z[j][i] is the value at (xs[i], ys[j]), or NaN if it is
+	// missing.
+	z [][]float64
+}
+
+func newContourGrid(t *table.Table, xcol, ycol, zcol string) *contourGrid {
+	var xs, ys, zs []float64
+	slice.Convert(&xs, t.MustColumn(xcol))
+	slice.Convert(&ys, t.MustColumn(ycol))
+	slice.Convert(&zs, t.MustColumn(zcol))
+
+	g := &contourGrid{xs: distinct(xs), ys: distinct(ys)}
+	g.z = make([][]float64, len(g.ys))
+	for j := range g.z {
+		g.z[j] = make([]float64, len(g.xs))
+		for i := range g.z[j] {
+			g.z[j][i] = math.NaN()
+		}
+	}
+	for k := range zs {
+		i := sort.SearchFloat64s(g.xs, xs[k])
+		j := sort.SearchFloat64s(g.ys, ys[k])
+		if i < len(g.xs) && j < len(g.ys) {
+			g.z[j][i] = zs[k]
+		}
+	}
+	return g
+}
+
+// distinct returns the sorted distinct finite values of xs.
+func distinct(xs []float64) []float64 {
+	var out []float64
+	for _, x := range xs {
+		if !math.IsNaN(x) && !math.IsInf(x, 0) {
+			out = append(out, x)
+		}
+	}
+	sort.Float64s(out)
+	j := 0
+	for i, x := range out {
+		if i == 0 || x != out[j-1] {
+			out[j] = x
+			j++
+		}
+	}
+	return out[:j]
+}
+
+// levels returns about n "nice" levels spanning the range of g's
+// values.
+func (g *contourGrid) levels(n int) []float64 {
+	if n <= 0 {
+		n = 10
+	}
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, row := range g.z {
+		for _, z := range row {
+			if !math.IsNaN(z) && !math.IsInf(z, 0) {
+				min, max = math.Min(min, z), math.Max(max, z)
+			}
+		}
+	}
+	if min > max {
+		return nil
+	}
+	o := scale.TickOptions{Max: n + 1}
+	s := scale.Linear{Min: min, Max: max}
+	s.Nice(o)
+	major, _ := s.Ticks(o)
+	return major
+}
+
+// A contourEdge identifies a grid edge by the grid point at its lower
+// left and whether it is vertical.
+type contourEdge struct {
+	i, j     int
+	vertical bool
+}
+
+// A contourBuilder accumulates the output of Contour.
+type contourBuilder struct {
+	xs, ys, levels, uppers []float64
+	paths                  []int
+	npaths                 int
+}
+
+// add adds a path with the given vertices.
+func (c *contourBuilder) add(xs, ys []float64, level, upper float64) {
+	for i := range xs {
+		c.xs = append(c.xs, xs[i])
+		c.ys = append(c.ys, ys[i])
+		c.levels = append(c.levels, level)
+		c.uppers = append(c.uppers, upper)
+		c.paths = append(c.paths, c.npaths)
+	}
+	c.npaths++
+}
+
+// cell returns the values at the corners of the cell whose lower
+// left corner is grid point (i, j), counterclockwise from the lower
+// left. ok is false if any corner is missing.
+func (g *contourGrid) cell(i, j int) (z [4]float64, ok bool) {
+	z = [4]float64{g.z[j][i], g.z[j][i+1], g.z[j+1][i+1], g.z[j+1][i]}
+	for _, v := range z {
+		if math.IsNaN(v) {
+			return z, false
+		}
+	}
+	return z, true
+}
+
+// lines adds the contour lines at level to c using marching squares.
+func (g *contourGrid) lines(c *contourBuilder, level float64) {
+	// Find the segments crossing each cell. The edges of the
+	// cell, counterclockwise from the bottom, are numbered 0
+	// through 3. Each segment joins two edges.
+	type segment [2]contourEdge
+	var segs []segment
+	for j := 0; j+1 < len(g.ys); j++ {
+		for i := 0; i+1 < len(g.xs); i++ {
+			z, ok := g.cell(i, j)
+			if !ok {
+				continue
+			}
+			edges := [4]contourEdge{{i, j, false}, {i + 1, j, true}, {i, j + 1, false}, {i, j, true}}
+			var above [4]bool
+			for k := range z {
+				above[k] = z[k] >= level
+			}
+
+			if isSaddle(z, level) {
+				// A saddle. Use the value at the center
+				// of the cell to decide which pair of
+				// opposite corners is connected, and
+				// cut off the other two corners.
+				center := (z[0]+z[1]+z[2]+z[3])/4 >= level
+				if center == above[0] {
+					// Corners 0 and 2 are connected.
+					segs = append(segs, segment{edges[0], edges[1]}, segment{edges[2], edges[3]})
+				} else {
+					segs = append(segs, segment{edges[3], edges[0]}, segment{edges[1], edges[2]})
+				}
+				continue
+			}
+
+			// Otherwise, zero or two edges cross the level.
+			var crossing []contourEdge
+			for k := range edges {
+				if above[k] != above[(k+1)%4] {
+					crossing = append(crossing, edges[k])
+				}
+			}
+			if len(crossing) == 2 {
+				segs = append(segs, segment{crossing[0], crossing[1]})
+			}
+		}
+	}
+
+	// Join segments that share an edge into paths.
+	byEdge := make(map[contourEdge][]int)
+	for k, s := range segs {
+		byEdge[s[0]] = append(byEdge[s[0]], k)
+		byEdge[s[1]] = append(byEdge[s[1]], k)
+	}
+	used := make([]bool, len(segs))
+	// follow extends path from its last edge until it reaches a
+	// dead end or closes.
+	follow := func(path []contourEdge) []contourEdge {
+		for {
+			last, next := path[len(path)-1], -1
+			for _, k := range byEdge[last] {
+				if !used[k] {
+					next = k
+					break
+				}
+			}
+			if next < 0 {
+				return path
+			}
+			used[next] = true
+			if segs[next][0] == last {
+				path = append(path, segs[next][1])
+			} else {
+				path = append(path, segs[next][0])
+			}
+		}
+	}
+	for k, s := range segs {
+		if used[k] {
+			continue
+		}
+		used[k] = true
+		path := follow([]contourEdge{s[0], s[1]})
+		if path[0] != path[len(path)-1] {
+			// Extend the other direction.
+			for a, b := 0, len(path)-1; a < b; a, b = a+1, b-1 {
+				path[a], path[b] = path[b], path[a]
+			}
+			path = follow(path)
+		}
+
+		xs, ys := make([]float64, len(path)), make([]float64, len(path))
+		for k, e := range path {
+			xs[k], ys[k] = g.crossing(e, level)
+		}
+		c.add(xs, ys, level, math.NaN())
+	}
+}
+
+// crossing returns the point where the function crosses level along
+// edge e, using linear interpolation.
+func (g *contourGrid) crossing(e contourEdge, level float64) (x, y float64) {
+	i2, j2 := e.i+1, e.j
+	if e.vertical {
+		i2, j2 = e.i, e.j+1
+	}
+	z1, z2 := g.z[e.j][e.i], g.z[j2][i2]
+	t := (level - z1) / (z2 - z1)
+	return g.xs[e.i] + t*(g.xs[i2]-g.xs[e.i]), g.ys[e.j] + t*(g.ys[j2]-g.ys[e.j])
+}
+
+// A contourVertex is a vertex of a polygon in a filled contour.
+type contourVertex struct {
+	x, y, z float64
+}
+
+// bands adds polygons covering the regions between each pair of
+// adjacent levels to c. Each cell is clipped to each region
+// separately, interpolating linearly along the cell's edges. Each
+// region includes its lower level, and the last region also includes
+// its upper level, so the regions don't overlap.
+func (g *contourGrid) bands(c *contourBuilder, levels []float64) {
+	for j := 0; j+1 < len(g.ys); j++ {
+		for i := 0; i+1 < len(g.xs); i++ {
+			z, ok := g.cell(i, j)
+			if !ok {
+				continue
+			}
+			min := math.Min(math.Min(z[0], z[1]), math.Min(z[2], z[3]))
+			max := math.Max(math.Max(z[0], z[1]), math.Max(z[2], z[3]))
+
+			corners := []contourVertex{
+				{g.xs[i], g.ys[j], z[0]},
+				{g.xs[i+1], g.ys[j], z[1]},
+				{g.xs[i+1], g.ys[j+1], z[2]},
+				{g.xs[i], g.ys[j+1], z[3]},
+			}
+			polys := [][]contourVertex{corners}
+			for _, level := range levels {
+				if !isSaddle(z, level) {
+					continue
+				}
+				// Clipping a saddle along its edges
+				// would make the regions on either
+				// side of level overlap. Instead,
+				// split the cell into triangles
+				// around its center, like lines. This
+				// must be done for every region so
+				// they tile the cell.
+				center := contourVertex{
+					(g.xs[i] + g.xs[i+1]) / 2,
+					(g.ys[j] + g.ys[j+1]) / 2,
+					(z[0] + z[1] + z[2] + z[3]) / 4,
+				}
+				polys = polys[:0]
+				for k := range corners {
+					polys = append(polys, []contourVertex{corners[k], corners[(k+1)%4], center})
+				}
+				break
+			}
+
+			for k := 0; k+1 < len(levels); k++ {
+				lo, hi := levels[k], levels[k+1]
+				last := k+2 == len(levels)
+				if max < lo || min > hi || min == hi && !last {
+					continue
+				}
+				below := func(z float64) bool { return z < hi }
+				if last {
+					below = func(z float64) bool { return z <= hi }
+				}
+				for _, poly := range polys {
+					poly = clipContour(poly, lo, func(z float64) bool { return z >= lo })
+					poly = clipContour(poly, hi, below)
+					if len(poly) < 3 {
+						continue
+					}
+					xs, ys := make([]float64, len(poly)), make([]float64, len(poly))
+					for k, v := range poly {
+						xs[k], ys[k] = v.x, v.y
+					}
+					c.add(xs, ys, lo, hi)
+				}
+			}
+		}
+	}
+}
+
+// isSaddle returns whether the cell with corner values z is a saddle
+// with respect to level; that is, whether opposite corners are on
+// the same side of level and adjacent corners are on different
+// sides.
+func isSaddle(z [4]float64, level float64) bool {
+	var above [4]bool
+	for k := range z {
+		above[k] = z[k] >= level
+	}
+	return above[0] == above[2] && above[1] == above[3] && above[0] != above[1]
+}
+
+// clipContour clips poly to the region where inside(z) is true
+// using Sutherland-Hodgman clipping. inside must be true on one side
+// of level.
+func clipContour(poly []contourVertex, level float64, inside func(z float64) bool) []contourVertex {
+	var out []contourVertex
+	for k, q := range poly {
+		p := poly[(k+len(poly)-1)%len(poly)]
+		if inside(p.z) != inside(q.z) {
+			t := (level - p.z) / (q.z - p.z)
+			out = append(out, contourVertex{p.x + t*(q.x-p.x), p.y + t*(q.y-p.y), level})
+		}
+		if inside(q.z) {
+			out = append(out, q)
+		}
+	}
+	return out
+}
@@ -0,0 +1,216 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ggstat
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aclements/go-gg/table"
+)
+
+// gridTable returns a table sampling f on the grid xs × ys. Points
+// where f returns NaN are omitted from the table.
+func gridTable(xs, ys []float64, f func(x, y float64) float64) *table.Table {
+	var txs, tys, tzs []float64
+	for _, y := range ys {
+		for _, x := range xs {
+			z := f(x, y)
+			if math.IsNaN(z) {
+				continue
+			}
+			txs, tys, tzs = append(txs, x), append(tys, y), append(tzs, z)
+		}
+	}
+	return new(table.Builder).Add("x", txs).Add("y", tys).Add("z", tzs).Done()
+}
+
+// contourPath is a path in the output of Contour.
+type contourPath struct {
+	closed bool
+	// points are the distinct vertices of the path, sorted.
+	points [][2]float64
+}
+
+func (p contourPath) String() string {
+	return fmt.Sprintf("{closed:%v %v}", p.closed, p.points)
+}
+
+// contourPaths splits the output of Contour into paths, rounding
+// vertices to avoid floating-point noise.
+func contourPaths(g table.Grouping) []contourPath {
+	t := g.Table(g.Tables()[0])
+	xs := t.MustColumn("x").([]float64)
+	ys := t.MustColumn("y").([]float64)
+	ids := t.MustColumn("path").([]int)
+	round := func(v float64) float64 { return math.Floor(v*1e6+0.5) / 1e6 }
+
+	var paths []contourPath
+	for i := 0; i < len(ids); {
+		j := i
+		for j < len(ids) && ids[j] == ids[i] {
+			j++
+		}
+		var p contourPath
+		p.closed = j-i > 1 && xs[i] == xs[j-1] && ys[i] == ys[j-1]
+		seen := make(map[[2]float64]bool)
+		for k := i; k < j; k++ {
+			pt := [2]float64{round(xs[k]), round(ys[k])}
+			if !seen[pt] {
+				seen[pt] = true
+				p.points = append(p.points, pt)
+			}
+		}
+		sort.Sort(pointSlice(p.points))
+		paths = append(paths, p)
+		i = j
+	}
+	sort.Sort(pathSlice(paths))
+	return paths
+}
+
+func lessPoint(a, b [2]float64) bool {
+	if a[0] != b[0] {
+		return a[0] < b[0]
+	}
+	return a[1] < b[1]
+}
+
+type pointSlice [][2]float64
+
+func (s pointSlice) Len() int           { return len(s) }
+func (s pointSlice) Less(i, j int) bool { return lessPoint(s[i], s[j]) }
+func (s pointSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// pathSlice sorts paths by their first point.
+type pathSlice []contourPath
+
+func (s pathSlice) Len() int           { return len(s) }
+func (s pathSlice) Less(i, j int) bool { return lessPoint(s[i].points[0], s[j].points[0]) }
+func (s pathSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func TestContourLines(t *testing.T) {
+	peak := func(x, y float64) float64 {
+		if x == 0 && y == 0 {
+			return 1
+		}
+		return 0
+	}
+	for _, test := range []struct {
+		name   string
+		xs, ys []float64
+		f      func(x, y float64) float64
+		want   []contourPath
+	}{
+		{
+			"peak",
+			[]float64{-1, 0, 1}, []float64{-1, 0, 1},
+			peak,
+			[]contourPath{
+				{true, [][2]float64{{-0.5, 0}, {0, -0.5}, {0, 0.5}, {0.5, 0}}},
+			},
+		},
+		{
+			// Corners (0,0) and (1,1) are high. The center
+			// of the cell is at the level, so the high
+			// corners are connected and the low corners
+			// are cut off.
+			"saddle",
+			[]float64{0, 1}, []float64{0, 1},
+			func(x, y float64) float64 {
+				if x == y {
+					return 1
+				}
+				return 0
+			},
+			[]contourPath{
+				{false, [][2]float64{{0, 0.5}, {0.5, 1}}},
+				{false, [][2]float64{{0.5, 0}, {1, 0.5}}},
+			},
+		},
+		{
+			// Removing a corner drops the upper right
+			// cell, leaving an open path around the peak.
+			"missing",
+			[]float64{-1, 0, 1}, []float64{-1, 0, 1},
+			func(x, y float64) float64 {
+				if x == 1 && y == 1 {
+					return math.NaN()
+				}
+				return peak(x, y)
+			},
+			[]contourPath{
+				{false, [][2]float64{{-0.5, 0}, {0, -0.5}, {0, 0.5}, {0.5, 0}}},
+			},
+		},
+	} {
+		tab := gridTable(test.xs, test.ys, test.f)
+		got := contourPaths(Contour{X: "x", Y: "y", Z: "z", Levels: []float64{0.5}}.F(tab))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got paths %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestContourBands(t *testing.T) {
+	xs := []float64{0, 1, 3, 4, 7}
+	ys := []float64{0, 2, 3, 5}
+	for _, test := range []struct {
+		name   string
+		f      func(x, y float64) float64
+		levels []float64
+		want   float64
+	}{
+		{"smooth", func(x, y float64) float64 { return math.Sin(x) * math.Cos(y) }, nil, 7 * 5},
+		{"saddle", func(x, y float64) float64 { return (x - 3.5) * (y - 2.5) }, nil, 7 * 5},
+		{"plateau", func(x, y float64) float64 { return math.Min(x, 3) }, []float64{0, 1, 2, 3}, 7 * 5},
+		{
+			// The point (3, 3) is missing, which drops
+			// the four cells around it.
+			"missing",
+			func(x, y float64) float64 {
+				if x == 3 && y == 3 {
+					return math.NaN()
+				}
+				return x + y
+			},
+			nil,
+			7*5 - (4-1)*(5-2),
+		},
+	} {
+		tab := gridTable(xs, ys, test.f)
+		res := Contour{X: "x", Y: "y", Z: "z", Levels: test.levels, Filled: true}.F(tab)
+		rt := res.Table(res.Tables()[0])
+		pxs := rt.MustColumn("x").([]float64)
+		pys := rt.MustColumn("y").([]float64)
+		ids := rt.MustColumn("path").([]int)
+
+		// Sum the areas of the polygons with the shoelace
+		// formula.
+		var area float64
+		for i := 0; i < len(ids); {
+			j := i
+			for j < len(ids) && ids[j] == ids[i] {
+				j++
+			}
+			var a float64
+			for k := i; k < j; k++ {
+				k2 := k + 1
+				if k2 == j {
+					k2 = i
+				}
+				a += pxs[k]*pys[k2] - pxs[k2]*pys[k]
+			}
+			area += math.Abs(a) / 2
+			i = j
+		}
+		if math.Abs(area-test.want) > 1e-9 {
+			t.Errorf("%s: polygons have total area %v, want %v", test.name, area, test.want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ggstat
+
+import (
+	"math"
+
+	"github.com/aclements/go-gg/generic/slice"
+	"github.com/aclements/go-gg/table"
+)
+
+// HexBin bins points into a grid of hexagons and counts the points in
+// each hexagon. This summarizes dense scatterplots that would
+// otherwise overplot.
+//
+// The hexagons have vertical sides and are arranged in rows, with
+// alternate rows offset by half a hexagon. By default, all groups
+// share the same grid so their bins line up.
+//
+// The result of HexBin has a row for each non-empty hexagon. It has
+// the following columns in addition to constant columns from the
+// input:
+//
+// - Columns X and Y are the center of the hexagon.
+//
+// - Column W is the sum of the weights of the points in the
+// hexagon, or column "count" is the number of points if W is "".
+//
+// - Columns "width" and "height" are the horizontal distance between
+// the centers of adjacent hexagons in a row and the vertical
+// distance between adjacent rows. These are the same for every row.
+type HexBin struct {
+	// X and Y are the names of the columns giving the points to
+	// bin.
+	X, Y string
+
+	// W is the optional name of the column to use for point
+	// weights. It may be "" to weight each point as 1.
+	W string
+
+	// Bins is the number of hexagons across the range of X. The
+	// hexagons are sized so there are also about Bins rows
+	// across the range of Y. If Bins is 0, it defaults to 30.
+	Bins int
+
+	// Width and Height, if non-zero, override Bins to give the
+	// horizontal distance between the centers of hexagons and the
+	// vertical distance between rows, in the units of X and Y.
+	Width, Height float64
+
+	// SplitGroups indicates that each group should have a
+	// separate grid based on the data in that group alone.
+	SplitGroups bool
+}
+
+func (b HexBin) F(g table.Grouping) table.Grouping {
+	var grid hexGrid
+	if !b.SplitGroups {
+		grid = b.grid(g)
+	}
+	return table.MapTables(g, func(_ table.GroupID, t *table.Table) *table.Table {
+		grid := grid
+		if b.SplitGroups {
+			grid = b.grid(t)
+		}
+
+		var xs, ys, ws []float64
+		slice.Convert(&xs, t.MustColumn(b.X))
+		slice.Convert(&ys, t.MustColumn(b.Y))
+		if b.W != "" {
+			slice.Convert(&ws, t.MustColumn(b.W))
+		}
+
+		// Sum the weights in each hexagon, in the order the
+		// hexagons are first seen.
+		type hex struct{ col, row int }
+		index := make(map[hex]int)
+		var cx, cy, sums []float64
+		for i := range xs {
+			if math.IsNaN(xs[i]) || math.IsNaN(ys[i]) {
+				continue
+			}
+			col, row := grid.bin(xs[i], ys[i])
+			k, ok := index[hex{col, row}]
+			if !ok {
+				k = len(sums)
+				index[hex{col, row}] = k
+				x, y := grid.center(col, row)
+				cx, cy, sums = append(cx, x), append(cy, y), append(sums, 0)
+			}
+			if ws == nil {
+				sums[k]++
+			} else {
+				sums[k] += ws[i]
+			}
+		}
+
+		wname := b.W
+		if wname == "" {
+			wname = "count"
+		}
+		nt := new(table.Builder).Add(b.X, cx).Add(b.Y, cy).Add(wname, sums)
+		nt.AddConst("width", grid.dx).AddConst("height", grid.dy)
+		preserveConsts(nt, t)
+		return nt.Done()
+	})
+}
+
+// hexGrid is a grid of hexagons with vertical sides. The hexagon in
+// column col of row row is centered at (x0 + (col + (row&1)/2)*dx,
+// y0 + row*dy).
+type hexGrid struct {
+	x0, y0, dx, dy float64
+}
+
+// grid computes the hexagon grid covering the data in g.
+func (b HexBin) grid(g table.Grouping) hexGrid {
+	xmin, xmax := math.Inf(1), math.Inf(-1)
+	ymin, ymax := math.Inf(1), math.Inf(-1)
+	for _, gid := range g.Tables() {
+		var xs, ys []float64
+		slice.Convert(&xs, g.Table(gid).MustColumn(b.X))
+		slice.Convert(&ys, g.Table(gid).MustColumn(b.Y))
+		for i := range xs {
+			if math.IsNaN(xs[i]) || math.IsNaN(ys[i]) {
+				continue
+			}
+			xmin, xmax = math.Min(xmin, xs[i]), math.Max(xmax, xs[i])
+			ymin, ymax = math.Min(ymin, ys[i]), math.Max(ymax, ys[i])
+		}
+	}
+	if xmin > xmax {
+		return hexGrid{0, 0, 1, 1}
+	}
+
+	bins := b.Bins
+	if bins <= 0 {
+		bins = 30
+	}
+	// Pick the spacing so the hexagons are regular if the ranges
+	// of X and Y are displayed with the same length.
+	dx, dy := b.Width, b.Height
+	if dx == 0 {
+		dx = (xmax - xmin) / float64(bins)
+	}
+	if dy == 0 {
+		dy = (ymax - ymin) / float64(bins) * math.Sqrt(3) / 2
+	}
+	if dx == 0 {
+		dx = 1
+	}
+	if dy == 0 {
+		dy = 1
+	}
+	return hexGrid{xmin, ymin, dx, dy}
+}
+
+// bin returns the column and row of the hexagon containing (x, y).
+func (h hexGrid) bin(x, y float64) (col, row int) {
+	// Work in units where the hexagons are regular with unit
+	// spacing, so the closest center is the containing hexagon.
+	// The centers form two rectangular lattices of alternate
+	// rows, so find the closest center in each lattice.
+	u := (x - h.x0) / h.dx
+	v := (y - h.y0) / h.dy
+	const rowScale = 0.8660254037844386 // √3/2
+
+	// Even rows.
+	r1 := 2 * math.Floor(v/2+0.5)
+	c1 := math.Floor(u + 0.5)
+	d1 := math.Hypot(u-c1, (v-r1)*rowScale)
+
+	// Odd rows, whose centers are offset by half a column.
+	r2 := 2*math.Floor((v-1)/2+0.5) + 1
+	c2 := math.Floor(u)
+	d2 := math.Hypot(u-(c2+0.5), (v-r2)*rowScale)
+
+	if d1 <= d2 {
+		return int(c1), int(r1)
+	}
+	return int(c2), int(r2)
+}
+
+// center returns the center of the hexagon at col and row.
+func (h hexGrid) center(col, row int) (x, y float64) {
+	return h.x0 + (float64(col)+float64(row&1)/2)*h.dx, h.y0 + float64(row)*h.dy
+}
@@ -0,0 +1,120 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ggstat
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aclements/go-gg/table"
+)
+
+func TestHexGridBin(t *testing.T) {
+	unit := hexGrid{0, 0, 1, 1}
+	// A grid with 10 bins across [0, 10]×[0, 10].
+	bounded := HexBin{X: "x", Y: "y", Bins: 10}.grid(
+		new(table.Builder).Add("x", []float64{0, 10}).Add("y", []float64{0, 10}).Done())
+
+	for _, test := range []struct {
+		grid     hexGrid
+		x, y     float64
+		col, row int
+	}{
+		// Centers of even and odd rows.
+		{unit, 0, 0, 0, 0},
+		{unit, 3, 2, 3, 2},
+		{unit, 0.5, 1, 0, 1},
+		{unit, 2.5, 3, 2, 3},
+		{unit, -0.5, -1, -1, -1},
+
+		// Either side of the edge shared by (0, 0) and (0, 1),
+		// along the line between their centers.
+		{unit, 0.225, 0.45, 0, 0},
+		{unit, 0.275, 0.55, 0, 1},
+
+		// Either side of the edge shared by (0, 0) and
+		// (-1, 1).
+		{unit, -0.225, 0.45, 0, 0},
+		{unit, -0.275, 0.55, -1, 1},
+
+		// The corners of the data. Rows are √3/2 apart, so
+		// y = 10 is in row 12.
+		{bounded, 0, 0, 0, 0},
+		{bounded, 10, 0, 10, 0},
+		{bounded, 0, 10, 0, 12},
+		{bounded, 10, 10, 10, 12},
+	} {
+		col, row := test.grid.bin(test.x, test.y)
+		if col != test.col || row != test.row {
+			t.Errorf("%+v.bin(%v, %v) = %d, %d, want %d, %d", test.grid, test.x, test.y, col, row, test.col, test.row)
+		}
+	}
+}
+
+func TestHexBin(t *testing.T) {
+	// Group a has two points in the same hexagon. Group b has one
+	// point that isn't at a center of the shared grid.
+	tab := new(table.Builder).
+		Add("x", []float64{0, 0.1, 4.3}).
+		Add("y", []float64{0, 0.1, 4.2}).
+		Add("w", []float64{2, 3, 7}).
+		Add("g", []string{"a", "a", "b"}).
+		Done()
+	g := table.GroupBy(tab, "g")
+
+	type hex struct {
+		x, y, w float64
+	}
+	for _, test := range []struct {
+		name string
+		stat HexBin
+		want map[string][]hex
+	}{
+		{
+			"count",
+			HexBin{X: "x", Y: "y", Width: 1, Height: 1},
+			map[string][]hex{"a": {{0, 0, 2}}, "b": {{4, 4, 1}}},
+		},
+		{
+			"weight",
+			HexBin{X: "x", Y: "y", W: "w", Width: 1, Height: 1},
+			map[string][]hex{"a": {{0, 0, 5}}, "b": {{4, 4, 7}}},
+		},
+		{
+			// Each group's grid starts at its own data.
+			"split",
+			HexBin{X: "x", Y: "y", Width: 1, Height: 1, SplitGroups: true},
+			map[string][]hex{"a": {{0, 0, 2}}, "b": {{4.3, 4.2, 1}}},
+		},
+		{
+			"split weight",
+			HexBin{X: "x", Y: "y", W: "w", Width: 1, Height: 1, SplitGroups: true},
+			map[string][]hex{"a": {{0, 0, 5}}, "b": {{4.3, 4.2, 7}}},
+		},
+	} {
+		wcol := test.stat.W
+		if wcol == "" {
+			wcol = "count"
+		}
+		res := test.stat.F(g)
+		got := make(map[string][]hex)
+		for _, gid := range res.Tables() {
+			rt := res.Table(gid)
+			xs := rt.MustColumn("x").([]float64)
+			ys := rt.MustColumn("y").([]float64)
+			ws := rt.MustColumn(wcol).([]float64)
+			for i := range xs {
+				label := gid.Label().(string)
+				got[label] = append(got[label], hex{xs[i], ys[i], ws[i]})
+			}
+			if w, h := rt.MustColumn("width").([]float64), rt.MustColumn("height").([]float64); w[0] != 1 || h[0] != 1 {
+				t.Errorf("%s: width, height = %v, %v, want 1, 1", test.name, w[0], h[0])
+			}
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}